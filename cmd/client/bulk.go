@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/bulk"
+	"github.com/DaniilZ77/InMemDB/internal/tcp/client"
+)
+
+const (
+	usage = `usage: client [-address addr]                  interactive mode
+       client import [-file data.csv] [flags]    set keys from csv or json lines file
+       client export [-file data.jsonl] [flags]  write keys to csv or json lines file
+
+run "client <command> -h" for flags of the command
+`
+	progressInterval = time.Second
+)
+
+type bulkOptions struct {
+	address   string
+	file      string
+	format    string
+	batchSize int
+	dryRun    bool
+	prefix    string
+}
+
+func runBulk(command string, args []string) error {
+	var opts bulkOptions
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.StringVar(&opts.address, "address", "127.0.0.1:3223", "server address, host:port or unix:///path.sock")
+	flags.StringVar(&opts.file, "file", "", "csv or json lines file, stdin or stdout if empty")
+	flags.StringVar(&opts.format, "format", "", "csv or jsonl, by extension of the file if empty")
+
+	switch command {
+	case "import":
+		flags.IntVar(&opts.batchSize, "batch", 100, "keys set by one MSET request")
+		flags.BoolVar(&opts.dryRun, "dry_run", false, "only validate the file")
+	case "export":
+		flags.StringVar(&opts.prefix, "prefix", "", "export only keys with the prefix")
+	}
+	_ = flags.Parse(args)
+
+	format, err := bulk.ParseFormat(opts.format, opts.file)
+	if err != nil {
+		return err
+	}
+
+	var c *client.Client
+	if !opts.dryRun {
+		c, err = client.NewClient(opts.address)
+		if err != nil {
+			return fmt.Errorf("failed to init client: %w", err)
+		}
+		defer c.Close() // nolint
+	}
+
+	if command == "import" {
+		var sender bulk.Sender
+		if c != nil {
+			sender = c
+		}
+		return importFile(sender, format, opts)
+	}
+
+	return exportFile(c, format, opts)
+}
+
+func importFile(sender bulk.Sender, format bulk.Format, opts bulkOptions) error {
+	input := io.Reader(os.Stdin)
+	if opts.file != "" {
+		file, err := os.Open(opts.file)
+		if err != nil {
+			return err
+		}
+		defer file.Close() // nolint
+		input = file
+	}
+
+	reader, err := bulk.NewReader(input, format)
+	if err != nil {
+		return err
+	}
+
+	progress := newProgress("imported")
+	stats, err := bulk.Import(reader, sender,
+		bulk.WithBatchSize(opts.batchSize),
+		bulk.WithDryRun(opts.dryRun),
+		bulk.WithProgress(progress.report),
+	)
+	if opts.dryRun {
+		fmt.Fprintf(os.Stderr, "dry run: %d valid records, %d invalid\n", stats.Records, stats.Invalid)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%w (imported %d records)", err, stats.Records)
+	}
+
+	progress.done(stats)
+	return nil
+}
+
+func exportFile(streamer bulk.Streamer, format bulk.Format, opts bulkOptions) error {
+	output := io.Writer(os.Stdout)
+	if opts.file != "" {
+		file, err := os.Create(opts.file)
+		if err != nil {
+			return err
+		}
+		defer file.Close() // nolint
+		output = file
+	}
+
+	writer, err := bulk.NewWriter(output, format)
+	if err != nil {
+		return err
+	}
+
+	progress := newProgress("exported")
+	stats, err := bulk.Export(streamer, writer, opts.prefix, progress.report)
+	if err != nil {
+		return err
+	}
+
+	progress.done(stats)
+	return nil
+}
+
+// progress prints stats to stderr at most once per progressInterval.
+type progress struct {
+	action  string
+	started time.Time
+	printed time.Time
+}
+
+func newProgress(action string) *progress {
+	return &progress{action: action, started: time.Now(), printed: time.Now()}
+}
+
+func (p *progress) report(stats bulk.Stats) {
+	if time.Since(p.printed) < progressInterval {
+		return
+	}
+	p.printed = time.Now()
+	fmt.Fprintf(os.Stderr, "%s %d records\n", p.action, stats.Records)
+}
+
+func (p *progress) done(stats bulk.Stats) {
+	fmt.Fprintf(os.Stderr, "%s %d records in %s\n", p.action, stats.Records, time.Since(p.started).Round(time.Millisecond))
+}
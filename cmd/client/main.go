@@ -2,13 +2,27 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
 
 	"github.com/DaniilZ77/InMemDB/internal/tcp/client"
 )
 
 func main() {
+	if len(os.Args) > 1 && (os.Args[1] == "import" || os.Args[1] == "export") {
+		if err := runBulk(os.Args[1], os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var address string
-	flag.StringVar(&address, "address", "127.0.0.1:3223", "server address")
+	flag.StringVar(&address, "address", "127.0.0.1:3223", "server address, host:port or unix:///path.sock")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
 
 	flag.Parse()
 
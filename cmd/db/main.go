@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"flag"
 	"os"
 	"os/signal"
+	"syscall"
 
 	"github.com/DaniilZ77/InMemDB/internal/app"
 	"github.com/DaniilZ77/InMemDB/internal/config"
@@ -11,10 +13,32 @@ import (
 )
 
 func main() {
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	restoreDir := flag.String("restore_dir", "", "restore state of the stopped node into the fresh directory and exit")
+	restoreLSN := flag.Int("restore_lsn", -1, "last lsn to restore")
+	restoreTime := flag.String("restore_time", "", "time to restore state as of, RFC3339")
+	importDump := flag.String("import_dump", "", "load the dump into the empty data directory of the stopped node and exit")
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	config := config.MustConfig()
+	if *importDump != "" {
+		if err := app.ImportDump(config, *importDump); err != nil {
+			panic(err)
+		}
+		return
+	}
+	if *restoreDir != "" {
+		target, err := app.ParseRecoveryTarget(*restoreLSN, *restoreTime)
+		if err != nil {
+			panic(err)
+		}
+		if err := app.RestoreToPoint(config, target, *restoreDir); err != nil {
+			panic(err)
+		}
+		return
+	}
+
 	if err := app.RunApp(ctx, config); err != nil {
 		panic(err)
 	}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
+)
+
+const usage = `usage: walctl <command> [flags]
+
+commands:
+  list      list segments with lsn ranges and sizes
+  dump      print commands as json lines
+  verify    check integrity of the segments
+  truncate  cut off corrupted tail of the segment
+
+run "walctl <command> -h" for flags of the command
+`
+
+type options struct {
+	directory string
+	keyFile   string
+	keyEnv    string
+	segment   string
+	key       string
+	fromLSN   int
+	toLSN     int
+}
+
+// record is a dumped command.
+type record struct {
+	Segment string   `json:"segment"`
+	LSN     int      `json:"lsn"`
+	Type    string   `json:"type"`
+	Args    []string `json:"args"`
+	Time    string   `json:"time,omitempty"`
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	command := os.Args[1]
+	var opts options
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.StringVar(&opts.directory, "dir", "./data/wal", "wal data directory")
+	flags.StringVar(&opts.keyFile, "key_file", "", "file with encryption keys")
+	flags.StringVar(&opts.keyEnv, "key_env", "", "environment variable with encryption keys")
+
+	var run func(*disk.Disk, options) error
+	switch command {
+	case "list":
+		run = list
+	case "dump":
+		flags.StringVar(&opts.key, "key", "", "dump only commands with the key")
+		flags.IntVar(&opts.fromLSN, "from", 0, "first lsn to dump")
+		flags.IntVar(&opts.toLSN, "to", -1, "last lsn to dump, negative for no limit")
+		run = dump
+	case "verify":
+		run = verify
+	case "truncate":
+		flags.StringVar(&opts.segment, "segment", "", "segment to truncate, the last one by default")
+		run = truncate
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	_ = flags.Parse(os.Args[2:])
+
+	disk, err := newDisk(opts)
+	if err == nil {
+		err = run(disk, opts)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "walctl:", err)
+		os.Exit(1)
+	}
+}
+
+func newDisk(opts options) (*disk.Disk, error) {
+	// Disk creates missing directory, but inspecting a mistyped path must
+	// fail instead of leaving an empty WAL directory behind.
+	if _, err := os.Stat(opts.directory); err != nil {
+		return nil, err
+	}
+
+	var keys *disk.Keyring
+	if opts.keyFile != "" || opts.keyEnv != "" {
+		var err error
+		if keys, err = disk.LoadKeyring(opts.keyFile, opts.keyEnv, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	log := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	return disk.NewDisk(opts.directory, 0, log, disk.WithKeyring(keys)), nil
+}
+
+// lastLSN returns lsn of the last command of the records.
+func lastLSN(records []disk.Record) (int, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	commands, err := wal.DecodeRecord(records[len(records)-1])
+	if err != nil || len(commands) == 0 {
+		return int(records[len(records)-1].LSN), err
+	}
+
+	return commands[len(commands)-1].LSN, nil
+}
+
+func list(d *disk.Disk, _ options) error {
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "SEGMENT\tVERSION\tKEY\tFIRST LSN\tLAST LSN\tRECORDS\tSIZE\tSTATUS")
+
+	err := d.Inspect(func(info disk.SegmentInfo, records []disk.Record) error {
+		status := "ok"
+		switch {
+		case errors.Is(info.Err, disk.ErrUnknownKey) || errors.Is(info.Err, disk.ErrDecryption):
+			status = info.Err.Error()
+		case info.Err != nil:
+			status = fmt.Sprintf("corrupted at offset %d", info.Valid)
+		}
+
+		lsnRange := "-\t-"
+		if len(records) > 0 {
+			last, err := lastLSN(records)
+			if err != nil {
+				status = "malformed payload"
+			}
+			lsnRange = fmt.Sprintf("%d\t%d", info.FirstLSN, last)
+		}
+
+		fmt.Fprintf(out, "%s\t%d\t%d\t%s\t%d\t%d\t%s\n",
+			info.Name, info.Header.Version, info.Header.KeyID, lsnRange, info.Records, info.Size, status)
+		return nil
+	})
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+
+	return err
+}
+
+func dump(d *disk.Disk, opts options) error {
+	encoder := json.NewEncoder(os.Stdout)
+	var problems error
+
+	err := d.Inspect(func(info disk.SegmentInfo, records []disk.Record) error {
+		for _, r := range records {
+			if opts.toLSN >= 0 && int(r.LSN) > opts.toLSN {
+				return nil
+			}
+
+			commands, err := wal.DecodeRecord(r)
+			if err != nil {
+				problems = errors.Join(problems, fmt.Errorf("segment %s: %w", info.Name, err))
+				break
+			}
+
+			for _, command := range commands {
+				if !matches(command, opts) {
+					continue
+				}
+				if err := encoder.Encode(newRecord(info.Name, command)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if info.Err != nil {
+			problems = errors.Join(problems, fmt.Errorf("segment %s: %w", info.Name, info.Err))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return problems
+}
+
+func matches(command wal.Command, opts options) bool {
+	if command.LSN < opts.fromLSN || (opts.toLSN >= 0 && command.LSN > opts.toLSN) {
+		return false
+	}
+
+	return opts.key == "" || (len(command.Args) > 0 && command.Args[0] == opts.key)
+}
+
+func newRecord(segment string, command wal.Command) record {
+	r := record{
+		Segment: segment,
+		LSN:     command.LSN,
+		Type:    parser.CommandType(command.CommandType).String(),
+		Args:    command.Args,
+	}
+	if !command.Timestamp.IsZero() {
+		r.Time = command.Timestamp.Format(time.RFC3339Nano)
+	}
+
+	return r
+}
+
+func verify(d *disk.Disk, _ options) error {
+	var segments, commands, corrupted int
+	var last, lastCorrupted string
+	err := d.Inspect(func(info disk.SegmentInfo, records []disk.Record) error {
+		segments++
+		last = info.Name
+
+		segmentErr := info.Err
+		for _, r := range records {
+			decoded, err := wal.DecodeRecord(r)
+			if err != nil {
+				segmentErr = errors.Join(segmentErr, err)
+				break
+			}
+			commands += len(decoded)
+		}
+
+		if segmentErr != nil {
+			corrupted++
+			lastCorrupted = info.Name
+			fmt.Printf("%s: %v\n", info.Name, segmentErr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("segments: %d, commands: %d, corrupted segments: %d\n", segments, commands, corrupted)
+	switch {
+	case corrupted == 1 && lastCorrupted == last:
+		return fmt.Errorf("last segment %s is corrupted, its tail can be truncated", last)
+	case corrupted > 0:
+		return errors.New("wal is corrupted")
+	}
+
+	return nil
+}
+
+func truncate(d *disk.Disk, opts options) error {
+	segment := opts.segment
+	if segment == "" {
+		var err error
+		if segment, err = d.LastSegment(); err != nil {
+			return err
+		}
+		if segment == "" {
+			return errors.New("no segments")
+		}
+	}
+
+	dropped, err := d.TruncateSegment(segment)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: dropped %d bytes\n", segment, dropped)
+	return nil
+}
@@ -5,7 +5,6 @@ go 1.23.4
 require (
 	github.com/stretchr/testify v1.10.0
 	go.uber.org/automaxprocs v1.6.0
-	golang.org/x/sync v0.12.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
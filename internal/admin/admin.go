@@ -0,0 +1,419 @@
+package admin
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"path"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
+	"github.com/DaniilZ77/InMemDB/internal/storage/engine"
+	"github.com/DaniilZ77/InMemDB/internal/storage/replication"
+	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
+	"github.com/DaniilZ77/InMemDB/internal/tcp/server"
+)
+
+const (
+	errNoSuchClient   = "ERROR(no such client)"
+	errInvalidID      = "ERROR(invalid client id)"
+	errNotSupported   = "ERROR(command is not supported)"
+	errInvalidPattern = "ERROR(invalid pattern)"
+	emptyResponse     = "NIL"
+	infoSectionHeader = "# "
+	modeReadWrite     = "read-write"
+	modeReadOnly      = "read-only"
+)
+
+//go:generate mockery --name=Engine --case=snake --inpackage --inpackage-suffix --with-expecter
+type Engine interface {
+	Stats() []engine.ShardStats
+}
+
+//go:generate mockery --name=Wal --case=snake --inpackage --inpackage-suffix --with-expecter
+type Wal interface {
+	LSN() int
+	PendingWrites() int
+	ReadOnly() error
+	SetReadWrite() error
+	BatchStats() wal.BatchStats
+}
+
+//go:generate mockery --name=Segments --case=snake --inpackage --inpackage-suffix --with-expecter
+type Segments interface {
+	SegmentsCount() (int, error)
+}
+
+//go:generate mockery --name=Replication --case=snake --inpackage --inpackage-suffix --with-expecter
+type Replication interface {
+	Stats() replication.Stats
+	Replicas() []replication.ReplicaInfo
+}
+
+//go:generate mockery --name=Clients --case=snake --inpackage --inpackage-suffix --with-expecter
+type Clients interface {
+	Clients() []server.ClientInfo
+	KillClient(id uint64) bool
+	Stats() server.ConnectionStats
+}
+
+type Admin struct {
+	started  time.Time
+	engine   Engine
+	wal      Wal
+	segments Segments
+	replica  Replication
+	clients  Clients
+	settings map[string]string
+	log      *slog.Logger
+}
+
+func NewAdmin(engine Engine, settings map[string]string, log *slog.Logger, opts ...AdminOption) (*Admin, error) {
+	if engine == nil {
+		return nil, errors.New("engine is nil")
+	}
+	if log == nil {
+		return nil, errors.New("logger is nil")
+	}
+
+	admin := &Admin{
+		started:  time.Now(),
+		engine:   engine,
+		settings: settings,
+		log:      log,
+	}
+
+	for _, opt := range opts {
+		opt(admin)
+	}
+
+	return admin, nil
+}
+
+func (a *Admin) Execute(command *parser.Command) string {
+	switch command.Type {
+	case parser.INFO:
+		return a.info()
+	case parser.CLIENT:
+		switch command.Args[0] {
+		case parser.ClientList:
+			return a.clientList()
+		case parser.ClientKill:
+			return a.clientKill(command.Args[1])
+		}
+	case parser.CONFIG:
+		if command.Args[0] == parser.ConfigGet {
+			return a.configGet(command.Args[1])
+		}
+	case parser.READWRITE:
+		return a.readWrite()
+	case parser.REPLICAS:
+		return a.replicas()
+	}
+
+	return errNotSupported
+}
+
+type section struct {
+	name   string
+	values [][2]string
+}
+
+func (s *section) add(key string, value any) {
+	s.values = append(s.values, [2]string{key, fmt.Sprint(value)})
+}
+
+func (a *Admin) info() string {
+	sections := []*section{
+		a.serverInfo(),
+		a.keyspaceInfo(),
+		a.memoryInfo(),
+		a.walInfo(),
+		a.replicationInfo(),
+		a.clientsInfo(),
+	}
+
+	builder := strings.Builder{}
+	for _, section := range sections {
+		if section == nil {
+			continue
+		}
+		if builder.Len() > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(infoSectionHeader + section.name + "\n")
+		for _, value := range section.values {
+			builder.WriteString(value[0] + ":" + value[1] + "\n")
+		}
+	}
+
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+func (a *Admin) serverInfo() *section {
+	info := &section{name: "Server"}
+	info.add("uptime_seconds", int(time.Since(a.started).Seconds()))
+	info.add("go_version", runtime.Version())
+	info.add("goroutines", runtime.NumGoroutine())
+	return info
+}
+
+func (a *Admin) keyspaceInfo() *section {
+	info := &section{name: "Keyspace"}
+	stats := a.engine.Stats()
+
+	keys := 0
+	for _, shard := range stats {
+		keys += shard.Keys
+	}
+	info.add("keys", keys)
+	info.add("shards", len(stats))
+	for i, shard := range stats {
+		info.add("shard_"+strconv.Itoa(i), fmt.Sprintf("keys=%d,bytes=%d", shard.Keys, shard.Bytes))
+	}
+
+	return info
+}
+
+func (a *Admin) memoryInfo() *section {
+	info := &section{name: "Memory"}
+
+	dataset := 0
+	for _, shard := range a.engine.Stats() {
+		dataset += shard.Bytes
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	info.add("used_memory_dataset", dataset)
+	info.add("used_memory_heap", memStats.HeapAlloc)
+	info.add("used_memory_sys", memStats.Sys)
+	return info
+}
+
+func (a *Admin) walInfo() *section {
+	if a.wal == nil {
+		return nil
+	}
+
+	info := &section{name: "Wal"}
+	info.add("wal_lsn", a.wal.LSN())
+	info.add("wal_pending_writes", a.wal.PendingWrites())
+	if err := a.wal.ReadOnly(); err != nil {
+		info.add("wal_mode", modeReadOnly)
+		info.add("wal_read_only_reason", err)
+	} else {
+		info.add("wal_mode", modeReadWrite)
+	}
+	batches := a.wal.BatchStats()
+	info.add("wal_batch_sizes", formatHistogram(batches.Sizes))
+	info.add("wal_batch_waits_us", formatHistogram(batches.Waits))
+	info.add("wal_fsync_latency_us", batches.FsyncLatency)
+	if a.segments != nil {
+		segments, err := a.segments.SegmentsCount()
+		if err != nil {
+			a.log.Warn("failed to count segments", slog.Any("error", err))
+		} else {
+			info.add("wal_segments", segments)
+		}
+	}
+
+	return info
+}
+
+// formatHistogram formats buckets as upper_bound=count pairs, the unbounded
+// bucket is inf.
+func formatHistogram(buckets []wal.HistogramBucket) string {
+	pairs := make([]string, 0, len(buckets))
+	for _, bucket := range buckets {
+		bound := "inf"
+		if bucket.UpperBound != math.MaxInt64 {
+			bound = strconv.FormatInt(bucket.UpperBound, 10)
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%d", bound, bucket.Count))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// readWrite switches WAL back to read-write mode after disk failures, it fails
+// if the disk isn't writable yet.
+func (a *Admin) readWrite() string {
+	if a.wal == nil {
+		return errNotSupported
+	}
+
+	if err := a.wal.SetReadWrite(); err != nil {
+		a.log.Warn("failed to switch to read-write mode", slog.Any("error", err))
+		return fmt.Sprintf("ERROR(%s)", err.Error())
+	}
+
+	return "OK"
+}
+
+func (a *Admin) replicationInfo() *section {
+	info := &section{name: "Replication"}
+	if a.replica == nil {
+		info.add("role", replication.RoleMaster)
+		info.add("replication_enabled", false)
+		return info
+	}
+
+	stats := a.replica.Stats()
+	info.add("role", stats.Role)
+	info.add("replication_enabled", true)
+	info.add("replication_lsn", stats.LSN)
+	if stats.LastSync.IsZero() {
+		info.add("last_sync_seconds", -1)
+	} else {
+		info.add("last_sync_seconds", int(time.Since(stats.LastSync).Seconds()))
+	}
+	info.add("replication_lag_records", stats.LagRecords)
+	info.add("replication_lag_seconds", formatSeconds(stats.Lag))
+
+	if stats.Role == replication.RoleMaster {
+		replicas := a.replica.Replicas()
+		info.add("connected_replicas", len(replicas))
+		for i, replica := range replicas {
+			info.add("replica_"+strconv.Itoa(i), fmt.Sprintf("id=%s,acked_lsn=%d,lag_records=%d,lag_bytes=%d,lag_seconds=%s",
+				replica.ID,
+				replica.AckedLSN,
+				replica.LagRecords,
+				replica.LagBytes,
+				formatSeconds(replica.Lag),
+			))
+		}
+	}
+
+	return info
+}
+
+// replicas lists replicas connected to master and their lag.
+func (a *Admin) replicas() string {
+	if a.replica == nil || a.replica.Stats().Role != replication.RoleMaster {
+		return errNotSupported
+	}
+
+	replicas := a.replica.Replicas()
+	if len(replicas) == 0 {
+		return emptyResponse
+	}
+
+	now := time.Now()
+	lines := make([]string, 0, len(replicas))
+	for _, replica := range replicas {
+		lines = append(lines, fmt.Sprintf("id=%s lsn=%d acked_lsn=%d lag_records=%d lag_bytes=%d lag_seconds=%s last_ack=%d",
+			replica.ID,
+			replica.LSN,
+			replica.AckedLSN,
+			replica.LagRecords,
+			replica.LagBytes,
+			formatSeconds(replica.Lag),
+			int(now.Sub(replica.LastAck).Seconds()),
+		))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatSeconds formats duration as seconds with millisecond precision.
+func formatSeconds(duration time.Duration) string {
+	return strconv.FormatFloat(duration.Seconds(), 'f', 3, 64)
+}
+
+func (a *Admin) clientsInfo() *section {
+	if a.clients == nil {
+		return nil
+	}
+
+	info := &section{name: "Clients"}
+	stats := a.clients.Stats()
+	info.add("connected_clients", stats.Connected)
+	info.add("total_connections_received", stats.Accepted)
+	info.add("total_commands_processed", stats.Commands)
+	info.add("throttled_commands", stats.Throttled)
+	return info
+}
+
+func (a *Admin) clientList() string {
+	if a.clients == nil {
+		return errNotSupported
+	}
+
+	clients := a.clients.Clients()
+	if len(clients) == 0 {
+		return emptyResponse
+	}
+	slices.SortFunc(clients, func(a, b server.ClientInfo) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	now := time.Now()
+	lines := make([]string, 0, len(clients))
+	for _, client := range clients {
+		idle := now.Sub(client.ConnectedAt)
+		if !client.LastCommand.IsZero() {
+			idle = now.Sub(client.LastCommand)
+		}
+		lines = append(lines, fmt.Sprintf("id=%d addr=%s laddr=%s age=%d idle=%d commands=%d",
+			client.ID,
+			client.RemoteAddr,
+			client.LocalAddr,
+			int(now.Sub(client.ConnectedAt).Seconds()),
+			int(idle.Seconds()),
+			client.Commands,
+		))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (a *Admin) clientKill(id string) string {
+	if a.clients == nil {
+		return errNotSupported
+	}
+
+	clientID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return errInvalidID
+	}
+
+	if !a.clients.KillClient(clientID) {
+		return errNoSuchClient
+	}
+
+	a.log.Info("client killed", slog.Uint64("id", clientID))
+	return "OK"
+}
+
+func (a *Admin) configGet(pattern string) string {
+	keys := make([]string, 0, len(a.settings))
+	for key := range a.settings {
+		ok, err := path.Match(pattern, key)
+		if err != nil {
+			a.log.Warn("bad config pattern", slog.String("pattern", pattern), slog.Any("error", err))
+			return errInvalidPattern
+		}
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return emptyResponse
+	}
+	slices.Sort(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		lines = append(lines, key+": "+a.settings[key])
+	}
+
+	return strings.Join(lines, "\n")
+}
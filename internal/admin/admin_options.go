@@ -0,0 +1,22 @@
+package admin
+
+type AdminOption func(*Admin)
+
+func WithWal(wal Wal, segments Segments) AdminOption {
+	return func(a *Admin) {
+		a.wal = wal
+		a.segments = segments
+	}
+}
+
+func WithReplication(replica Replication) AdminOption {
+	return func(a *Admin) {
+		a.replica = replica
+	}
+}
+
+func WithClients(clients Clients) AdminOption {
+	return func(a *Admin) {
+		a.clients = clients
+	}
+}
@@ -0,0 +1,216 @@
+package admin
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
+	"github.com/DaniilZ77/InMemDB/internal/storage/engine"
+	"github.com/DaniilZ77/InMemDB/internal/storage/replication"
+	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
+	"github.com/DaniilZ77/InMemDB/internal/tcp/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfo(t *testing.T) {
+	t.Parallel()
+
+	batches := wal.BatchStats{
+		Sizes:        []wal.HistogramBucket{{UpperBound: 1, Count: 3}, {UpperBound: math.MaxInt64, Count: 1}},
+		Waits:        []wal.HistogramBucket{{UpperBound: 100, Count: 4}, {UpperBound: math.MaxInt64}},
+		FsyncLatency: 250,
+	}
+	engineStats, wal, segments, replica, clients := NewMockEngine(t), NewMockWal(t), NewMockSegments(t), NewMockReplication(t), NewMockClients(t)
+	admin, err := NewAdmin(engineStats, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithWal(wal, segments),
+		WithReplication(replica),
+		WithClients(clients))
+	require.NoError(t, err)
+
+	engineStats.EXPECT().Stats().Return([]engine.ShardStats{{Keys: 2, Bytes: 20}, {Keys: 1, Bytes: 5}})
+	wal.EXPECT().LSN().Return(42).Once()
+	wal.EXPECT().PendingWrites().Return(7).Once()
+	wal.EXPECT().ReadOnly().Return(nil).Once()
+	wal.EXPECT().BatchStats().Return(batches).Once()
+	segments.EXPECT().SegmentsCount().Return(3, nil).Once()
+	replica.EXPECT().Stats().Return(replication.Stats{
+		Role:       replication.RoleSlave,
+		LSN:        40,
+		LastSync:   time.Now().Add(-2 * time.Second),
+		LagRecords: 2,
+		Lag:        1500 * time.Millisecond,
+	}).Once()
+	clients.EXPECT().Stats().Return(server.ConnectionStats{Connected: 2, Accepted: 5, Commands: 10, Throttled: 1}).Once()
+
+	info := admin.Execute(&parser.Command{Type: parser.INFO})
+
+	for _, expected := range []string{
+		"# Server\nuptime_seconds:0",
+		"# Keyspace\nkeys:3\nshards:2\nshard_0:keys=2,bytes=20\nshard_1:keys=1,bytes=5",
+		"used_memory_dataset:25",
+		"# Wal\nwal_lsn:42\nwal_pending_writes:7\nwal_mode:read-write\n" +
+			"wal_batch_sizes:1=3,inf=1\nwal_batch_waits_us:100=4,inf=0\nwal_fsync_latency_us:250\nwal_segments:3",
+		"# Replication\nrole:slave\nreplication_enabled:true\nreplication_lsn:40\nlast_sync_seconds:2\n" +
+			"replication_lag_records:2\nreplication_lag_seconds:1.500",
+		"# Clients\nconnected_clients:2\ntotal_connections_received:5\ntotal_commands_processed:10\nthrottled_commands:1",
+	} {
+		assert.Contains(t, info, expected)
+	}
+}
+
+func TestInfo_ReadOnly(t *testing.T) {
+	t.Parallel()
+
+	var batches wal.BatchStats
+	engineStats, wal := NewMockEngine(t), NewMockWal(t)
+	admin, err := NewAdmin(engineStats, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithWal(wal, nil))
+	require.NoError(t, err)
+
+	engineStats.EXPECT().Stats().Return(nil)
+	wal.EXPECT().LSN().Return(42).Once()
+	wal.EXPECT().PendingWrites().Return(0).Once()
+	wal.EXPECT().ReadOnly().Return(errors.New("read-only mode: disk is full")).Once()
+	wal.EXPECT().BatchStats().Return(batches).Once()
+
+	info := admin.Execute(&parser.Command{Type: parser.INFO})
+	assert.Contains(t, info, "wal_mode:read-only\nwal_read_only_reason:read-only mode: disk is full")
+}
+
+func TestReadWrite(t *testing.T) {
+	t.Parallel()
+
+	wal := NewMockWal(t)
+	admin, err := NewAdmin(NewMockEngine(t), nil, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithWal(wal, nil))
+	require.NoError(t, err)
+
+	wal.EXPECT().SetReadWrite().Return(errors.New("disk is full")).Once()
+	assert.Equal(t, "ERROR(disk is full)", admin.Execute(&parser.Command{Type: parser.READWRITE}))
+
+	wal.EXPECT().SetReadWrite().Return(nil).Once()
+	assert.Equal(t, "OK", admin.Execute(&parser.Command{Type: parser.READWRITE}))
+
+	admin, err = NewAdmin(NewMockEngine(t), nil, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	assert.Equal(t, errNotSupported, admin.Execute(&parser.Command{Type: parser.READWRITE}))
+}
+
+func TestReplicas(t *testing.T) {
+	t.Parallel()
+
+	engineStats, replica := NewMockEngine(t), NewMockReplication(t)
+	admin, err := NewAdmin(engineStats, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithReplication(replica))
+	require.NoError(t, err)
+
+	engineStats.EXPECT().Stats().Return(nil)
+	replicas := []replication.ReplicaInfo{
+		{ID: "slave-1", LSN: 42, AckedLSN: 42, LastAck: time.Now()},
+		{ID: "slave-2", LSN: 42, AckedLSN: 30, LastAck: time.Now().Add(-3 * time.Second), LagRecords: 12, LagBytes: 640, Lag: 3 * time.Second},
+	}
+	replica.EXPECT().Stats().Return(replication.Stats{Role: replication.RoleMaster, LSN: 42, LagRecords: 12, Lag: 3 * time.Second})
+	replica.EXPECT().Replicas().Return(replicas)
+
+	assert.Equal(t,
+		"id=slave-1 lsn=42 acked_lsn=42 lag_records=0 lag_bytes=0 lag_seconds=0.000 last_ack=0\n"+
+			"id=slave-2 lsn=42 acked_lsn=30 lag_records=12 lag_bytes=640 lag_seconds=3.000 last_ack=3",
+		admin.Execute(&parser.Command{Type: parser.REPLICAS}))
+
+	assert.Contains(t, admin.Execute(&parser.Command{Type: parser.INFO}),
+		"replication_lag_records:12\nreplication_lag_seconds:3.000\nconnected_replicas:2\n"+
+			"replica_0:id=slave-1,acked_lsn=42,lag_records=0,lag_bytes=0,lag_seconds=0.000\n"+
+			"replica_1:id=slave-2,acked_lsn=30,lag_records=12,lag_bytes=640,lag_seconds=3.000")
+
+	slave := NewMockReplication(t)
+	slave.EXPECT().Stats().Return(replication.Stats{Role: replication.RoleSlave}).Once()
+	admin, err = NewAdmin(NewMockEngine(t), nil, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithReplication(slave))
+	require.NoError(t, err)
+	assert.Equal(t, errNotSupported, admin.Execute(&parser.Command{Type: parser.REPLICAS}))
+}
+
+func TestInfo_WithoutWal(t *testing.T) {
+	t.Parallel()
+
+	engineStats := NewMockEngine(t)
+	admin, err := NewAdmin(engineStats, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	engineStats.EXPECT().Stats().Return(nil)
+
+	info := admin.Execute(&parser.Command{Type: parser.INFO})
+	assert.NotContains(t, info, "# Wal")
+	assert.NotContains(t, info, "# Clients")
+	assert.Contains(t, info, "role:master\nreplication_enabled:false")
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	clients := NewMockClients(t)
+	admin, err := NewAdmin(NewMockEngine(t), nil, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithClients(clients))
+	require.NoError(t, err)
+
+	now := time.Now()
+	clients.EXPECT().Clients().Return([]server.ClientInfo{
+		{ID: 2, RemoteAddr: "127.0.0.1:2", LocalAddr: "127.0.0.1:3223", ConnectedAt: now, LastCommand: now, Commands: 3},
+		{ID: 1, RemoteAddr: "127.0.0.1:1", LocalAddr: "127.0.0.1:3223", ConnectedAt: now.Add(-time.Minute)},
+	}).Once()
+	clients.EXPECT().KillClient(uint64(1)).Return(true).Once()
+	clients.EXPECT().KillClient(uint64(3)).Return(false).Once()
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name: "list",
+			args: []string{parser.ClientList},
+			expected: "id=1 addr=127.0.0.1:1 laddr=127.0.0.1:3223 age=60 idle=60 commands=0\n" +
+				"id=2 addr=127.0.0.1:2 laddr=127.0.0.1:3223 age=0 idle=0 commands=3",
+		},
+		{name: "kill", args: []string{parser.ClientKill, "1"}, expected: "OK"},
+		{name: "kill unknown", args: []string{parser.ClientKill, "3"}, expected: errNoSuchClient},
+		{name: "kill invalid id", args: []string{parser.ClientKill, "abc"}, expected: errInvalidID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := admin.Execute(&parser.Command{Type: parser.CLIENT, Args: tt.args})
+			assert.Equal(t, tt.expected, res)
+		})
+	}
+}
+
+func TestConfigGet(t *testing.T) {
+	t.Parallel()
+
+	settings := map[string]string{
+		"network.address":         ":3223",
+		"network.max_connections": "100",
+		"engine.shards_number":    "16",
+	}
+	admin, err := NewAdmin(NewMockEngine(t), settings, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		pattern  string
+		expected string
+	}{
+		{name: "exact key", pattern: "engine.shards_number", expected: "engine.shards_number: 16"},
+		{name: "pattern", pattern: "network.*", expected: "network.address: :3223\nnetwork.max_connections: 100"},
+		{name: "no match", pattern: "wal.*", expected: emptyResponse},
+		{name: "invalid pattern", pattern: "[", expected: errInvalidPattern},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := admin.Execute(&parser.Command{Type: parser.CONFIG, Args: []string{parser.ConfigGet, tt.pattern}})
+			assert.Equal(t, tt.expected, res)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package admin
+
+import (
+	server "github.com/DaniilZ77/InMemDB/internal/tcp/server"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockClients is an autogenerated mock type for the Clients type
+type MockClients struct {
+	mock.Mock
+}
+
+type MockClients_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockClients) EXPECT() *MockClients_Expecter {
+	return &MockClients_Expecter{mock: &_m.Mock}
+}
+
+// Clients provides a mock function with no fields
+func (_m *MockClients) Clients() []server.ClientInfo {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Clients")
+	}
+
+	var r0 []server.ClientInfo
+	if rf, ok := ret.Get(0).(func() []server.ClientInfo); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]server.ClientInfo)
+		}
+	}
+
+	return r0
+}
+
+// MockClients_Clients_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Clients'
+type MockClients_Clients_Call struct {
+	*mock.Call
+}
+
+// Clients is a helper method to define mock.On call
+func (_e *MockClients_Expecter) Clients() *MockClients_Clients_Call {
+	return &MockClients_Clients_Call{Call: _e.mock.On("Clients")}
+}
+
+func (_c *MockClients_Clients_Call) Run(run func()) *MockClients_Clients_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockClients_Clients_Call) Return(_a0 []server.ClientInfo) *MockClients_Clients_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockClients_Clients_Call) RunAndReturn(run func() []server.ClientInfo) *MockClients_Clients_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// KillClient provides a mock function with given fields: id
+func (_m *MockClients) KillClient(id uint64) bool {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for KillClient")
+	}
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(uint64) bool); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// MockClients_KillClient_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'KillClient'
+type MockClients_KillClient_Call struct {
+	*mock.Call
+}
+
+// KillClient is a helper method to define mock.On call
+//   - id uint64
+func (_e *MockClients_Expecter) KillClient(id interface{}) *MockClients_KillClient_Call {
+	return &MockClients_KillClient_Call{Call: _e.mock.On("KillClient", id)}
+}
+
+func (_c *MockClients_KillClient_Call) Run(run func(id uint64)) *MockClients_KillClient_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uint64))
+	})
+	return _c
+}
+
+func (_c *MockClients_KillClient_Call) Return(_a0 bool) *MockClients_KillClient_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockClients_KillClient_Call) RunAndReturn(run func(uint64) bool) *MockClients_KillClient_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Stats provides a mock function with no fields
+func (_m *MockClients) Stats() server.ConnectionStats {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stats")
+	}
+
+	var r0 server.ConnectionStats
+	if rf, ok := ret.Get(0).(func() server.ConnectionStats); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(server.ConnectionStats)
+	}
+
+	return r0
+}
+
+// MockClients_Stats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Stats'
+type MockClients_Stats_Call struct {
+	*mock.Call
+}
+
+// Stats is a helper method to define mock.On call
+func (_e *MockClients_Expecter) Stats() *MockClients_Stats_Call {
+	return &MockClients_Stats_Call{Call: _e.mock.On("Stats")}
+}
+
+func (_c *MockClients_Stats_Call) Run(run func()) *MockClients_Stats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockClients_Stats_Call) Return(_a0 server.ConnectionStats) *MockClients_Stats_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockClients_Stats_Call) RunAndReturn(run func() server.ConnectionStats) *MockClients_Stats_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockClients creates a new instance of MockClients. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockClients(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockClients {
+	mock := &MockClients{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,82 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package admin
+
+import (
+	engine "github.com/DaniilZ77/InMemDB/internal/storage/engine"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockEngine is an autogenerated mock type for the Engine type
+type MockEngine struct {
+	mock.Mock
+}
+
+type MockEngine_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockEngine) EXPECT() *MockEngine_Expecter {
+	return &MockEngine_Expecter{mock: &_m.Mock}
+}
+
+// Stats provides a mock function with no fields
+func (_m *MockEngine) Stats() []engine.ShardStats {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stats")
+	}
+
+	var r0 []engine.ShardStats
+	if rf, ok := ret.Get(0).(func() []engine.ShardStats); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]engine.ShardStats)
+		}
+	}
+
+	return r0
+}
+
+// MockEngine_Stats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Stats'
+type MockEngine_Stats_Call struct {
+	*mock.Call
+}
+
+// Stats is a helper method to define mock.On call
+func (_e *MockEngine_Expecter) Stats() *MockEngine_Stats_Call {
+	return &MockEngine_Stats_Call{Call: _e.mock.On("Stats")}
+}
+
+func (_c *MockEngine_Stats_Call) Run(run func()) *MockEngine_Stats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockEngine_Stats_Call) Return(_a0 []engine.ShardStats) *MockEngine_Stats_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockEngine_Stats_Call) RunAndReturn(run func() []engine.ShardStats) *MockEngine_Stats_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockEngine creates a new instance of MockEngine. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockEngine(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockEngine {
+	mock := &MockEngine{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,127 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package admin
+
+import (
+	replication "github.com/DaniilZ77/InMemDB/internal/storage/replication"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockReplication is an autogenerated mock type for the Replication type
+type MockReplication struct {
+	mock.Mock
+}
+
+type MockReplication_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockReplication) EXPECT() *MockReplication_Expecter {
+	return &MockReplication_Expecter{mock: &_m.Mock}
+}
+
+// Replicas provides a mock function with no fields
+func (_m *MockReplication) Replicas() []replication.ReplicaInfo {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Replicas")
+	}
+
+	var r0 []replication.ReplicaInfo
+	if rf, ok := ret.Get(0).(func() []replication.ReplicaInfo); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]replication.ReplicaInfo)
+		}
+	}
+
+	return r0
+}
+
+// MockReplication_Replicas_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Replicas'
+type MockReplication_Replicas_Call struct {
+	*mock.Call
+}
+
+// Replicas is a helper method to define mock.On call
+func (_e *MockReplication_Expecter) Replicas() *MockReplication_Replicas_Call {
+	return &MockReplication_Replicas_Call{Call: _e.mock.On("Replicas")}
+}
+
+func (_c *MockReplication_Replicas_Call) Run(run func()) *MockReplication_Replicas_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockReplication_Replicas_Call) Return(_a0 []replication.ReplicaInfo) *MockReplication_Replicas_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockReplication_Replicas_Call) RunAndReturn(run func() []replication.ReplicaInfo) *MockReplication_Replicas_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Stats provides a mock function with no fields
+func (_m *MockReplication) Stats() replication.Stats {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stats")
+	}
+
+	var r0 replication.Stats
+	if rf, ok := ret.Get(0).(func() replication.Stats); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(replication.Stats)
+	}
+
+	return r0
+}
+
+// MockReplication_Stats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Stats'
+type MockReplication_Stats_Call struct {
+	*mock.Call
+}
+
+// Stats is a helper method to define mock.On call
+func (_e *MockReplication_Expecter) Stats() *MockReplication_Stats_Call {
+	return &MockReplication_Stats_Call{Call: _e.mock.On("Stats")}
+}
+
+func (_c *MockReplication_Stats_Call) Run(run func()) *MockReplication_Stats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockReplication_Stats_Call) Return(_a0 replication.Stats) *MockReplication_Stats_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockReplication_Stats_Call) RunAndReturn(run func() replication.Stats) *MockReplication_Stats_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockReplication creates a new instance of MockReplication. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockReplication(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockReplication {
+	mock := &MockReplication{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,87 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package admin
+
+import mock "github.com/stretchr/testify/mock"
+
+// MockSegments is an autogenerated mock type for the Segments type
+type MockSegments struct {
+	mock.Mock
+}
+
+type MockSegments_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockSegments) EXPECT() *MockSegments_Expecter {
+	return &MockSegments_Expecter{mock: &_m.Mock}
+}
+
+// SegmentsCount provides a mock function with no fields
+func (_m *MockSegments) SegmentsCount() (int, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for SegmentsCount")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func() (int, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockSegments_SegmentsCount_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SegmentsCount'
+type MockSegments_SegmentsCount_Call struct {
+	*mock.Call
+}
+
+// SegmentsCount is a helper method to define mock.On call
+func (_e *MockSegments_Expecter) SegmentsCount() *MockSegments_SegmentsCount_Call {
+	return &MockSegments_SegmentsCount_Call{Call: _e.mock.On("SegmentsCount")}
+}
+
+func (_c *MockSegments_SegmentsCount_Call) Run(run func()) *MockSegments_SegmentsCount_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockSegments_SegmentsCount_Call) Return(_a0 int, _a1 error) *MockSegments_SegmentsCount_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockSegments_SegmentsCount_Call) RunAndReturn(run func() (int, error)) *MockSegments_SegmentsCount_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockSegments creates a new instance of MockSegments. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockSegments(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockSegments {
+	mock := &MockSegments{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,260 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package admin
+
+import (
+	wal "github.com/DaniilZ77/InMemDB/internal/storage/wal"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockWal is an autogenerated mock type for the Wal type
+type MockWal struct {
+	mock.Mock
+}
+
+type MockWal_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockWal) EXPECT() *MockWal_Expecter {
+	return &MockWal_Expecter{mock: &_m.Mock}
+}
+
+// BatchStats provides a mock function with no fields
+func (_m *MockWal) BatchStats() wal.BatchStats {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for BatchStats")
+	}
+
+	var r0 wal.BatchStats
+	if rf, ok := ret.Get(0).(func() wal.BatchStats); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(wal.BatchStats)
+	}
+
+	return r0
+}
+
+// MockWal_BatchStats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'BatchStats'
+type MockWal_BatchStats_Call struct {
+	*mock.Call
+}
+
+// BatchStats is a helper method to define mock.On call
+func (_e *MockWal_Expecter) BatchStats() *MockWal_BatchStats_Call {
+	return &MockWal_BatchStats_Call{Call: _e.mock.On("BatchStats")}
+}
+
+func (_c *MockWal_BatchStats_Call) Run(run func()) *MockWal_BatchStats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockWal_BatchStats_Call) Return(_a0 wal.BatchStats) *MockWal_BatchStats_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockWal_BatchStats_Call) RunAndReturn(run func() wal.BatchStats) *MockWal_BatchStats_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// LSN provides a mock function with no fields
+func (_m *MockWal) LSN() int {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for LSN")
+	}
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
+// MockWal_LSN_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'LSN'
+type MockWal_LSN_Call struct {
+	*mock.Call
+}
+
+// LSN is a helper method to define mock.On call
+func (_e *MockWal_Expecter) LSN() *MockWal_LSN_Call {
+	return &MockWal_LSN_Call{Call: _e.mock.On("LSN")}
+}
+
+func (_c *MockWal_LSN_Call) Run(run func()) *MockWal_LSN_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockWal_LSN_Call) Return(_a0 int) *MockWal_LSN_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockWal_LSN_Call) RunAndReturn(run func() int) *MockWal_LSN_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PendingWrites provides a mock function with no fields
+func (_m *MockWal) PendingWrites() int {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for PendingWrites")
+	}
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
+// MockWal_PendingWrites_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PendingWrites'
+type MockWal_PendingWrites_Call struct {
+	*mock.Call
+}
+
+// PendingWrites is a helper method to define mock.On call
+func (_e *MockWal_Expecter) PendingWrites() *MockWal_PendingWrites_Call {
+	return &MockWal_PendingWrites_Call{Call: _e.mock.On("PendingWrites")}
+}
+
+func (_c *MockWal_PendingWrites_Call) Run(run func()) *MockWal_PendingWrites_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockWal_PendingWrites_Call) Return(_a0 int) *MockWal_PendingWrites_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockWal_PendingWrites_Call) RunAndReturn(run func() int) *MockWal_PendingWrites_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReadOnly provides a mock function with no fields
+func (_m *MockWal) ReadOnly() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReadOnly")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockWal_ReadOnly_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReadOnly'
+type MockWal_ReadOnly_Call struct {
+	*mock.Call
+}
+
+// ReadOnly is a helper method to define mock.On call
+func (_e *MockWal_Expecter) ReadOnly() *MockWal_ReadOnly_Call {
+	return &MockWal_ReadOnly_Call{Call: _e.mock.On("ReadOnly")}
+}
+
+func (_c *MockWal_ReadOnly_Call) Run(run func()) *MockWal_ReadOnly_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockWal_ReadOnly_Call) Return(_a0 error) *MockWal_ReadOnly_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockWal_ReadOnly_Call) RunAndReturn(run func() error) *MockWal_ReadOnly_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetReadWrite provides a mock function with no fields
+func (_m *MockWal) SetReadWrite() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetReadWrite")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockWal_SetReadWrite_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetReadWrite'
+type MockWal_SetReadWrite_Call struct {
+	*mock.Call
+}
+
+// SetReadWrite is a helper method to define mock.On call
+func (_e *MockWal_Expecter) SetReadWrite() *MockWal_SetReadWrite_Call {
+	return &MockWal_SetReadWrite_Call{Call: _e.mock.On("SetReadWrite")}
+}
+
+func (_c *MockWal_SetReadWrite_Call) Run(run func()) *MockWal_SetReadWrite_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockWal_SetReadWrite_Call) Return(_a0 error) *MockWal_SetReadWrite_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockWal_SetReadWrite_Call) RunAndReturn(run func() error) *MockWal_SetReadWrite_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockWal creates a new instance of MockWal. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockWal(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockWal {
+	mock := &MockWal{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"log/slog"
+
+	"github.com/DaniilZ77/InMemDB/internal/admin"
+	"github.com/DaniilZ77/InMemDB/internal/config"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+	"github.com/DaniilZ77/InMemDB/internal/storage/engine"
+	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
+	"github.com/DaniilZ77/InMemDB/internal/tcp/server"
+)
+
+func NewAdmin(
+	config *config.Config,
+	engine *engine.Engine,
+	wal *wal.Wal,
+	disk *disk.Disk,
+	replica any,
+	server *server.Server,
+	log *slog.Logger) (*admin.Admin, error) {
+	settings, err := config.Flatten()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []admin.AdminOption{admin.WithClients(server)}
+	if wal != nil {
+		opts = append(opts, admin.WithWal(wal, disk))
+	}
+	if replica != nil {
+		opts = append(opts, admin.WithReplication(replica.(admin.Replication)))
+	}
+
+	return admin.NewAdmin(engine, settings, log, opts...)
+}
@@ -6,14 +6,13 @@ import (
 
 	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
 	"github.com/DaniilZ77/InMemDB/internal/storage/replication"
+	"github.com/DaniilZ77/InMemDB/internal/tcp/server"
 
 	"github.com/DaniilZ77/InMemDB/internal/config"
-	"github.com/DaniilZ77/InMemDB/internal/tcp/server"
-	"golang.org/x/sync/errgroup"
 )
 
 func RunApp(ctx context.Context, config *config.Config) error {
-	group, groupCtx := errgroup.WithContext(ctx)
+	config = WithDefaults(config)
 
 	log, err := NewLogger(config)
 	if err != nil {
@@ -30,60 +29,99 @@ func RunApp(ctx context.Context, config *config.Config) error {
 		return fmt.Errorf("failed to init engine: %w", err)
 	}
 
-	wal, replica, err := NewWalReplica(config, log)
+	keys, err := NewKeyring(config)
+	if err != nil {
+		return fmt.Errorf("failed to init encryption: %w", err)
+	}
+
+	wal, disk, replica, err := NewWalReplica(config, keys, log)
 	if err != nil {
 		return fmt.Errorf("failed to init wal and replica: %w", err)
 	}
 
-	if _, ok := replica.(*replication.Slave); !ok && wal != nil {
-		group.Go(func() error {
-			wal.Start(groupCtx)
-			return nil
-		})
+	mainServer, err := NewServer(config, log)
+	if err != nil {
+		return fmt.Errorf("failed to init main server: %w", err)
+	}
+
+	admin, err := NewAdmin(config, engine, wal, disk, replica, mainServer, log)
+	if err != nil {
+		return fmt.Errorf("failed to init admin: %w", err)
+	}
+
+	snapshotter, err := NewSnapshotter(config, engine, disk, replica, keys, log)
+	if err != nil {
+		return fmt.Errorf("failed to init snapshots: %w", err)
 	}
 
-	database, err := NewDatabase(parser, engine, wal, replica, log)
+	database, err := NewDatabase(parser, engine, wal, replica, admin, snapshotter, recoveryWorkers(config), dumpDirectory(config), log)
 	if err != nil {
 		return fmt.Errorf("failed to init database: %w", err)
 	}
 
+	retention, err := NewRetentionPolicy(config, snapshotter != nil)
+	if err != nil {
+		return fmt.Errorf("failed to init wal retention: %w", err)
+	}
+
+	var replicaServer *server.Server
+	if _, ok := replica.(*replication.Master); ok {
+		replicaServer, err = NewReplicaServer(config, log)
+		if err != nil {
+			return fmt.Errorf("failed to init replica server: %w", err)
+		}
+	}
+
+	// WAL is replayed before anything runs, so nothing is written to it
+	// before recovery and failed startup leaves no goroutines behind.
 	err = database.Recover()
 	if err != nil {
 		return fmt.Errorf("failed to recover database: %w", err)
 	}
 
-	mainServer, err := NewServer(config, log)
-	if err != nil {
-		return fmt.Errorf("failed to init main server: %w", err)
+	lifecycle := NewLifecycle(config, log)
+
+	if !isSlave(replica) && wal != nil {
+		lifecycle.Go(phaseWal, "wal", func(ctx context.Context) error {
+			wal.Start(ctx)
+			return nil
+		})
+	}
+
+	if snapshotter != nil {
+		lifecycle.Go(phaseWal, "snapshots", func(ctx context.Context) error {
+			database.StartSnapshots(ctx, snapshotInterval(config))
+			return nil
+		})
 	}
 
-	group.Go(func() error {
-		return mainServer.Run(groupCtx, func(b []byte) ([]byte, error) {
+	if retention != nil && disk != nil {
+		lifecycle.Go(phaseWal, "wal retention", func(ctx context.Context) error {
+			disk.StartRetention(ctx, retentionInterval(config), *retention)
+			return nil
+		})
+	}
+
+	lifecycle.Go(phaseServers, "main server", func(ctx context.Context) error {
+		return mainServer.RunStreaming(ctx, func(b []byte) ([]byte, error) {
 			response := database.Execute(string(b))
 			return []byte(response), nil
+		}, func(b []byte) (server.ResponseStream, bool) {
+			return database.Stream(string(b))
 		})
 	})
 
-	var replicaServer *server.Server
 	switch r := replica.(type) {
 	case *replication.Master:
-		replicaServer, err = NewReplicaServer(config, log)
-		if err != nil {
-			return fmt.Errorf("failed to init replica server: %w", err)
-		}
-
-		group.Go(func() error {
-			return replicaServer.Run(groupCtx, func(b []byte) ([]byte, error) {
-				response, err := r.HandleRequest(b)
-				return response, err
-			})
+		r.SetCommittedLSN(wal.LSN())
+		lifecycle.Go(phaseReplication, "replica server", func(ctx context.Context) error {
+			return replicaServer.RunStream(ctx, r.Stream)
 		})
 	case *replication.Slave:
-		group.Go(func() error {
-			r.Start(groupCtx)
-			return nil
+		lifecycle.Go(phaseReplication, "slave", func(ctx context.Context) error {
+			return r.Start(ctx, wal.LSN())
 		})
 	}
 
-	return group.Wait()
+	return lifecycle.Wait(ctx)
 }
@@ -3,20 +3,51 @@ package app
 import (
 	"log/slog"
 
+	"github.com/DaniilZ77/InMemDB/internal/admin"
 	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
+	"github.com/DaniilZ77/InMemDB/internal/config"
 	"github.com/DaniilZ77/InMemDB/internal/storage"
 	"github.com/DaniilZ77/InMemDB/internal/storage/engine"
+	"github.com/DaniilZ77/InMemDB/internal/storage/snapshot"
 	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
 )
 
-func NewDatabase(parser *parser.Parser, engine *engine.Engine, wal *wal.Wal, replica any, log *slog.Logger) (database *storage.Database, err error) {
+func NewDatabase(
+	parser *parser.Parser,
+	engine *engine.Engine,
+	wal *wal.Wal,
+	replica any,
+	admin *admin.Admin,
+	snapshotter *snapshot.Snapshotter,
+	recoveryWorkers int,
+	dumpDirectory string,
+	log *slog.Logger) (database *storage.Database, err error) {
+	opts := []storage.DatabaseOption{
+		storage.WithAdmin(admin),
+		storage.WithRecoveryWorkers(recoveryWorkers),
+	}
+	if dumpDirectory != "" {
+		opts = append(opts, storage.WithDumpDirectory(dumpDirectory))
+	}
+	if snapshotter != nil {
+		opts = append(opts, storage.WithSnapshots(snapshotter))
+	}
+
 	if wal == nil {
-		return storage.NewDatabase(parser, engine, nil, nil, log)
+		return storage.NewDatabase(parser, engine, nil, nil, log, opts...)
 	}
 
 	if replica == nil {
-		return storage.NewDatabase(parser, engine, wal, nil, log)
+		return storage.NewDatabase(parser, engine, wal, nil, log, opts...)
+	}
+
+	return storage.NewDatabase(parser, engine, wal, replica.(storage.Replication), log, opts...)
+}
+
+func dumpDirectory(config *config.Config) string {
+	if config.Dump == nil {
+		return ""
 	}
 
-	return storage.NewDatabase(parser, engine, wal, replica.(storage.Replication), log)
+	return config.Dump.Directory
 }
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/config"
+)
+
+const (
+	defaultMaxRequestSize        = 8 << 20
+	defaultMaxRequestMemory      = 64 << 20
+	defaultUnixSocketPermissions = "0660"
+	defaultDrainTimeout          = 5 * time.Second
+)
+
+// WithDefaults returns copy of config with defaults the components are
+// created with, optional sections (wal, replication, snapshot, rate limit)
+// stay disabled if they are not set.
+func WithDefaults(cfg *config.Config) *config.Config {
+	effective := *cfg
+
+	engine := config.Engine{}
+	if cfg.Engine != nil {
+		engine = *cfg.Engine
+	}
+	if engine.Type == "" {
+		engine.Type = defaultEngineType
+	}
+	if engine.ShardsNumber <= 0 {
+		engine.ShardsNumber = defaultShardsNumber
+	}
+	effective.Engine = &engine
+
+	network := config.Network{}
+	if cfg.Network != nil {
+		network = *cfg.Network
+	}
+	if network.Address == "" {
+		network.Address = defaultAddress
+	}
+	if _, err := parseBytes(network.MaxMessageSize); err != nil {
+		network.MaxMessageSize = formatBytes(defaultMaxMessageSize)
+	}
+	if _, err := parseBytes(network.MaxRequestSize); err != nil {
+		network.MaxRequestSize = formatBytes(defaultMaxRequestSize)
+	}
+	if _, err := parseBytes(network.MaxRequestMemory); err != nil {
+		network.MaxRequestMemory = formatBytes(defaultMaxRequestMemory)
+	}
+	if network.UnixSocketPermissions == "" {
+		network.UnixSocketPermissions = defaultUnixSocketPermissions
+	}
+	effective.Network = &network
+
+	if cfg.Wal != nil {
+		wal := *cfg.Wal
+		if wal.FlushingBatchSize <= 0 {
+			wal.FlushingBatchSize = defaultFlushingBatchSize
+		}
+		if wal.FlushingBatchTimeout <= 0 {
+			wal.FlushingBatchTimeout = defaultFlushingBatchTimeout
+		}
+		if _, err := parseBytes(wal.MaxSegmentSize); err != nil {
+			wal.MaxSegmentSize = formatBytes(defaultMaxSegmentSize)
+		}
+		if wal.DataDirectory == "" {
+			wal.DataDirectory = defaultDataDirectory
+		}
+		if !isFsyncPolicy(wal.Fsync) {
+			wal.Fsync = string(defaultFsyncPolicy)
+		}
+		if wal.FsyncInterval <= 0 {
+			wal.FsyncInterval = defaultFsyncInterval
+		}
+		wal.RecoveryWorkers = recoveryWorkers(cfg)
+		if wal.Compression == "" {
+			wal.Compression = defaultCompression
+		}
+		if wal.MaxPendingWrites <= 0 {
+			wal.MaxPendingWrites = defaultMaxPendingWrites
+		}
+		if wal.BusyTimeout <= 0 {
+			wal.BusyTimeout = defaultBusyTimeout
+		}
+		if wal.FailureThreshold <= 0 {
+			wal.FailureThreshold = defaultFailureThreshold
+		}
+		if wal.Retention != nil {
+			retention := *wal.Retention
+			retention.Interval = retentionInterval(cfg)
+			retention.ReplicaTimeout = replicaTimeout(cfg)
+			wal.Retention = &retention
+		}
+		effective.Wal = &wal
+	}
+
+	if cfg.Replication != nil {
+		replication := *cfg.Replication
+		if !replicaTypes[replication.ReplicaType] {
+			replication.ReplicaType = defaultReplicaType
+		}
+		if replication.MasterAddress == "" {
+			replication.MasterAddress = defaultMasterAddress
+		}
+		if replication.SyncInterval <= 0 {
+			replication.SyncInterval = defaultSyncInterval
+		}
+		if replication.HeartbeatInterval <= 0 {
+			replication.HeartbeatInterval = defaultHeartbeatInterval
+		}
+		if replication.LagThreshold <= 0 {
+			replication.LagThreshold = defaultLagThreshold
+		}
+		effective.Replication = &replication
+	}
+
+	if cfg.Snapshot != nil {
+		snapshot := *cfg.Snapshot
+		if snapshot.Directory == "" {
+			snapshot.Directory = defaultSnapshotDirectory
+		}
+		if snapshot.Interval <= 0 {
+			snapshot.Interval = defaultSnapshotInterval
+		}
+		effective.Snapshot = &snapshot
+	}
+
+	shutdown := config.Shutdown{}
+	if cfg.Shutdown != nil {
+		shutdown = *cfg.Shutdown
+	}
+	if shutdown.DrainTimeout <= 0 {
+		shutdown.DrainTimeout = defaultDrainTimeout
+	}
+	if shutdown.Timeout <= 0 {
+		shutdown.Timeout = defaultShutdownTimeout
+	}
+	effective.Shutdown = &shutdown
+
+	return &effective
+}
+
+func formatBytes(size int) string {
+	for _, unit := range []struct {
+		name  string
+		shift int
+	}{{"GB", 30}, {"MB", 20}, {"KB", 10}} {
+		if size >= 1<<unit.shift && size%(1<<unit.shift) == 0 {
+			return fmt.Sprintf("%d%s", size>>unit.shift, unit.name)
+		}
+	}
+
+	return fmt.Sprintf("%dB", size)
+}
@@ -0,0 +1,16 @@
+package app
+
+import (
+	"github.com/DaniilZ77/InMemDB/internal/config"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+)
+
+// NewKeyring returns nil if encryption is not configured, keys are listed as
+// "<id>:<hex key>" separated by newlines or commas.
+func NewKeyring(config *config.Config) (*disk.Keyring, error) {
+	if config.Encryption == nil {
+		return nil, nil
+	}
+
+	return disk.LoadKeyring(config.Encryption.KeyFile, config.Encryption.KeyEnv, config.Encryption.KeyID)
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/config"
+)
+
+type phase int
+
+// Phases are stopped in declaration order: clients stop being served before
+// the wal is flushed, and replication is closed only after the wal is on disk.
+const (
+	phaseServers phase = iota
+	phaseWal
+	phaseReplication
+)
+
+var phaseNames = map[phase]string{
+	phaseServers:     "servers",
+	phaseWal:         "wal",
+	phaseReplication: "replication",
+}
+
+const defaultShutdownTimeout = 15 * time.Second
+
+var ErrShutdownTimeout = errors.New("shutdown timeout exceeded")
+
+type component struct {
+	name string
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+type Lifecycle struct {
+	components map[phase][]*component
+	errors     chan error
+	timeout    time.Duration
+	log        *slog.Logger
+}
+
+func NewLifecycle(config *config.Config, log *slog.Logger) *Lifecycle {
+	timeout := defaultShutdownTimeout
+	if config.Shutdown != nil && config.Shutdown.Timeout > 0 {
+		timeout = config.Shutdown.Timeout
+	}
+
+	return &Lifecycle{
+		components: make(map[phase][]*component),
+		errors:     make(chan error, 1),
+		timeout:    timeout,
+		log:        log,
+	}
+}
+
+func (l *Lifecycle) Go(phase phase, name string, run func(ctx context.Context) error) {
+	ctx, stop := context.WithCancel(context.Background())
+	component := &component{
+		name: name,
+		stop: stop,
+		done: make(chan struct{}),
+	}
+	l.components[phase] = append(l.components[phase], component)
+
+	go func() {
+		defer close(component.done)
+		if err := run(ctx); err != nil {
+			l.log.Error("component failed", slog.String("component", name), slog.Any("error", err))
+			select {
+			case l.errors <- err:
+			default:
+			}
+		}
+	}()
+}
+
+// Wait blocks until ctx is cancelled or any component fails, then stops all
+// components phase by phase within the shutdown timeout.
+func (l *Lifecycle) Wait(ctx context.Context) error {
+	var runErr error
+	select {
+	case <-ctx.Done():
+		l.log.Info("shutdown requested")
+	case runErr = <-l.errors:
+		l.log.Info("shutting down after component failure")
+	}
+
+	return errors.Join(runErr, l.shutdown())
+}
+
+func (l *Lifecycle) shutdown() error {
+	deadline := time.After(l.timeout)
+	for _, phase := range []phase{phaseServers, phaseWal, phaseReplication} {
+		components := l.components[phase]
+		if len(components) == 0 {
+			continue
+		}
+
+		start := time.Now()
+		l.log.Info("shutdown phase started", slog.String("phase", phaseNames[phase]))
+		for _, component := range components {
+			component.stop()
+		}
+
+		for _, component := range components {
+			select {
+			case <-component.done:
+			case <-deadline:
+				l.log.Error("shutdown timeout exceeded",
+					slog.String("phase", phaseNames[phase]),
+					slog.String("component", component.name),
+					slog.Duration("timeout", l.timeout),
+				)
+				return ErrShutdownTimeout
+			}
+		}
+		l.log.Info("shutdown phase finished",
+			slog.String("phase", phaseNames[phase]),
+			slog.Duration("elapsed", time.Since(start)),
+		)
+	}
+
+	l.log.Info("shutdown completed")
+	return nil
+}
@@ -0,0 +1,227 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
+	"github.com/DaniilZ77/InMemDB/internal/config"
+	"github.com/DaniilZ77/InMemDB/internal/storage"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+	"github.com/DaniilZ77/InMemDB/internal/storage/snapshot"
+	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
+)
+
+const (
+	restoredWalDirectory      = "wal"
+	restoredSnapshotDirectory = "snapshots"
+)
+
+// ParseRecoveryTarget returns target for the last lsn to restore and the time
+// in RFC3339 to restore state as of, negative lsn and empty time are unset.
+func ParseRecoveryTarget(lsn int, at string) (wal.RecoveryTarget, error) {
+	var target wal.RecoveryTarget
+	if lsn >= 0 {
+		target.StopLSN = lsn + 1
+	}
+	if at != "" {
+		stopTime, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return target, fmt.Errorf("invalid restore time: %w", err)
+		}
+		target.StopTime = stopTime
+	}
+
+	if target.IsZero() {
+		return target, errors.New("restore lsn or time must be set")
+	}
+
+	return target, nil
+}
+
+// RestoreToPoint replays the snapshot and WAL of the node till the target and
+// saves the result as a snapshot in the fresh directory, the node is started
+// from it with wal.data_directory and snapshot.directory set to its "wal" and
+// "snapshots" subdirectories. The node must be stopped, torn tail of its WAL
+// is truncated as on recovery.
+func RestoreToPoint(config *config.Config, target wal.RecoveryTarget, directory string) error {
+	config = WithDefaults(config)
+
+	log, err := NewLogger(config)
+	if err != nil {
+		return err
+	}
+
+	if config.Wal == nil {
+		return errors.New("wal is disabled")
+	}
+	if err := checkEmptyDirectory(directory); err != nil {
+		return err
+	}
+
+	parser, err := parser.NewParser(log)
+	if err != nil {
+		return fmt.Errorf("failed to init parser: %w", err)
+	}
+
+	engine, err := NewEngine(config)
+	if err != nil {
+		return fmt.Errorf("failed to init engine: %w", err)
+	}
+
+	maxSegmentSize, err := parseBytes(config.Wal.MaxSegmentSize)
+	if err != nil {
+		return err
+	}
+
+	keys, err := NewKeyring(config)
+	if err != nil {
+		return fmt.Errorf("failed to init encryption: %w", err)
+	}
+
+	sourceDisk := disk.NewDisk(config.Wal.DataDirectory, maxSegmentSize, log, disk.WithKeyring(keys))
+	logsManager := wal.NewLogsManager(sourceDisk, log)
+	sourceWal, err := wal.NewWal(config.Wal.FlushingBatchSize, config.Wal.FlushingBatchTimeout, logsManager, logsManager, log)
+	if err != nil {
+		return err
+	}
+
+	opts := []storage.DatabaseOption{storage.WithRecoveryWorkers(recoveryWorkers(config))}
+	if config.Snapshot != nil {
+		snapshots, err := snapshot.NewSnapshotter(config.Snapshot.Directory, engine, sourceDisk, log, snapshot.WithKeyring(keys))
+		if err != nil {
+			return fmt.Errorf("failed to init snapshots: %w", err)
+		}
+		opts = append(opts, storage.WithSnapshots(snapshots))
+	}
+
+	database, err := storage.NewDatabase(parser, engine, sourceWal, nil, log, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to init database: %w", err)
+	}
+
+	if err := database.RecoverTo(target); err != nil {
+		return fmt.Errorf("failed to recover database: %w", err)
+	}
+
+	restoredDisk := disk.NewDisk(filepath.Join(directory, restoredWalDirectory), maxSegmentSize, log, disk.WithKeyring(keys))
+	restored, err := snapshot.NewSnapshotter(filepath.Join(directory, restoredSnapshotDirectory), engine, restoredDisk, log, snapshot.WithKeyring(keys))
+	if err != nil {
+		return fmt.Errorf("failed to init restored snapshots: %w", err)
+	}
+
+	if err := restored.Create(sourceWal.LSN()); err != nil {
+		return fmt.Errorf("failed to save restored state: %w", err)
+	}
+
+	log.Info("restored data directory",
+		slog.String("directory", directory),
+		slog.Int("lsn", sourceWal.LSN()),
+	)
+
+	return nil
+}
+
+func checkEmptyDirectory(directory string) error {
+	entries, err := os.ReadDir(directory)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("restore directory %s is not empty", directory)
+	}
+
+	return nil
+}
+
+// ImportDump loads the dump into the data directory of the stopped node, its
+// entries are saved in WAL as by RESTORE, so the node must be empty.
+func ImportDump(config *config.Config, path string) error {
+	config = WithDefaults(config)
+
+	log, err := NewLogger(config)
+	if err != nil {
+		return err
+	}
+
+	if config.Wal == nil {
+		return errors.New("wal is disabled")
+	}
+
+	parser, err := parser.NewParser(log)
+	if err != nil {
+		return fmt.Errorf("failed to init parser: %w", err)
+	}
+
+	engine, err := NewEngine(config)
+	if err != nil {
+		return fmt.Errorf("failed to init engine: %w", err)
+	}
+
+	keys, err := NewKeyring(config)
+	if err != nil {
+		return fmt.Errorf("failed to init encryption: %w", err)
+	}
+
+	wal, disk, replica, err := NewWalReplica(config, keys, log)
+	if err != nil {
+		return fmt.Errorf("failed to init wal: %w", err)
+	}
+	if isSlave(replica) {
+		return errors.New("dump can't be imported into replica")
+	}
+
+	opts := []storage.DatabaseOption{storage.WithRecoveryWorkers(recoveryWorkers(config))}
+	snapshotter, err := NewSnapshotter(config, engine, disk, replica, keys, log)
+	if err != nil {
+		return fmt.Errorf("failed to init snapshots: %w", err)
+	}
+	if snapshotter != nil {
+		opts = append(opts, storage.WithSnapshots(snapshotter))
+	}
+
+	database, err := storage.NewDatabase(parser, engine, wal, nil, log, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to init database: %w", err)
+	}
+
+	if err := database.Recover(); err != nil {
+		return fmt.Errorf("failed to recover database: %w", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close() // nolint
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		wal.Start(ctx)
+	}()
+
+	imported, err := database.Restore(file)
+	cancel()
+	<-stopped
+	if err != nil {
+		return fmt.Errorf("failed to import dump (imported %d keys): %w", imported, err)
+	}
+
+	log.Info("dump imported",
+		slog.String("path", path),
+		slog.Int("keys", imported),
+		slog.Int("lsn", wal.LSN()),
+	)
+
+	return nil
+}
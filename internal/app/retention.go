@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/config"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+)
+
+const (
+	defaultRetentionInterval = time.Minute
+	defaultReplicaTimeout    = time.Minute
+)
+
+// NewRetentionPolicy returns nil if retention is not configured. Segments not
+// covered by snapshot are kept if snapshots are taken.
+func NewRetentionPolicy(config *config.Config, snapshots bool) (*disk.RetentionPolicy, error) {
+	if config.Wal == nil || config.Wal.Retention == nil {
+		return nil, nil
+	}
+	retention := config.Wal.Retention
+
+	policy := &disk.RetentionPolicy{
+		MaxAge:      retention.MaxAge,
+		MinSegments: retention.MinSegments,
+		Snapshots:   snapshots,
+	}
+
+	var err error
+	if retention.MaxSize != "" {
+		if policy.MaxSize, err = parseBytes(retention.MaxSize); err != nil {
+			return nil, fmt.Errorf("invalid wal retention max size %q", retention.MaxSize)
+		}
+	}
+	if retention.HardMaxSize != "" {
+		if policy.HardMaxSize, err = parseBytes(retention.HardMaxSize); err != nil {
+			return nil, fmt.Errorf("invalid wal retention hard max size %q", retention.HardMaxSize)
+		}
+	}
+	if policy.HardMaxSize > 0 && policy.HardMaxSize < policy.MaxSize {
+		return nil, fmt.Errorf("wal retention hard max size %s is less than max size %s", retention.HardMaxSize, retention.MaxSize)
+	}
+
+	return policy, nil
+}
+
+func retentionInterval(config *config.Config) time.Duration {
+	if config.Wal == nil || config.Wal.Retention == nil || config.Wal.Retention.Interval <= 0 {
+		return defaultRetentionInterval
+	}
+
+	return config.Wal.Retention.Interval
+}
+
+func replicaTimeout(config *config.Config) time.Duration {
+	if config.Wal == nil || config.Wal.Retention == nil || config.Wal.Retention.ReplicaTimeout <= 0 {
+		return defaultReplicaTimeout
+	}
+
+	return config.Wal.Retention.ReplicaTimeout
+}
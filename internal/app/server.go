@@ -1,8 +1,13 @@
 package app
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
+	"strconv"
 
+	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
 	"github.com/DaniilZ77/InMemDB/internal/config"
 	"github.com/DaniilZ77/InMemDB/internal/tcp/server"
 )
@@ -26,12 +31,43 @@ func NewServer(config *config.Config, log *slog.Logger) (*server.Server, error)
 			maxMessageSize = defaultMaxMessageSize
 		}
 		opts = append(opts, server.WithMaxMessageSize(maxMessageSize))
+		if config.Network.MaxRequestSize != "" {
+			maxRequestSize, err := parseBytes(config.Network.MaxRequestSize)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max request size %q", config.Network.MaxRequestSize)
+			}
+			opts = append(opts, server.WithMaxRequestSize(maxRequestSize))
+		}
+		if config.Network.MaxRequestMemory != "" {
+			maxRequestMemory, err := parseBytes(config.Network.MaxRequestMemory)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max request memory %q", config.Network.MaxRequestMemory)
+			}
+			opts = append(opts, server.WithMaxRequestMemory(maxRequestMemory))
+		}
 		if config.Network.IdleTimeout > 0 {
 			opts = append(opts, server.WithIdleTimeout(config.Network.IdleTimeout))
 		}
 		if config.Network.MaxConnections > 0 {
 			opts = append(opts, server.WithMaxConnections(config.Network.MaxConnections))
 		}
+		if len(config.Network.Listeners) > 0 {
+			opts = append(opts, server.WithListeners(config.Network.Listeners...))
+		}
+		if config.Network.UnixSocketPermissions != "" {
+			permissions, err := strconv.ParseUint(config.Network.UnixSocketPermissions, 8, 32)
+			if err != nil {
+				return nil, errors.New("invalid unix socket permissions")
+			}
+			opts = append(opts, server.WithSocketPermissions(fs.FileMode(permissions)))
+		}
+	}
+
+	if config.Shutdown != nil && config.Shutdown.DrainTimeout > 0 {
+		opts = append(opts, server.WithDrainTimeout(config.Shutdown.DrainTimeout))
+	}
+	if config.RateLimit != nil {
+		opts = append(opts, server.WithRateLimiter(NewRateLimiter(config.RateLimit)))
 	}
 
 	server, err := server.NewServer(address, maxMessageSize, log, opts...)
@@ -42,6 +78,40 @@ func NewServer(config *config.Config, log *slog.Logger) (*server.Server, error)
 	return server, nil
 }
 
+const (
+	readClass  = "read"
+	writeClass = "write"
+)
+
+func NewRateLimiter(config *config.RateLimit) *server.RateLimiter {
+	clients := make(map[string]server.RateLimits, len(config.Clients))
+	for identity, limits := range config.Clients {
+		clients[identity] = rateLimits(limits)
+	}
+
+	return server.NewRateLimiter(classifyRequest, rateLimits(config.Default), clients)
+}
+
+func rateLimits(config config.RateLimitClasses) server.RateLimits {
+	limits := server.RateLimits{}
+	if config.Read != nil {
+		limits[readClass] = server.Limit{Rate: config.Read.Rate, Burst: config.Read.Burst}
+	}
+	if config.Write != nil {
+		limits[writeClass] = server.Limit{Rate: config.Write.Rate, Burst: config.Write.Burst}
+	}
+
+	return limits
+}
+
+func classifyRequest(request []byte) string {
+	if commandType, ok := parser.Peek(request); ok && commandType.IsWrite() {
+		return writeClass
+	}
+
+	return readClass
+}
+
 func NewReplicaServer(config *config.Config, log *slog.Logger) (*server.Server, error) {
 	masterAddress := defaultMasterAddress
 	if config.Replication.MasterAddress != "" {
@@ -53,7 +123,12 @@ func NewReplicaServer(config *config.Config, log *slog.Logger) (*server.Server,
 		maxMessageSize = defaultMaxMessageSize
 	}
 
-	server, err := server.NewServer(masterAddress, maxMessageSize, log)
+	opts := []server.ServerOption{}
+	if config.Shutdown != nil && config.Shutdown.DrainTimeout > 0 {
+		opts = append(opts, server.WithDrainTimeout(config.Shutdown.DrainTimeout))
+	}
+
+	server, err := server.NewServer(masterAddress, maxMessageSize, log, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/config"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+	"github.com/DaniilZ77/InMemDB/internal/storage/engine"
+	"github.com/DaniilZ77/InMemDB/internal/storage/snapshot"
+)
+
+const (
+	defaultSnapshotDirectory = "./data/snapshots"
+	defaultSnapshotInterval  = 5 * time.Minute
+)
+
+// NewSnapshotter returns nil if snapshots are not configured, they are taken
+// only by the node writing its own WAL.
+func NewSnapshotter(config *config.Config, engine *engine.Engine, disk *disk.Disk, replica any, keys *disk.Keyring, log *slog.Logger) (*snapshot.Snapshotter, error) {
+	if config.Snapshot == nil || disk == nil || isSlave(replica) {
+		return nil, nil
+	}
+
+	directory := defaultSnapshotDirectory
+	if config.Snapshot.Directory != "" {
+		directory = config.Snapshot.Directory
+	}
+
+	return snapshot.NewSnapshotter(directory, engine, disk, log,
+		snapshot.WithKeyring(keys),
+		snapshot.WithRetentionWindow(config.Snapshot.RetentionWindow))
+}
+
+func snapshotInterval(config *config.Config) time.Duration {
+	if config.Snapshot == nil || config.Snapshot.Interval <= 0 {
+		return defaultSnapshotInterval
+	}
+
+	return config.Snapshot.Interval
+}
@@ -1,7 +1,10 @@
 package app
 
 import (
+	"fmt"
 	"log/slog"
+	"path/filepath"
+	"runtime"
 	"time"
 
 	"github.com/DaniilZ77/InMemDB/internal/config"
@@ -16,12 +19,24 @@ const (
 	defaultFlushingBatchTimeout = 10 * time.Millisecond
 	defaultFlushingBatchSize    = 100
 	defaultDataDirectory        = "./data/wal"
+	defaultFsyncPolicy          = wal.FsyncGroup
+	defaultFsyncInterval        = time.Second
+	defaultCompression          = "none"
+	defaultMaxPendingWrites     = 10000
+	defaultBusyTimeout          = 100 * time.Millisecond
+	defaultFailureThreshold     = 3
 	slave                       = "slave"
 	master                      = "master"
 	defaultReplicaType          = master
 	defaultMasterAddress        = ":3232"
 	defaultSyncInterval         = time.Second
-	defaultIdleTimeout          = time.Second
+	defaultHeartbeatInterval    = time.Second
+	defaultLagThreshold         = 10 * time.Second
+	heartbeatsMissed            = 3
+	maxReplicationMessageSize   = 1 << 30
+	// replicaIDFile keeps generated replica id in WAL data directory, it's
+	// hidden so that it isn't taken for a segment.
+	replicaIDFile = ".replica_id"
 )
 
 var replicaTypes = map[string]bool{
@@ -29,14 +44,35 @@ var replicaTypes = map[string]bool{
 	master: true,
 }
 
-func NewWalReplica(config *config.Config, log *slog.Logger) (*wal.Wal, any, error) {
+func isSlave(replica any) bool {
+	_, ok := replica.(*replication.Slave)
+	return ok
+}
+
+func isFsyncPolicy(policy string) bool {
+	return wal.FsyncPolicy(policy).IsValid()
+}
+
+// recoveryWorkers returns amount of goroutines WAL is replayed with, by
+// default one per available CPU.
+func recoveryWorkers(config *config.Config) int {
+	if config.Wal == nil || config.Wal.RecoveryWorkers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+
+	return config.Wal.RecoveryWorkers
+}
+
+func NewWalReplica(config *config.Config, keys *disk.Keyring, log *slog.Logger) (*wal.Wal, *disk.Disk, any, error) {
 	if config.Wal == nil {
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 
 	replicaType := defaultReplicaType
 	masterAddress := defaultMasterAddress
 	syncInterval := defaultSyncInterval
+	heartbeatInterval := defaultHeartbeatInterval
+	lagThreshold := defaultLagThreshold
 	if config.Replication != nil {
 		if replicaTypes[config.Replication.ReplicaType] {
 			replicaType = config.Replication.ReplicaType
@@ -47,6 +83,12 @@ func NewWalReplica(config *config.Config, log *slog.Logger) (*wal.Wal, any, erro
 		if config.Replication.SyncInterval > 0 {
 			syncInterval = config.Replication.SyncInterval
 		}
+		if config.Replication.HeartbeatInterval > 0 {
+			heartbeatInterval = config.Replication.HeartbeatInterval
+		}
+		if config.Replication.LagThreshold > 0 {
+			lagThreshold = config.Replication.LagThreshold
+		}
 	}
 
 	flushingBatchTimeout := defaultFlushingBatchTimeout
@@ -61,42 +103,119 @@ func NewWalReplica(config *config.Config, log *slog.Logger) (*wal.Wal, any, erro
 	if config.Wal.DataDirectory != "" {
 		dataDirectory = config.Wal.DataDirectory
 	}
+	fsyncPolicy := defaultFsyncPolicy
+	fsyncInterval := defaultFsyncInterval
+	if isFsyncPolicy(config.Wal.Fsync) {
+		fsyncPolicy = wal.FsyncPolicy(config.Wal.Fsync)
+	}
+	if config.Wal.FsyncInterval > 0 {
+		fsyncInterval = config.Wal.FsyncInterval
+	}
+
+	maxPendingWrites := defaultMaxPendingWrites
+	busyTimeout := defaultBusyTimeout
+	if config.Wal.MaxPendingWrites > 0 {
+		maxPendingWrites = config.Wal.MaxPendingWrites
+	}
+	if config.Wal.BusyTimeout > 0 {
+		busyTimeout = config.Wal.BusyTimeout
+	}
+	failureThreshold := defaultFailureThreshold
+	if config.Wal.FailureThreshold > 0 {
+		failureThreshold = config.Wal.FailureThreshold
+	}
 
 	maxSegmentSize, err := parseBytes(config.Wal.MaxSegmentSize)
 	if err != nil {
 		maxSegmentSize = defaultMaxSegmentSize
 	}
 
-	disk := disk.NewDisk(dataDirectory, maxSegmentSize, log)
+	codec, _ := disk.ParseCodec(defaultCompression)
+	if config.Wal.Compression != "" {
+		var ok bool
+		if codec, ok = disk.ParseCodec(config.Wal.Compression); !ok {
+			return nil, nil, nil, fmt.Errorf("invalid wal compression %q", config.Wal.Compression)
+		}
+	}
+
+	disk := disk.NewDisk(
+		dataDirectory,
+		maxSegmentSize,
+		log,
+		disk.WithCompression(codec),
+		disk.WithKeyring(keys),
+		disk.WithPreallocation(config.Wal.Preallocate),
+	)
 	logsManager := wal.NewLogsManager(disk, log)
 
-	wal, err := wal.NewWal(flushingBatchSize, flushingBatchTimeout, logsManager, logsManager, log)
+	// Master streams batches to replicas as soon as they are committed.
+	var replicationMaster *replication.Master
+	if config.Replication != nil && replicaType == master {
+		replicationMaster, err = replication.NewMaster(
+			logsManager,
+			log,
+			replication.WithReplicaTimeout(replicaTimeout(config)),
+			replication.WithHeartbeatInterval(heartbeatInterval),
+			replication.WithMasterKeyring(keys),
+			replication.WithMasterCompression(codec),
+			replication.WithMasterLagThreshold(lagThreshold),
+		)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		disk.TrackReplicas(replicationMaster)
+	}
+
+	walOpts := []wal.WalOption{
+		wal.WithFsyncPolicy(fsyncPolicy),
+		wal.WithFsyncInterval(fsyncInterval),
+		wal.WithMaxPendingWrites(maxPendingWrites),
+		wal.WithBusyTimeout(busyTimeout),
+		wal.WithWriteTimeout(config.Wal.WriteTimeout),
+		wal.WithFailureThreshold(failureThreshold),
+		wal.WithAdaptiveBatching(config.Wal.AdaptiveBatching),
+	}
+	if replicationMaster != nil {
+		walOpts = append(walOpts, wal.WithCommitHook(replicationMaster.Publish))
+	}
+
+	wal, err := wal.NewWal(
+		flushingBatchSize,
+		flushingBatchTimeout,
+		logsManager,
+		logsManager,
+		log,
+		walOpts...,
+	)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	if config.Replication == nil {
-		return wal, nil, nil
+		return wal, disk, nil, nil
+	}
+
+	if replicationMaster != nil {
+		return wal, disk, replicationMaster, nil
 	}
 
-	var replica any
-	switch replicaType {
-	case master:
-		replica, err = replication.NewMaster(disk, dataDirectory, log)
-		return wal, replica, err
-	case slave:
-		client, err := NewClient(
+	// Slave reconnects if master sends nothing, not even heartbeats.
+	dial := func() (replication.Client, error) {
+		return client.NewClient(
 			masterAddress,
-			log,
-			client.WithBufferSize(2*maxSegmentSize),
-			client.WithIdleTimeout(defaultIdleTimeout),
+			client.WithMaxMessageSize(maxReplicationMessageSize),
+			client.WithIdleTimeout(heartbeatsMissed*heartbeatInterval),
 		)
-		if err != nil {
-			return nil, nil, err
-		}
-		replica, err := replication.NewSlave(syncInterval, client, disk, log)
-		return wal, replica, err
 	}
-
-	panic("unreachable")
+	replica, err := replication.NewSlave(
+		syncInterval,
+		dial,
+		logsManager,
+		log,
+		replication.WithKeyring(keys),
+		replication.WithReplicaID(config.Replication.ReplicaID),
+		replication.WithReplicaIDFile(filepath.Join(dataDirectory, replicaIDFile)),
+		replication.WithLagThreshold(lagThreshold),
+	)
+	return wal, disk, replica, err
 }
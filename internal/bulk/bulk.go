@@ -0,0 +1,225 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/dump"
+)
+
+const (
+	defaultBatchSize    = 100
+	exportProgressEvery = 10000
+	// maxReportedErrors limits invalid records reported by dry run, all of
+	// them are counted.
+	maxReportedErrors = 20
+
+	errorPrefix = "ERROR("
+)
+
+var (
+	ErrInvalidRecord = errors.New("invalid record")
+	ErrRejected      = errors.New("request rejected")
+)
+
+//go:generate mockery --name=Sender --case=snake --inpackage --inpackage-suffix --with-expecter
+type Sender interface {
+	Send(request []byte) ([]byte, error)
+}
+
+//go:generate mockery --name=Streamer --case=snake --inpackage --inpackage-suffix --with-expecter
+type Streamer interface {
+	Stream(request []byte) (io.Reader, error)
+}
+
+// Stats describes records processed so far: set or exported ones, valid ones
+// for dry run. Batches is amount of sent requests.
+type Stats struct {
+	Records int
+	Invalid int
+	Batches int
+}
+
+type importer struct {
+	batchSize int
+	dryRun    bool
+	progress  func(Stats)
+}
+
+type ImportOption func(*importer)
+
+// WithBatchSize sets amount of records set by one MSET request.
+func WithBatchSize(batchSize int) ImportOption {
+	return func(i *importer) {
+		if batchSize > 0 {
+			i.batchSize = batchSize
+		}
+	}
+}
+
+// WithDryRun only validates records, nothing is sent and invalid records
+// don't stop the import.
+func WithDryRun(dryRun bool) ImportOption {
+	return func(i *importer) {
+		i.dryRun = dryRun
+	}
+}
+
+// WithProgress sets function called after every batch.
+func WithProgress(progress func(Stats)) ImportOption {
+	return func(i *importer) {
+		i.progress = progress
+	}
+}
+
+// Import sets the records by MSET requests. It stops at the first invalid
+// record, records of the batches sent before it stay set.
+func Import(reader Reader, sender Sender, opts ...ImportOption) (Stats, error) {
+	i := &importer{batchSize: defaultBatchSize}
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	var stats Stats
+	var invalid []error
+	batch := make([]Record, 0, i.batchSize)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil {
+			err = Validate(record)
+		}
+		if err != nil {
+			if !i.dryRun {
+				return stats, err
+			}
+			stats.Invalid++
+			if len(invalid) < maxReportedErrors {
+				invalid = append(invalid, err)
+			}
+			if !errors.Is(err, ErrInvalidRecord) {
+				// the reader can't go on after failure of the input
+				break
+			}
+			continue
+		}
+
+		if i.dryRun {
+			stats.Records++
+			continue
+		}
+
+		batch = append(batch, record)
+		if len(batch) == i.batchSize {
+			if err := i.send(sender, batch, &stats); err != nil {
+				return stats, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := i.send(sender, batch, &stats); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, errors.Join(invalid...)
+}
+
+func (i *importer) send(sender Sender, batch []Record, stats *Stats) error {
+	var request bytes.Buffer
+	request.WriteString("MSET")
+	for _, record := range batch {
+		request.WriteByte(' ')
+		request.WriteString(record.Key)
+		request.WriteByte(' ')
+		request.WriteString(record.Value)
+	}
+
+	response, err := sender.Send(request.Bytes())
+	if err != nil {
+		return err
+	}
+	if string(response) != "OK" {
+		return fmt.Errorf("%w: records from line %d: %s", ErrRejected, batch[0].Line, response)
+	}
+
+	stats.Records += len(batch)
+	stats.Batches++
+	if i.progress != nil {
+		i.progress(*stats)
+	}
+
+	return nil
+}
+
+// Validate checks that key and value are single tokens of the query.
+func Validate(record Record) error {
+	switch {
+	case record.Key == "":
+		return fmt.Errorf("%w: line %d: empty key", ErrInvalidRecord, record.Line)
+	case record.Value == "":
+		return fmt.Errorf("%w: line %d: empty value", ErrInvalidRecord, record.Line)
+	case strings.IndexFunc(record.Key, unicode.IsSpace) >= 0:
+		return fmt.Errorf("%w: line %d: key contains whitespace", ErrInvalidRecord, record.Line)
+	case strings.IndexFunc(record.Value, unicode.IsSpace) >= 0:
+		return fmt.Errorf("%w: line %d: value contains whitespace", ErrInvalidRecord, record.Line)
+	}
+
+	return nil
+}
+
+// Export writes keys with the prefix, they are filtered by DUMP PREFIX on the
+// server and written as the dump arrives. Progress is called every
+// exportProgressEvery written records, it may be nil.
+func Export(streamer Streamer, writer Writer, prefix string, progress func(Stats)) (Stats, error) {
+	var stats Stats
+
+	request := "DUMP"
+	if prefix != "" {
+		if strings.IndexFunc(prefix, unicode.IsSpace) >= 0 {
+			return stats, errors.New("prefix contains whitespace")
+		}
+		request += " PREFIX " + prefix
+	}
+
+	response, err := streamer.Stream([]byte(request))
+	if err != nil {
+		return stats, err
+	}
+
+	buffered := bufio.NewReader(response)
+	if head, _ := buffered.Peek(len(errorPrefix)); string(head) == errorPrefix {
+		message, err := io.ReadAll(buffered)
+		if err != nil {
+			return stats, err
+		}
+		return stats, fmt.Errorf("%w: %s", ErrRejected, message)
+	}
+	stats.Batches++
+
+	_, err = dump.Read(buffered, func(entry dump.Entry) error {
+		if err := writer.Write(Record{Key: entry.Key, Value: entry.Value}); err != nil {
+			return err
+		}
+
+		stats.Records++
+		if progress != nil && stats.Records%exportProgressEvery == 0 {
+			progress(stats)
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	return stats, writer.Flush()
+}
@@ -0,0 +1,117 @@
+package bulk
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/dump"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	reader, err := NewReader(strings.NewReader("key,value\na,1\nb,2\nc,3\n"), CSV)
+	require.NoError(t, err)
+
+	sender := NewMockSender(t)
+	sender.EXPECT().Send([]byte("MSET a 1 b 2")).Return([]byte("OK"), nil).Once()
+	sender.EXPECT().Send([]byte("MSET c 3")).Return([]byte("OK"), nil).Once()
+
+	var progress []Stats
+	stats, err := Import(reader, sender, WithBatchSize(2), WithProgress(func(stats Stats) {
+		progress = append(progress, stats)
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, Stats{Records: 3, Batches: 2}, stats)
+	assert.Equal(t, []Stats{{Records: 2, Batches: 1}, {Records: 3, Batches: 2}}, progress)
+}
+
+func TestImport_Rejected(t *testing.T) {
+	t.Parallel()
+
+	reader, err := NewReader(strings.NewReader(`{"key":"a","value":"1"}`+"\n"), JSONLines)
+	require.NoError(t, err)
+
+	sender := NewMockSender(t)
+	sender.EXPECT().Send([]byte("MSET a 1")).Return([]byte("ERROR(read-only mode)"), nil).Once()
+
+	_, err = Import(reader, sender)
+	assert.ErrorIs(t, err, ErrRejected)
+	assert.ErrorContains(t, err, "read-only mode")
+}
+
+func TestImport_InvalidRecord(t *testing.T) {
+	t.Parallel()
+
+	reader, err := NewReader(strings.NewReader("a,1\nb,two words\nc,3\n"), CSV)
+	require.NoError(t, err)
+
+	stats, err := Import(reader, NewMockSender(t))
+	assert.ErrorIs(t, err, ErrInvalidRecord)
+	assert.ErrorContains(t, err, "line 2")
+	assert.Equal(t, Stats{}, stats)
+}
+
+func TestImport_DryRun(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		`{"key":"a","value":"1"}`,
+		`{"key":"","value":"2"}`,
+		`{"key":"c"`,
+		`{"key":"d","value":"4"}`,
+	}, "\n")
+	reader, err := NewReader(strings.NewReader(input), JSONLines)
+	require.NoError(t, err)
+
+	stats, err := Import(reader, nil, WithDryRun(true))
+	assert.ErrorIs(t, err, ErrInvalidRecord)
+	assert.ErrorContains(t, err, "line 2: empty key")
+	assert.ErrorContains(t, err, "line 3")
+	assert.Equal(t, Stats{Records: 2, Invalid: 2}, stats)
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	var response bytes.Buffer
+	dumpWriter, err := dump.NewWriter(&response, time.Now(), 3)
+	require.NoError(t, err)
+	require.NoError(t, dumpWriter.Write("user:1", "Daniil"))
+	require.NoError(t, dumpWriter.Write("user:2", "Ivan"))
+	require.NoError(t, dumpWriter.Close())
+
+	streamer := NewMockStreamer(t)
+	streamer.EXPECT().Stream([]byte("DUMP PREFIX user:")).Return(&response, nil).Once()
+
+	var output bytes.Buffer
+	writer, err := NewWriter(&output, CSV)
+	require.NoError(t, err)
+
+	stats, err := Export(streamer, writer, "user:", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, Stats{Records: 2, Batches: 1}, stats)
+	assert.Equal(t, "key,value\nuser:1,Daniil\nuser:2,Ivan\n", output.String())
+}
+
+func TestExport_Rejected(t *testing.T) {
+	t.Parallel()
+
+	streamer := NewMockStreamer(t)
+	streamer.EXPECT().Stream([]byte("DUMP")).Return(strings.NewReader("ERROR(internal error)"), nil).Once()
+
+	writer, err := NewWriter(&bytes.Buffer{}, JSONLines)
+	require.NoError(t, err)
+
+	_, err = Export(streamer, writer, "", nil)
+	assert.ErrorIs(t, err, ErrRejected)
+
+	_, err = Export(streamer, writer, "user 1", nil)
+	assert.Error(t, err, "prefix must be a single token")
+}
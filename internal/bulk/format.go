@@ -0,0 +1,178 @@
+package bulk
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+type Format string
+
+const (
+	CSV       Format = "csv"
+	JSONLines Format = "jsonl"
+)
+
+const (
+	csvKey   = "key"
+	csvValue = "value"
+	// maxLineLen is max request size of the server by default.
+	maxLineLen = 512 << 20
+)
+
+var ErrUnknownFormat = errors.New("unknown format")
+
+// ParseFormat returns the format by its name or, if the name is empty, by
+// extension of the file.
+func ParseFormat(name, path string) (Format, error) {
+	if name == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			return CSV, nil
+		case ".jsonl", ".ndjson":
+			return JSONLines, nil
+		}
+		return "", fmt.Errorf("%w: set format of %q explicitly", ErrUnknownFormat, path)
+	}
+
+	switch format := Format(strings.ToLower(name)); format {
+	case CSV, JSONLines:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("%w %q", ErrUnknownFormat, name)
+}
+
+// Record is a key with its value. In CSV it's a row of two columns, the
+// first row may be the "key,value" header. In JSON lines it's an object with
+// "key" and "value" fields, other fields are ignored.
+type Record struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Line is the number of the line the record is read from.
+	Line int `json:"-"`
+}
+
+// Reader returns records one by one and io.EOF after the last one.
+type Reader interface {
+	Read() (Record, error)
+}
+
+type Writer interface {
+	Write(record Record) error
+	Flush() error
+}
+
+func NewReader(reader io.Reader, format Format) (Reader, error) {
+	switch format {
+	case CSV:
+		csvReader := csv.NewReader(reader)
+		csvReader.FieldsPerRecord = 2
+		csvReader.ReuseRecord = true
+		return &csvRecordReader{reader: csvReader}, nil
+	case JSONLines:
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(nil, maxLineLen)
+		return &jsonRecordReader{scanner: scanner}, nil
+	}
+
+	return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+func NewWriter(writer io.Writer, format Format) (Writer, error) {
+	switch format {
+	case CSV:
+		csvWriter := csv.NewWriter(writer)
+		if err := csvWriter.Write([]string{csvKey, csvValue}); err != nil {
+			return nil, err
+		}
+		return &csvRecordWriter{writer: csvWriter}, nil
+	case JSONLines:
+		buffered := bufio.NewWriter(writer)
+		return &jsonRecordWriter{writer: buffered, encoder: json.NewEncoder(buffered)}, nil
+	}
+
+	return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+type csvRecordReader struct {
+	reader *csv.Reader
+	read   bool
+}
+
+func (r *csvRecordReader) Read() (Record, error) {
+	for {
+		fields, err := r.reader.Read()
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return Record{}, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+		}
+		if err != nil {
+			return Record{}, err
+		}
+		line, _ := r.reader.FieldPos(0)
+
+		header := !r.read && fields[0] == csvKey && fields[1] == csvValue
+		r.read = true
+		if !header {
+			return Record{Key: fields[0], Value: fields[1], Line: line}, nil
+		}
+	}
+}
+
+type jsonRecordReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *jsonRecordReader) Read() (Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		if len(strings.TrimSpace(r.scanner.Text())) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(r.scanner.Bytes(), &record); err != nil {
+			return Record{}, fmt.Errorf("%w: line %d: %w", ErrInvalidRecord, r.line, err)
+		}
+		record.Line = r.line
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, err
+	}
+
+	return Record{}, io.EOF
+}
+
+type csvRecordWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvRecordWriter) Write(record Record) error {
+	return w.writer.Write([]string{record.Key, record.Value})
+}
+
+func (w *csvRecordWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type jsonRecordWriter struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (w *jsonRecordWriter) Write(record Record) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *jsonRecordWriter) Flush() error {
+	return w.writer.Flush()
+}
@@ -0,0 +1,92 @@
+package bulk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		format   string
+		path     string
+		expected Format
+		err      error
+	}{
+		{name: "csv by extension", path: "data.CSV", expected: CSV},
+		{name: "jsonl by extension", path: "data.ndjson", expected: JSONLines},
+		{name: "explicit format", format: "JSONL", path: "data.txt", expected: JSONLines},
+		{name: "unknown extension", path: "data.txt", err: ErrUnknownFormat},
+		{name: "unknown format", format: "xml", err: ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := ParseFormat(tt.format, tt.path)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.expected, format)
+		})
+	}
+}
+
+func TestFormat_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	records := []Record{
+		{Key: "name", Value: "Daniil"},
+		{Key: "quoted", Value: `"a,b"`},
+	}
+
+	for _, format := range []Format{CSV, JSONLines} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := NewWriter(&buf, format)
+			require.NoError(t, err)
+			for _, record := range records {
+				require.NoError(t, writer.Write(record))
+			}
+			require.NoError(t, writer.Flush())
+
+			reader, err := NewReader(&buf, format)
+			require.NoError(t, err)
+
+			var read []Record
+			for {
+				record, err := reader.Read()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				record.Line = 0
+				read = append(read, record)
+			}
+
+			assert.Equal(t, records, read)
+		})
+	}
+}
+
+func TestReader_CSVLines(t *testing.T) {
+	t.Parallel()
+
+	reader, err := NewReader(bytes.NewBufferString("key,value\na,1\nb\nc,3\n"), CSV)
+	require.NoError(t, err)
+
+	record, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, Record{Key: "a", Value: "1", Line: 2}, record)
+
+	_, err = reader.Read()
+	assert.ErrorIs(t, err, ErrInvalidRecord)
+
+	record, err = reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, Record{Key: "c", Value: "3", Line: 4}, record)
+}
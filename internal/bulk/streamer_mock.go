@@ -0,0 +1,94 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package bulk
+
+import (
+	io "io"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockStreamer is an autogenerated mock type for the Streamer type
+type MockStreamer struct {
+	mock.Mock
+}
+
+type MockStreamer_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockStreamer) EXPECT() *MockStreamer_Expecter {
+	return &MockStreamer_Expecter{mock: &_m.Mock}
+}
+
+// Stream provides a mock function with given fields: request
+func (_m *MockStreamer) Stream(request []byte) (io.Reader, error) {
+	ret := _m.Called(request)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stream")
+	}
+
+	var r0 io.Reader
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]byte) (io.Reader, error)); ok {
+		return rf(request)
+	}
+	if rf, ok := ret.Get(0).(func([]byte) io.Reader); ok {
+		r0 = rf(request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.Reader)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]byte) error); ok {
+		r1 = rf(request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStreamer_Stream_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Stream'
+type MockStreamer_Stream_Call struct {
+	*mock.Call
+}
+
+// Stream is a helper method to define mock.On call
+//   - request []byte
+func (_e *MockStreamer_Expecter) Stream(request interface{}) *MockStreamer_Stream_Call {
+	return &MockStreamer_Stream_Call{Call: _e.mock.On("Stream", request)}
+}
+
+func (_c *MockStreamer_Stream_Call) Run(run func(request []byte)) *MockStreamer_Stream_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].([]byte))
+	})
+	return _c
+}
+
+func (_c *MockStreamer_Stream_Call) Return(_a0 io.Reader, _a1 error) *MockStreamer_Stream_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStreamer_Stream_Call) RunAndReturn(run func([]byte) (io.Reader, error)) *MockStreamer_Stream_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockStreamer creates a new instance of MockStreamer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockStreamer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockStreamer {
+	mock := &MockStreamer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,15 @@
+package common
+
+import "strings"
+
+const unixScheme = "unix://"
+
+// ParseAddress splits address into network and address accepted by net.Dial
+// and net.Listen: "unix:///path.sock" is a unix socket, anything else is tcp.
+func ParseAddress(address string) (network string, addr string) {
+	if path, ok := strings.CutPrefix(address, unixScheme); ok {
+		return "unix", path
+	}
+
+	return "tcp", address
+}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAddress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		address         string
+		expectedNetwork string
+		expectedAddr    string
+	}{
+		{address: "127.0.0.1:3223", expectedNetwork: "tcp", expectedAddr: "127.0.0.1:3223"},
+		{address: ":3223", expectedNetwork: "tcp", expectedAddr: ":3223"},
+		{address: "unix:///tmp/inmemdb.sock", expectedNetwork: "unix", expectedAddr: "/tmp/inmemdb.sock"},
+		{address: "unix://inmemdb.sock", expectedNetwork: "unix", expectedAddr: "inmemdb.sock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			network, addr := ParseAddress(tt.address)
+			assert.Equal(t, tt.expectedNetwork, network)
+			assert.Equal(t, tt.expectedAddr, addr)
+		})
+	}
+}
@@ -1,10 +1,33 @@
 package common
 
 import (
+	"bufio"
 	"encoding/binary"
+	"errors"
 	"io"
 )
 
+// Frame header is a little endian uint32 holding the frame length, its high
+// bit marks a chunk of a message which is continued by the following frame.
+const (
+	chunkFlag       = 1 << 31
+	readStep        = 64 << 10
+	writeBufferSize = 64 << 10
+)
+
+// ThrottledResponse is sent instead of executing a request when client
+// exceeds its rate limit, clients should back off and retry.
+const ThrottledResponse = "ERROR(throttled)"
+
+// BusyResponse is sent instead of executing a write when WAL can't keep up
+// with writes, the write isn't executed, so clients should back off and retry.
+const BusyResponse = "ERROR(server busy)"
+
+var (
+	ErrMessageTooLarge = errors.New("message too large")
+	ErrBudgetExceeded  = errors.New("message budget exceeded")
+)
+
 func Read(reader io.Reader, data []byte) (int, error) {
 	var l uint32
 	if err := binary.Read(reader, binary.LittleEndian, &l); err != nil {
@@ -32,3 +55,255 @@ func Write(writer io.Writer, data []byte) (int, error) {
 
 	return writer.Write(data)
 }
+
+// ReadMessage reads a message sent by WriteMessage appending it to buffer[:0].
+// The buffer grows only as data arrives, so a peer can't make us allocate more
+// than it actually sends. If a frame exceeds maxFrameSize or the message
+// exceeds maxMessageSize the rest of the message is discarded and
+// ErrMessageTooLarge is returned, the reader stays at the next message.
+func ReadMessage(reader io.Reader, buffer []byte, maxFrameSize, maxMessageSize int) ([]byte, error) {
+	message, _, err := ReadMessageBudget(reader, buffer, maxFrameSize, maxMessageSize, nil)
+	return message, err
+}
+
+// Budget limits memory held by messages read from all connections.
+type Budget interface {
+	TryAcquire(n int) bool
+	Release(n int)
+}
+
+// ReadMessageBudget reads a message as ReadMessage does, but memory beyond
+// cap(buffer) is taken from budget before it's allocated. It returns the part
+// of budget the message holds, the caller releases it once the message is
+// handled. If budget is exhausted the rest of the message is discarded and
+// ErrBudgetExceeded is returned.
+func ReadMessageBudget(reader io.Reader, buffer []byte, maxFrameSize, maxMessageSize int, budget Budget) ([]byte, int, error) {
+	message := buffer[:0]
+	limit := maxMessageSize
+	if budget != nil {
+		limit = cap(buffer)
+	}
+
+	reserved := 0
+	var failure error
+	for first := true; ; first = false {
+		var header uint32
+		if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+			if !first && errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			release(budget, reserved)
+			return nil, 0, err
+		}
+
+		frameLen := int(header &^ chunkFlag)
+		if failure == nil && (frameLen > maxFrameSize || len(message)+frameLen > maxMessageSize) {
+			failure = ErrMessageTooLarge
+		}
+		if failure == nil && len(message)+frameLen > limit {
+			n := reserve(budget, len(message)+frameLen-limit, limit, maxMessageSize)
+			if n == 0 {
+				failure = ErrBudgetExceeded
+			}
+			reserved += n
+			limit += n
+		}
+
+		var err error
+		if failure != nil {
+			_, err = io.CopyN(io.Discard, reader, int64(frameLen))
+		} else {
+			message, err = readFrame(reader, message, frameLen, limit)
+		}
+		if err != nil {
+			release(budget, reserved)
+			return nil, 0, err
+		}
+
+		if header&chunkFlag == 0 {
+			break
+		}
+	}
+
+	if failure != nil {
+		release(budget, reserved)
+		return nil, 0, failure
+	}
+
+	return message, reserved, nil
+}
+
+// reserve takes at least n bytes from budget, it tries to double the limit so
+// a chunked message doesn't go to the budget for every frame.
+func reserve(budget Budget, n, limit, maxMessageSize int) int {
+	if budget == nil {
+		return 0
+	}
+	if double := min(max(n, limit), maxMessageSize-limit); double > n && budget.TryAcquire(double) {
+		return double
+	}
+	if budget.TryAcquire(n) {
+		return n
+	}
+
+	return 0
+}
+
+func release(budget Budget, n int) {
+	if budget != nil && n > 0 {
+		budget.Release(n)
+	}
+}
+
+// readFrame appends frame to message, its capacity never exceeds limit.
+func readFrame(reader io.Reader, message []byte, frameLen, limit int) ([]byte, error) {
+	for frameLen > 0 {
+		step := min(frameLen, readStep)
+		if cap(message)-len(message) < step {
+			grown := make([]byte, len(message), min(max(2*cap(message), len(message)+step), limit))
+			copy(grown, message)
+			message = grown
+		}
+
+		start := len(message)
+		message = message[:start+step]
+		if _, err := io.ReadFull(reader, message[start:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		frameLen -= step
+	}
+
+	return message, nil
+}
+
+// WriteMessage writes data as a single frame if it fits chunkSize, otherwise
+// splits it into chunks of at most chunkSize bytes.
+func WriteMessage(writer io.Writer, data []byte, chunkSize int) error {
+	if chunkSize <= 0 || len(data) <= chunkSize {
+		_, err := Write(writer, data)
+		return err
+	}
+
+	buffered := bufio.NewWriterSize(writer, writeBufferSize)
+	for len(data) > 0 {
+		chunk := data[:min(len(data), chunkSize)]
+		data = data[len(chunk):]
+
+		header := uint32(len(chunk))
+		if len(data) > 0 {
+			header |= chunkFlag
+		}
+		if err := binary.Write(buffered, binary.LittleEndian, header); err != nil {
+			return err
+		}
+		if _, err := buffered.Write(chunk); err != nil {
+			return err
+		}
+	}
+
+	return buffered.Flush()
+}
+
+// MessageWriter writes a message of unknown size, it's sent in chunks of
+// chunkSize bytes as it's written and Close sends the last one.
+type MessageWriter struct {
+	writer    io.Writer
+	chunkSize int
+	frame     []byte
+}
+
+const headerSize = 4
+
+func NewMessageWriter(writer io.Writer, chunkSize int) *MessageWriter {
+	if chunkSize <= 0 {
+		chunkSize = writeBufferSize
+	}
+
+	return &MessageWriter{
+		writer:    writer,
+		chunkSize: chunkSize,
+		frame:     make([]byte, headerSize, headerSize+chunkSize),
+	}
+}
+
+func (w *MessageWriter) Write(data []byte) (int, error) {
+	written := 0
+	for len(data) > 0 {
+		// the full chunk is sent only once more data follows it, so the
+		// last chunk is always sent by Close
+		if len(w.frame)-headerSize == w.chunkSize {
+			if err := w.flush(chunkFlag); err != nil {
+				return written, err
+			}
+		}
+
+		n := min(len(data), headerSize+w.chunkSize-len(w.frame))
+		w.frame = append(w.frame, data[:n]...)
+		data = data[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close ends the message, it doesn't close the underlying writer.
+func (w *MessageWriter) Close() error {
+	return w.flush(0)
+}
+
+func (w *MessageWriter) flush(flag uint32) error {
+	binary.LittleEndian.PutUint32(w.frame, uint32(len(w.frame)-headerSize)|flag)
+	_, err := w.writer.Write(w.frame)
+	w.frame = w.frame[:headerSize]
+	return err
+}
+
+// MessageReader reads a message sent by WriteMessage or MessageWriter as it
+// arrives, so it isn't held in memory. It returns io.EOF at the end of the
+// message, frames over maxFrameSize fail with ErrMessageTooLarge.
+type MessageReader struct {
+	reader       io.Reader
+	maxFrameSize int
+	// left is amount of unread bytes of the current frame.
+	left    int
+	started bool
+	last    bool
+}
+
+func NewMessageReader(reader io.Reader, maxFrameSize int) *MessageReader {
+	return &MessageReader{reader: reader, maxFrameSize: maxFrameSize}
+}
+
+func (r *MessageReader) Read(data []byte) (int, error) {
+	for r.left == 0 {
+		if r.last {
+			return 0, io.EOF
+		}
+
+		var header uint32
+		if err := binary.Read(r.reader, binary.LittleEndian, &header); err != nil {
+			if r.started && errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		r.started = true
+		r.last = header&chunkFlag == 0
+		r.left = int(header &^ chunkFlag)
+		if r.left > r.maxFrameSize {
+			return 0, ErrMessageTooLarge
+		}
+	}
+
+	n, err := r.reader.Read(data[:min(len(data), r.left)])
+	r.left -= n
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
@@ -0,0 +1,173 @@
+package common
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/DaniilZ77/InMemDB/internal/concurrency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_Chunked(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		size      int
+		chunkSize int
+	}{
+		{name: "empty", size: 0, chunkSize: 16},
+		{name: "single frame", size: 16, chunkSize: 16},
+		{name: "several chunks", size: 100, chunkSize: 16},
+		{name: "large message", size: 1 << 20, chunkSize: 4 << 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte("a"), tt.size)
+			stream := &bytes.Buffer{}
+			require.NoError(t, WriteMessage(stream, data, tt.chunkSize))
+
+			message, err := ReadMessage(stream, nil, tt.chunkSize, tt.size)
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(data, message))
+			assert.Zero(t, stream.Len())
+		})
+	}
+}
+
+func TestMessage_SingleFrameCompatible(t *testing.T) {
+	t.Parallel()
+
+	stream := &bytes.Buffer{}
+	require.NoError(t, WriteMessage(stream, []byte("get name"), 100))
+
+	buffer := make([]byte, 100)
+	n, err := Read(stream, buffer)
+	require.NoError(t, err)
+	assert.Equal(t, "get name", string(buffer[:n]))
+}
+
+func TestMessage_TooLarge(t *testing.T) {
+	t.Parallel()
+
+	stream := &bytes.Buffer{}
+	require.NoError(t, WriteMessage(stream, bytes.Repeat([]byte("a"), 100), 16))
+	require.NoError(t, WriteMessage(stream, []byte("get name"), 16))
+	require.NoError(t, WriteMessage(stream, bytes.Repeat([]byte("b"), 32), 32))
+
+	_, err := ReadMessage(stream, nil, 16, 64)
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+
+	message, err := ReadMessage(stream, nil, 16, 64)
+	require.NoError(t, err)
+	assert.Equal(t, "get name", string(message))
+
+	_, err = ReadMessage(stream, nil, 16, 64)
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+	assert.Zero(t, stream.Len())
+}
+
+func TestMessage_Truncated(t *testing.T) {
+	t.Parallel()
+
+	stream := &bytes.Buffer{}
+	require.NoError(t, WriteMessage(stream, bytes.Repeat([]byte("a"), 100), 16))
+	stream.Truncate(40)
+
+	_, err := ReadMessage(stream, nil, 16, 1024)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestMessage_Budget(t *testing.T) {
+	t.Parallel()
+
+	budget := concurrency.NewBudget(150)
+	stream := &bytes.Buffer{}
+	require.NoError(t, WriteMessage(stream, bytes.Repeat([]byte("a"), 100), 16))
+	require.NoError(t, WriteMessage(stream, bytes.Repeat([]byte("b"), 100), 16))
+	require.NoError(t, WriteMessage(stream, []byte("get name"), 16))
+
+	first, reserved, err := ReadMessageBudget(stream, make([]byte, 0, 16), 16, 1024, budget)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte("a"), 100), first)
+	assert.GreaterOrEqual(t, reserved, 100-16)
+	assert.LessOrEqual(t, cap(first), 16+reserved)
+
+	_, _, err = ReadMessageBudget(stream, make([]byte, 0, 16), 16, 1024, budget)
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+
+	message, n, err := ReadMessageBudget(stream, make([]byte, 0, 16), 16, 1024, budget)
+	require.NoError(t, err)
+	assert.Equal(t, "get name", string(message))
+	assert.Zero(t, n)
+	assert.Zero(t, stream.Len())
+
+	budget.Release(reserved)
+	assert.True(t, budget.TryAcquire(150))
+}
+
+func TestMessageWriter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		writes []int
+	}{
+		{name: "empty", writes: nil},
+		{name: "single frame", writes: []int{16}},
+		{name: "small writes", writes: []int{5, 5, 5, 5, 5, 5, 5}},
+		{name: "large write", writes: []int{100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &bytes.Buffer{}
+			writer := NewMessageWriter(stream, 16)
+
+			var data []byte
+			for i, size := range tt.writes {
+				chunk := bytes.Repeat([]byte{byte('a' + i)}, size)
+				n, err := writer.Write(chunk)
+				require.NoError(t, err)
+				assert.Equal(t, size, n)
+				data = append(data, chunk...)
+			}
+			require.NoError(t, writer.Close())
+
+			message, err := ReadMessage(stream, nil, 16, len(data))
+			require.NoError(t, err)
+			assert.Equal(t, string(data), string(message))
+			assert.Zero(t, stream.Len())
+		})
+	}
+}
+
+func TestMessageReader(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat([]byte("abcdefgh"), 20)
+	stream := &bytes.Buffer{}
+	require.NoError(t, WriteMessage(stream, data, 16))
+	require.NoError(t, WriteMessage(stream, []byte("next"), 16))
+
+	message, err := io.ReadAll(NewMessageReader(stream, 16))
+	require.NoError(t, err)
+	assert.Equal(t, data, message)
+
+	message, err = io.ReadAll(NewMessageReader(stream, 16))
+	require.NoError(t, err)
+	assert.Equal(t, "next", string(message), "reader stays at the next message")
+
+	require.NoError(t, WriteMessage(stream, data, 32))
+	_, err = io.ReadAll(NewMessageReader(stream, 16))
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+
+	stream.Reset()
+	require.NoError(t, WriteMessage(stream, data, 16))
+	stream.Truncate(stream.Len() - 1)
+	_, err = io.ReadAll(NewMessageReader(stream, 16))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -1,6 +1,12 @@
 package parser
 
-import "errors"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
 
 type CommandType int
 
@@ -8,31 +14,122 @@ const (
 	GET CommandType = iota
 	SET
 	DEL
+	INFO
+	CLIENT
+	CONFIG
+	SAVE
+	BGSAVE
+	HEALTH
+	READWRITE
+	DUMP
+	RESTORE
+	MSET
+	REPLICAS
 
 	setArgsCount     = 2
+	noArgsCount      = 0
 	defaultArgsCount = 1
 )
 
+// SyncModifier is an optional last token of write commands, such command is
+// acknowledged only after it is synced to disk.
+const SyncModifier = "sync"
+
+// DumpPrefix is the modifier of DUMP sending only keys with the prefix, it's
+// stored lowercased as the first arg: DUMP PREFIX user: has args
+// ["prefix", "user:"].
+const DumpPrefix = "prefix"
+
+const (
+	ClientList = "list"
+	ClientKill = "kill"
+	ConfigGet  = "get"
+)
+
 var keywords = map[string]CommandType{
-	"get": GET,
-	"set": SET,
-	"del": DEL,
+	"get":       GET,
+	"set":       SET,
+	"del":       DEL,
+	"info":      INFO,
+	"client":    CLIENT,
+	"config":    CONFIG,
+	"save":      SAVE,
+	"bgsave":    BGSAVE,
+	"health":    HEALTH,
+	"readwrite": READWRITE,
+	"dump":      DUMP,
+	"restore":   RESTORE,
+	"mset":      MSET,
+	"replicas":  REPLICAS,
+}
+
+// subcommands maps subcommand to amount of its args, the subcommand itself is
+// stored lowercased as the first arg of the command.
+var subcommands = map[CommandType]map[string]int{
+	CLIENT: {
+		ClientList: 0,
+		ClientKill: 1,
+	},
+	CONFIG: {
+		ConfigGet: 1,
+	},
+}
+
+// optionalArgs are commands which may have one more arg, DUMP writes the dump
+// to the file if it's given.
+var optionalArgs = map[CommandType]bool{
+	DUMP: true,
+}
+
+// pairArgs are commands taking one or more key value pairs.
+var pairArgs = map[CommandType]bool{
+	MSET: true,
 }
 
 type Command struct {
 	Type CommandType
 	Args []string
+	Sync bool
 }
 
 func (ct CommandType) argsCount() int {
 	switch ct {
-	case SET:
+	case SET, MSET:
 		return setArgsCount
+	case INFO, SAVE, BGSAVE, HEALTH, READWRITE, DUMP, REPLICAS:
+		return noArgsCount
 	default:
 		return defaultArgsCount
 	}
 }
 
+// String returns keyword of the command in upper case.
+func (ct CommandType) String() string {
+	for keyword, commandType := range keywords {
+		if commandType == ct {
+			return strings.ToUpper(keyword)
+		}
+	}
+
+	return fmt.Sprintf("UNKNOWN(%d)", int(ct))
+}
+
+func (ct CommandType) IsWrite() bool {
+	return ct == SET || ct == DEL || ct == MSET
+}
+
+// Peek returns type of the command looking only at its first token, so it's
+// cheap to call on requests before they are parsed.
+func Peek(source []byte) (CommandType, bool) {
+	source = bytes.TrimLeftFunc(source, unicode.IsSpace)
+	if end := bytes.IndexFunc(source, unicode.IsSpace); end >= 0 {
+		source = source[:end]
+	}
+
+	commandType, ok := keywords[string(bytes.ToLower(source))]
+	return commandType, ok
+}
+
 var (
 	ErrInvalidCommand = errors.New("invalid command")
 )
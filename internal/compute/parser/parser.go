@@ -36,11 +36,71 @@ func (p *Parser) Parse(source string) (*Command, error) {
 }
 
 func (p *Parser) parseArgs(commandType CommandType, tokens []string) (*Command, error) {
-	if len(tokens) != commandType.argsCount() {
+	if subcommands, ok := subcommands[commandType]; ok {
+		return p.parseSubcommand(commandType, subcommands, tokens)
+	}
+
+	if commandType == DUMP && len(tokens) == 2 && strings.EqualFold(tokens[0], DumpPrefix) {
+		return &Command{Type: DUMP, Args: []string{DumpPrefix, tokens[1]}}, nil
+	}
+
+	sync := false
+	if commandType.IsWrite() && hasExtraArg(commandType, tokens) && strings.EqualFold(tokens[len(tokens)-1], SyncModifier) {
+		sync = true
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	if !validArgsCount(commandType, len(tokens)) {
 		p.log.Warn("bad amount of args", slog.Int("args", len(tokens)), slog.Int("expected", commandType.argsCount()))
 		return nil, fmt.Errorf("%w: bad amount of args", ErrInvalidCommand)
 	}
 
+	return &Command{
+		Type: commandType,
+		Args: tokens,
+		Sync: sync,
+	}, nil
+}
+
+// hasExtraArg reports whether the last token may be a modifier, not an arg.
+func hasExtraArg(commandType CommandType, tokens []string) bool {
+	if pairArgs[commandType] {
+		return len(tokens)%2 == 1
+	}
+
+	return len(tokens) == commandType.argsCount()+1
+}
+
+func validArgsCount(commandType CommandType, count int) bool {
+	switch {
+	case pairArgs[commandType]:
+		return count >= commandType.argsCount() && count%2 == 0
+	case optionalArgs[commandType]:
+		return count == commandType.argsCount() || count == commandType.argsCount()+1
+	default:
+		return count == commandType.argsCount()
+	}
+}
+
+func (p *Parser) parseSubcommand(commandType CommandType, subcommands map[string]int, tokens []string) (*Command, error) {
+	if len(tokens) == 0 {
+		p.log.Warn("missing subcommand")
+		return nil, fmt.Errorf("%w: missing subcommand", ErrInvalidCommand)
+	}
+
+	subcommand := strings.ToLower(tokens[0])
+	argsCount, ok := subcommands[subcommand]
+	if !ok {
+		p.log.Warn("bad subcommand", slog.String("subcommand", subcommand))
+		return nil, fmt.Errorf("%w: bad subcommand", ErrInvalidCommand)
+	}
+
+	if len(tokens)-1 != argsCount {
+		p.log.Warn("bad amount of args", slog.Int("args", len(tokens)-1), slog.Int("expected", argsCount))
+		return nil, fmt.Errorf("%w: bad amount of args", ErrInvalidCommand)
+	}
+
+	tokens[0] = subcommand
 	return &Command{
 		Type: commandType,
 		Args: tokens,
@@ -44,6 +44,153 @@ func TestParse_Success(t *testing.T) {
 				Args: []string{"name"},
 			},
 		},
+		{
+			name:    "set command with sync",
+			command: "set name Daniil SYNC",
+			expected: &Command{
+				Type: SET,
+				Args: []string{"name", "Daniil"},
+				Sync: true,
+			},
+		},
+		{
+			name:    "set sync value",
+			command: "set name sync",
+			expected: &Command{
+				Type: SET,
+				Args: []string{"name", "sync"},
+			},
+		},
+		{
+			name:    "delete command with sync",
+			command: "del name sync",
+			expected: &Command{
+				Type: DEL,
+				Args: []string{"name"},
+				Sync: true,
+			},
+		},
+		{
+			name:    "info command",
+			command: "INFO",
+			expected: &Command{
+				Type: INFO,
+				Args: []string{},
+			},
+		},
+		{
+			name:    "save command",
+			command: "save",
+			expected: &Command{
+				Type: SAVE,
+				Args: []string{},
+			},
+		},
+		{
+			name:    "bgsave command",
+			command: "BGSAVE",
+			expected: &Command{
+				Type: BGSAVE,
+				Args: []string{},
+			},
+		},
+		{
+			name:    "health command",
+			command: "health",
+			expected: &Command{
+				Type: HEALTH,
+				Args: []string{},
+			},
+		},
+		{
+			name:    "readwrite command",
+			command: "READWRITE",
+			expected: &Command{
+				Type: READWRITE,
+				Args: []string{},
+			},
+		},
+		{
+			name:    "replicas command",
+			command: "replicas",
+			expected: &Command{
+				Type: REPLICAS,
+				Args: []string{},
+			},
+		},
+		{
+			name:    "dump command",
+			command: "DUMP",
+			expected: &Command{
+				Type: DUMP,
+				Args: []string{},
+			},
+		},
+		{
+			name:    "dump to file command",
+			command: "dump backup.jsonl",
+			expected: &Command{
+				Type: DUMP,
+				Args: []string{"backup.jsonl"},
+			},
+		},
+		{
+			name:    "dump with prefix command",
+			command: "DUMP Prefix user:",
+			expected: &Command{
+				Type: DUMP,
+				Args: []string{DumpPrefix, "user:"},
+			},
+		},
+		{
+			name:    "restore command",
+			command: "RESTORE backup.jsonl",
+			expected: &Command{
+				Type: RESTORE,
+				Args: []string{"backup.jsonl"},
+			},
+		},
+		{
+			name:    "mset command",
+			command: "MSET a 1 b 2",
+			expected: &Command{
+				Type: MSET,
+				Args: []string{"a", "1", "b", "2"},
+			},
+		},
+		{
+			name:    "mset command with sync modifier",
+			command: "mset a 1 b 2 SYNC",
+			expected: &Command{
+				Type: MSET,
+				Args: []string{"a", "1", "b", "2"},
+				Sync: true,
+			},
+		},
+		{
+			name:    "client list command",
+			command: "client LIST",
+			expected: &Command{
+				Type: CLIENT,
+				Args: []string{"list"},
+			},
+		},
+		{
+			name:    "client kill command",
+			command: "client kill 12",
+			expected: &Command{
+				Type: CLIENT,
+				Args: []string{"kill", "12"},
+			},
+		},
+		{
+			name:    "config get command",
+			command: "config get network.*",
+			expected: &Command{
+				Type: CONFIG,
+				Args: []string{"get", "network.*"},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -87,6 +234,54 @@ func TestParse_Fail(t *testing.T) {
 			name:    "bad amount of args",
 			command: "del",
 		},
+		{
+			name:    "bad amount of args",
+			command: "info all",
+		},
+		{
+			name:    "bad amount of args",
+			command: "dump backup.jsonl now",
+		},
+		{
+			name:    "bad amount of args",
+			command: "dump prefix user: backup.jsonl",
+		},
+		{
+			name:    "bad amount of args",
+			command: "restore",
+		},
+		{
+			name:    "bad amount of args",
+			command: "mset",
+		},
+		{
+			name:    "bad amount of args",
+			command: "mset a 1 b",
+		},
+		{
+			name:    "bad amount of args",
+			command: "mset sync",
+		},
+		{
+			name:    "sync modifier on read command",
+			command: "get name sync",
+		},
+		{
+			name:    "bad modifier",
+			command: "set name Daniil async",
+		},
+		{
+			name:    "missing subcommand",
+			command: "client",
+		},
+		{
+			name:    "bad subcommand",
+			command: "config set name",
+		},
+		{
+			name:    "bad amount of subcommand args",
+			command: "client kill",
+		},
 	}
 
 	for _, tt := range tests {
@@ -96,3 +291,40 @@ func TestParse_Fail(t *testing.T) {
 		})
 	}
 }
+
+func TestPeek(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		command      string
+		expected     CommandType
+		expectedOk   bool
+		expectedRead bool
+	}{
+		{name: "get command", command: "get name", expected: GET, expectedOk: true, expectedRead: true},
+		{name: "set command", command: "  SET name Daniil", expected: SET, expectedOk: true},
+		{name: "del command", command: "del\tname", expected: DEL, expectedOk: true},
+		{name: "bad command type", command: "sat name Daniil"},
+		{name: "empty command", command: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commandType, ok := Peek([]byte(tt.command))
+			assert.Equal(t, tt.expectedOk, ok)
+			if ok {
+				assert.Equal(t, tt.expected, commandType)
+				assert.Equal(t, !tt.expectedRead, commandType.IsWrite())
+			}
+		})
+	}
+}
+
+func TestCommandType_String(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "SET", SET.String())
+	assert.Equal(t, "BGSAVE", BGSAVE.String())
+	assert.Equal(t, "UNKNOWN(100)", CommandType(100).String())
+}
@@ -0,0 +1,33 @@
+package concurrency
+
+import "sync"
+
+// Budget limits total size of resources held at once. Unlike Semaphore it
+// doesn't block, so holders of a part of the budget can't wait for each other.
+type Budget struct {
+	mu        sync.Mutex
+	available int
+}
+
+func NewBudget(size int) *Budget {
+	return &Budget{available: size}
+}
+
+func (b *Budget) TryAcquire(n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if n > b.available {
+		return false
+	}
+
+	b.available -= n
+	return true
+}
+
+func (b *Budget) Release(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.available += n
+}
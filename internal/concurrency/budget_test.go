@@ -0,0 +1,21 @@
+package concurrency
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudget(t *testing.T) {
+	t.Parallel()
+
+	budget := NewBudget(100)
+
+	assert.True(t, budget.TryAcquire(60))
+	assert.False(t, budget.TryAcquire(50))
+	assert.True(t, budget.TryAcquire(40))
+	assert.False(t, budget.TryAcquire(1))
+
+	budget.Release(60)
+	assert.True(t, budget.TryAcquire(50))
+}
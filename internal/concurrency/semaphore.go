@@ -1,5 +1,7 @@
 package concurrency
 
+import "context"
+
 type Semaphore struct {
 	tickets chan struct{}
 }
@@ -10,8 +12,15 @@ func NewSemaphore(maxTickets int) *Semaphore {
 	}
 }
 
-func (s *Semaphore) Acquire() {
-	s.tickets <- struct{}{}
+// Acquire waits for a ticket until ctx is done, it reports whether the ticket
+// is acquired.
+func (s *Semaphore) Acquire(ctx context.Context) bool {
+	select {
+	case s.tickets <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 func (s *Semaphore) Release() {
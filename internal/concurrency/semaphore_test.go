@@ -0,0 +1,23 @@
+package concurrency
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemaphore(t *testing.T) {
+	t.Parallel()
+
+	semaphore := NewSemaphore(1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	assert.True(t, semaphore.Acquire(ctx))
+
+	cancel()
+	assert.False(t, semaphore.Acquire(ctx), "acquire is stopped by ctx")
+
+	semaphore.Release()
+	assert.True(t, semaphore.Acquire(context.Background()))
+}
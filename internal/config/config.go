@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -16,13 +17,22 @@ type Config struct {
 	LogLevel    string       `yaml:"log_level"`
 	Wal         *Wal         `yaml:"wal"`
 	Replication *Replication `yaml:"replication"`
+	Snapshot    *Snapshot    `yaml:"snapshot"`
+	Shutdown    *Shutdown    `yaml:"shutdown"`
+	RateLimit   *RateLimit   `yaml:"rate_limit"`
+	Encryption  *Encryption  `yaml:"encryption"`
+	Dump        *Dump        `yaml:"dump"`
 }
 
 type Network struct {
-	Address        string        `yaml:"address"`
-	MaxConnections int           `yaml:"max_connections"`
-	MaxMessageSize string        `yaml:"max_message_size"`
-	IdleTimeout    time.Duration `yaml:"idle_timeout"`
+	Address               string        `yaml:"address"`
+	Listeners             []string      `yaml:"listeners"`
+	UnixSocketPermissions string        `yaml:"unix_socket_permissions"`
+	MaxConnections        int           `yaml:"max_connections"`
+	MaxMessageSize        string        `yaml:"max_message_size"`
+	MaxRequestSize        string        `yaml:"max_request_size"`
+	MaxRequestMemory      string        `yaml:"max_request_memory"`
+	IdleTimeout           time.Duration `yaml:"idle_timeout"`
 }
 
 type Engine struct {
@@ -33,14 +43,83 @@ type Engine struct {
 type Wal struct {
 	FlushingBatchSize    int           `yaml:"flushing_batch_size"`
 	FlushingBatchTimeout time.Duration `yaml:"flushing_batch_timeout"`
+	AdaptiveBatching     bool          `yaml:"adaptive_batching"`
 	MaxSegmentSize       string        `yaml:"max_segment_size"`
 	DataDirectory        string        `yaml:"data_directory"`
+	Fsync                string        `yaml:"fsync"`
+	FsyncInterval        time.Duration `yaml:"fsync_interval"`
+	RecoveryWorkers      int           `yaml:"recovery_workers"`
+	Compression          string        `yaml:"compression"`
+	MaxPendingWrites     int           `yaml:"max_pending_writes"`
+	BusyTimeout          time.Duration `yaml:"busy_timeout"`
+	WriteTimeout         time.Duration `yaml:"write_timeout"`
+	FailureThreshold     int           `yaml:"failure_threshold"`
+	Preallocate          bool          `yaml:"preallocate"`
+	Retention            *Retention    `yaml:"retention"`
+}
+
+// Retention limits disk usage of WAL, segments not received by connected
+// replicas are removed only when HardMaxSize is exceeded. Without snapshots
+// nothing else holds the removed commands, so they are lost on restart.
+type Retention struct {
+	MaxSize        string        `yaml:"max_size"`
+	MaxAge         time.Duration `yaml:"max_age"`
+	MinSegments    int           `yaml:"min_segments"`
+	HardMaxSize    string        `yaml:"hard_max_size"`
+	ReplicaTimeout time.Duration `yaml:"replica_timeout"`
+	Interval       time.Duration `yaml:"interval"`
 }
 
 type Replication struct {
-	ReplicaType   string        `yaml:"replica_type"`
-	MasterAddress string        `yaml:"master_address"`
-	SyncInterval  time.Duration `yaml:"sync_interval"`
+	ReplicaType       string        `yaml:"replica_type"`
+	MasterAddress     string        `yaml:"master_address"`
+	SyncInterval      time.Duration `yaml:"sync_interval"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+	LagThreshold      time.Duration `yaml:"lag_threshold"`
+	ReplicaID         string        `yaml:"replica_id"`
+}
+
+// Snapshot RetentionWindow is how far back the state can be restored, see
+// point-in-time recovery. Only the latest snapshot is kept if it's not set.
+type Snapshot struct {
+	Directory       string        `yaml:"directory"`
+	Interval        time.Duration `yaml:"interval"`
+	RetentionWindow time.Duration `yaml:"retention_window"`
+}
+
+// Encryption keys are read from the file or, if it is not set, from the
+// environment variable, KeyID selects the key new data is encrypted with.
+type Encryption struct {
+	KeyFile string `yaml:"key_file"`
+	KeyEnv  string `yaml:"key_env"`
+	KeyID   uint32 `yaml:"key_id"`
+}
+
+// Dump is the directory DUMP writes and RESTORE reads named dumps from.
+type Dump struct {
+	Directory string `yaml:"directory"`
+}
+
+type Shutdown struct {
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+// RateLimit limits are applied per client identity, clients are keyed by
+// remote ip or "local" for unix socket connections.
+type RateLimit struct {
+	Default RateLimitClasses            `yaml:"default"`
+	Clients map[string]RateLimitClasses `yaml:"clients"`
+}
+
+type RateLimitClasses struct {
+	Read  *Limit `yaml:"read"`
+	Write *Limit `yaml:"write"`
+}
+
+type Limit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
 }
 
 func MustConfig() *Config {
@@ -81,3 +160,42 @@ func getConfigPath() (configPath string, ok bool) {
 
 	return configPath, configPath != ""
 }
+
+// Flatten returns config values keyed by their dot separated yaml path, e.g.
+// "network.address", lists are joined by comma.
+func (c *Config) Flatten() (map[string]string, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree map[string]any
+	if err = yaml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	flatten("", tree, values)
+	return values, nil
+}
+
+func flatten(prefix string, node any, values map[string]string) {
+	switch node := node.(type) {
+	case map[string]any:
+		for key, child := range node {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flatten(key, child, values)
+		}
+	case []any:
+		items := make([]string, 0, len(node))
+		for _, item := range node {
+			items = append(items, fmt.Sprint(item))
+		}
+		values[prefix] = strings.Join(items, ",")
+	case nil:
+	default:
+		values[prefix] = fmt.Sprint(node)
+	}
+}
@@ -0,0 +1,81 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package storage
+
+import (
+	parser "github.com/DaniilZ77/InMemDB/internal/compute/parser"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockAdmin is an autogenerated mock type for the Admin type
+type MockAdmin struct {
+	mock.Mock
+}
+
+type MockAdmin_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockAdmin) EXPECT() *MockAdmin_Expecter {
+	return &MockAdmin_Expecter{mock: &_m.Mock}
+}
+
+// Execute provides a mock function with given fields: command
+func (_m *MockAdmin) Execute(command *parser.Command) string {
+	ret := _m.Called(command)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Execute")
+	}
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(*parser.Command) string); ok {
+		r0 = rf(command)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// MockAdmin_Execute_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Execute'
+type MockAdmin_Execute_Call struct {
+	*mock.Call
+}
+
+// Execute is a helper method to define mock.On call
+//   - command *parser.Command
+func (_e *MockAdmin_Expecter) Execute(command interface{}) *MockAdmin_Execute_Call {
+	return &MockAdmin_Execute_Call{Call: _e.mock.On("Execute", command)}
+}
+
+func (_c *MockAdmin_Execute_Call) Run(run func(command *parser.Command)) *MockAdmin_Execute_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(*parser.Command))
+	})
+	return _c
+}
+
+func (_c *MockAdmin_Execute_Call) Return(_a0 string) *MockAdmin_Execute_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockAdmin_Execute_Call) RunAndReturn(run func(*parser.Command) string) *MockAdmin_Execute_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockAdmin creates a new instance of MockAdmin. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockAdmin(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockAdmin {
+	mock := &MockAdmin{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
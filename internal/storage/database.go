@@ -1,10 +1,16 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/DaniilZ77/InMemDB/internal/common"
 	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
 	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
 )
@@ -14,6 +20,15 @@ const (
 	delCommand           = 2
 	errReplicaNotSupport = "ERROR(invalid command: replica support only get commands)"
 	errInternal          = "ERROR(internal error)"
+	errAdminNotSupport   = "ERROR(invalid command: admin commands are disabled)"
+	errReadOnly          = "ERROR(read-only mode)"
+	healthy              = "OK"
+	bgsaveStarted        = "Background saving started"
+)
+
+var (
+	ErrSnapshotsDisabled  = errors.New("snapshots are disabled")
+	ErrSnapshotInProgress = errors.New("snapshot is in progress")
 )
 
 //go:generate mockery --name=Compute --case=snake --inpackage --inpackage-suffix --with-expecter
@@ -26,26 +41,57 @@ type Engine interface {
 	Del(key string)
 	Get(key string) (string, bool)
 	Set(key, value string)
+	ShardIndex(key string) int
+	ShardsNumber() int
+	Len() int
+	Range(fn func(key, value string))
 }
 
 //go:generate mockery --name=Wal --case=snake --inpackage --inpackage-suffix --with-expecter
 type Wal interface {
-	Save(command *parser.Command) bool
-	Recover() ([]wal.Command, error)
+	Save(command *parser.Command) error
+	SaveBatch(commands []*parser.Command) error
+	ReadOnly() error
+	RecoverTo(lsn int, target wal.RecoveryTarget, apply func([]wal.Command)) error
+	LSN() int
 }
 
 //go:generate mockery --name=Replication --case=snake --inpackage --inpackage-suffix --with-expecter
 type Replication interface {
 	IsSlave() bool
 	GetReplicationStream() <-chan []wal.Command
+	// Applied is called once the batch received from the stream is applied.
+	Applied()
+}
+
+//go:generate mockery --name=Admin --case=snake --inpackage --inpackage-suffix --with-expecter
+type Admin interface {
+	Execute(command *parser.Command) string
+}
+
+//go:generate mockery --name=Snapshotter --case=snake --inpackage --inpackage-suffix --with-expecter
+type Snapshotter interface {
+	Create(lsn int) error
+	RestoreBefore(maxLSN int, at time.Time) (int, error)
 }
 
 type Database struct {
-	compute Compute
-	engine  Engine
-	wal     Wal
-	replica Replication
-	log     *slog.Logger
+	compute   Compute
+	engine    Engine
+	wal       Wal
+	replica   Replication
+	admin     Admin
+	snapshots Snapshotter
+	log       *slog.Logger
+
+	dumpDirectory string
+
+	recoveryWorkers int
+
+	// writes is held for reading by write commands from saving in WAL till
+	// applying to engine, snapshot holds it for writing to capture LSN.
+	writes       sync.RWMutex
+	snapshotting atomic.Bool
 }
 
 func NewDatabase(
@@ -53,7 +99,7 @@ func NewDatabase(
 	engine Engine,
 	wal Wal,
 	replica Replication,
-	log *slog.Logger) (*Database, error) {
+	log *slog.Logger, opts ...DatabaseOption) (*Database, error) {
 	if compute == nil {
 		return nil, errors.New("compute is nil")
 	}
@@ -65,17 +111,28 @@ func NewDatabase(
 	}
 
 	database := &Database{
-		compute: compute,
-		engine:  engine,
-		wal:     wal,
-		replica: replica,
-		log:     log,
+		compute:         compute,
+		engine:          engine,
+		wal:             wal,
+		replica:         replica,
+		log:             log,
+		recoveryWorkers: runtime.GOMAXPROCS(0),
+		dumpDirectory:   defaultDumpDirectory,
+	}
+
+	for _, opt := range opts {
+		opt(database)
+	}
+
+	if database.snapshots != nil && wal == nil {
+		return nil, errors.New("snapshots require wal")
 	}
 
 	if replica != nil && replica.IsSlave() {
 		go func() {
 			for commands := range replica.GetReplicationStream() {
 				database.executeWalCommands(commands)
+				replica.Applied()
 			}
 		}()
 	}
@@ -96,6 +153,20 @@ func (d *Database) Execute(source string) string {
 		return d.getCommand(command)
 	case parser.DEL:
 		return d.delCommand(command)
+	case parser.MSET:
+		return d.msetCommand(command)
+	case parser.INFO, parser.CLIENT, parser.CONFIG, parser.READWRITE, parser.REPLICAS:
+		return d.adminCommand(command)
+	case parser.HEALTH:
+		return d.healthCommand()
+	case parser.SAVE:
+		return d.saveCommand()
+	case parser.BGSAVE:
+		return d.bgsaveCommand()
+	case parser.DUMP:
+		return d.dumpCommand(command)
+	case parser.RESTORE:
+		return d.restoreCommand(command)
 	}
 
 	return errInternal
@@ -115,15 +186,72 @@ func (d *Database) executeWalCommands(commands []wal.Command) {
 }
 
 func (d *Database) Recover() error {
+	return d.RecoverTo(wal.RecoveryTarget{})
+}
+
+// RecoverTo restores the snapshot and replays WAL till the target, see
+// wal.RecoveryTarget.
+func (d *Database) RecoverTo(target wal.RecoveryTarget) error {
 	if d.wal == nil {
 		return nil
 	}
-	commands, err := d.wal.Recover()
-	if err != nil {
-		return err
+
+	// The snapshot must not contain commands past the target, so the latest
+	// snapshot preceding it is restored.
+	lsn := 0
+	if d.snapshots != nil {
+		var err error
+		if lsn, err = d.snapshots.RestoreBefore(target.StopLSN, target.StopTime); err != nil {
+			return err
+		}
+	}
+
+	workers := min(d.recoveryWorkers, d.engine.ShardsNumber())
+	if workers <= 1 {
+		return d.wal.RecoverTo(lsn, target, d.executeWalCommands)
+	}
+
+	replayer := newReplayer(workers, d.engine.ShardIndex, d.executeWalCommands)
+	err := d.wal.RecoverTo(lsn, target, replayer.apply)
+	replayer.wait()
+
+	return err
+}
+
+// Snapshot saves state of the engine. Writes are paused only to capture LSN,
+// so every command before it is applied to the engine. Commands following it
+// may get into the snapshot too, replaying them on recovery is idempotent.
+func (d *Database) Snapshot() error {
+	if d.snapshots == nil {
+		return ErrSnapshotsDisabled
+	}
+	if !d.snapshotting.CompareAndSwap(false, true) {
+		return ErrSnapshotInProgress
+	}
+	defer d.snapshotting.Store(false)
+
+	d.writes.Lock()
+	lsn := d.wal.LSN()
+	d.writes.Unlock()
+
+	return d.snapshots.Create(lsn)
+}
+
+// StartSnapshots takes snapshot every interval until ctx is done.
+func (d *Database) StartSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Snapshot(); err != nil {
+				d.log.Error("failed to take snapshot", slog.Any("error", err))
+			}
+		}
 	}
-	d.executeWalCommands(commands)
-	return nil
 }
 
 func (d *Database) setCommand(command *parser.Command) string {
@@ -131,12 +259,49 @@ func (d *Database) setCommand(command *parser.Command) string {
 		return errReplicaNotSupport
 	}
 
-	if d.wal == nil || d.wal.Save(command) {
-		d.engine.Set(command.Args[0], command.Args[1])
-		return "OK"
+	d.writes.RLock()
+	defer d.writes.RUnlock()
+
+	if d.wal != nil {
+		if err := d.wal.Save(command); err != nil {
+			return d.walError(err)
+		}
 	}
 
-	return errInternal
+	d.engine.Set(command.Args[0], command.Args[1])
+	return "OK"
+}
+
+// msetCommand saves the pairs in WAL as SET commands in order by a single
+// append and applies them once it succeeds, the last value of the repeated
+// key wins.
+func (d *Database) msetCommand(command *parser.Command) string {
+	if d.replica != nil && d.replica.IsSlave() {
+		return errReplicaNotSupport
+	}
+
+	commands := make([]*parser.Command, 0, len(command.Args)/2)
+	for i := 0; i+1 < len(command.Args); i += 2 {
+		commands = append(commands, &parser.Command{
+			Type: parser.SET,
+			Args: command.Args[i : i+2],
+			Sync: command.Sync,
+		})
+	}
+
+	d.writes.RLock()
+	defer d.writes.RUnlock()
+
+	if d.wal != nil {
+		if err := d.wal.SaveBatch(commands); err != nil {
+			return d.walError(err)
+		}
+	}
+
+	for _, command := range commands {
+		d.engine.Set(command.Args[0], command.Args[1])
+	}
+	return "OK"
 }
 
 func (d *Database) getCommand(command *parser.Command) string {
@@ -153,10 +318,95 @@ func (d *Database) delCommand(command *parser.Command) string {
 		return errReplicaNotSupport
 	}
 
-	if d.wal == nil || d.wal.Save(command) {
-		d.engine.Del(command.Args[0])
-		return "OK"
+	d.writes.RLock()
+	defer d.writes.RUnlock()
+
+	if d.wal != nil {
+		if err := d.wal.Save(command); err != nil {
+			return d.walError(err)
+		}
+	}
+
+	d.engine.Del(command.Args[0])
+	return "OK"
+}
+
+// walError returns response for the error of saving command in WAL, details
+// of disk errors are only logged.
+func (d *Database) walError(err error) string {
+	switch {
+	case errors.Is(err, wal.ErrBusy):
+		return common.BusyResponse
+	case errors.Is(err, wal.ErrReadOnly):
+		return errReadOnly
+	}
+
+	d.log.Error("failed to save command", slog.Any("error", err))
+	if reason := walErrorReason(err); reason != "" {
+		return fmt.Sprintf("ERROR(%s)", reason)
 	}
 
 	return errInternal
 }
+
+func walErrorReason(err error) string {
+	for _, walErr := range []error{wal.ErrTimeout, wal.ErrClosed, wal.ErrDiskFull, wal.ErrFsyncFailed, wal.ErrWriteFailed} {
+		if errors.Is(err, walErr) {
+			return walErr.Error()
+		}
+	}
+
+	return ""
+}
+
+// healthCommand reports whether the node accepts writes, reads are served in
+// read-only mode too.
+func (d *Database) healthCommand() string {
+	if d.wal == nil {
+		return healthy
+	}
+
+	err := d.wal.ReadOnly()
+	if err == nil {
+		return healthy
+	}
+
+	if reason := walErrorReason(err); reason != "" {
+		return fmt.Sprintf("READ-ONLY(%s)", reason)
+	}
+	return "READ-ONLY"
+}
+
+func (d *Database) adminCommand(command *parser.Command) string {
+	if d.admin == nil {
+		return errAdminNotSupport
+	}
+
+	return d.admin.Execute(command)
+}
+
+func (d *Database) saveCommand() string {
+	if err := d.Snapshot(); err != nil {
+		d.log.Error("failed to take snapshot", slog.Any("error", err))
+		return fmt.Sprintf("ERROR(%s)", err.Error())
+	}
+
+	return "OK"
+}
+
+func (d *Database) bgsaveCommand() string {
+	if d.snapshots == nil {
+		return fmt.Sprintf("ERROR(%s)", ErrSnapshotsDisabled.Error())
+	}
+	if d.snapshotting.Load() {
+		return fmt.Sprintf("ERROR(%s)", ErrSnapshotInProgress.Error())
+	}
+
+	go func() {
+		if err := d.Snapshot(); err != nil {
+			d.log.Error("failed to take snapshot", slog.Any("error", err))
+		}
+	}()
+
+	return bgsaveStarted
+}
@@ -0,0 +1,35 @@
+package storage
+
+type DatabaseOption func(*Database)
+
+func WithAdmin(admin Admin) DatabaseOption {
+	return func(d *Database) {
+		d.admin = admin
+	}
+}
+
+func WithSnapshots(snapshots Snapshotter) DatabaseOption {
+	return func(d *Database) {
+		d.snapshots = snapshots
+	}
+}
+
+// WithRecoveryWorkers sets amount of goroutines WAL is replayed with on
+// recovery, commands are partitioned between them by shard of the key.
+func WithRecoveryWorkers(workers int) DatabaseOption {
+	return func(d *Database) {
+		if workers > 0 {
+			d.recoveryWorkers = workers
+		}
+	}
+}
+
+// WithDumpDirectory sets directory dumps are written to and restored from by
+// name.
+func WithDumpDirectory(directory string) DatabaseOption {
+	return func(d *Database) {
+		if directory != "" {
+			d.dumpDirectory = directory
+		}
+	}
+}
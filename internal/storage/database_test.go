@@ -2,11 +2,13 @@ package storage
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"testing"
 	"time"
 
+	"github.com/DaniilZ77/InMemDB/internal/common"
 	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
 	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
 	"github.com/stretchr/testify/assert"
@@ -53,7 +55,7 @@ func TestExecute_Success(t *testing.T) {
 				}
 				compute.EXPECT().Parse("set name Daniil").Return(command, nil).Once()
 				engine.EXPECT().Set("name", "Daniil").Return().Once()
-				wal.EXPECT().Save(command).Return(true).Once()
+				wal.EXPECT().Save(command).Return(nil).Once()
 			},
 		},
 		{
@@ -67,7 +69,7 @@ func TestExecute_Success(t *testing.T) {
 				}
 				compute.EXPECT().Parse("del name").Return(command, nil).Once()
 				engine.EXPECT().Del("name").Return().Once()
-				wal.EXPECT().Save(command).Return(true).Once()
+				wal.EXPECT().Save(command).Return(nil).Once()
 			},
 		},
 		{
@@ -132,23 +134,122 @@ func TestExecute_NilWal(t *testing.T) {
 func TestExecute_WalSaveError(t *testing.T) {
 	t.Parallel()
 
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "busy", err: wal.ErrBusy, expected: common.BusyResponse},
+		{name: "read-only", err: fmt.Errorf("%w: %w", wal.ErrReadOnly, wal.ErrDiskFull), expected: errReadOnly},
+		{name: "disk full", err: fmt.Errorf("%w: write /data/wal_1.log: no space left on device", wal.ErrDiskFull), expected: "ERROR(disk is full)"},
+		{name: "fsync failed", err: fmt.Errorf("%w: input/output error", wal.ErrFsyncFailed), expected: "ERROR(wal fsync failed)"},
+		{name: "closed", err: wal.ErrClosed, expected: "ERROR(wal is closed)"},
+		{name: "timeout", err: wal.ErrTimeout, expected: "ERROR(wal write timeout)"},
+		{name: "unknown", err: errors.New("unknown"), expected: errInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compute := NewMockCompute(t)
+			engine := NewMockEngine(t)
+			w := NewMockWal(t)
+
+			database, err := NewDatabase(compute, engine, w, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+			require.NoError(t, err)
+
+			command := &parser.Command{
+				Type: parser.SET,
+				Args: []string{"name", "Daniil"},
+			}
+			commandStr := "set name Daniil"
+			compute.EXPECT().Parse(commandStr).Return(command, nil).Once()
+			w.EXPECT().Save(command).Return(tt.err).Once()
+
+			res := database.Execute(commandStr)
+			assert.Equal(t, tt.expected, res)
+		})
+	}
+}
+
+func TestExecute_Mset(t *testing.T) {
+	t.Parallel()
+
 	compute := NewMockCompute(t)
 	engine := NewMockEngine(t)
-	wal := NewMockWal(t)
+	w := NewMockWal(t)
 
-	database, err := NewDatabase(compute, engine, wal, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	database, err := NewDatabase(compute, engine, w, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)))
 	require.NoError(t, err)
 
-	command := &parser.Command{
-		Type: parser.SET,
-		Args: []string{"name", "Daniil"},
+	commandStr := "mset a 1 b 2 a 3 sync"
+	compute.EXPECT().Parse(commandStr).Return(&parser.Command{
+		Type: parser.MSET,
+		Args: []string{"a", "1", "b", "2", "a", "3"},
+		Sync: true,
+	}, nil).Once()
+	w.EXPECT().SaveBatch([]*parser.Command{
+		{Type: parser.SET, Args: []string{"a", "1"}, Sync: true},
+		{Type: parser.SET, Args: []string{"b", "2"}, Sync: true},
+		{Type: parser.SET, Args: []string{"a", "3"}, Sync: true},
+	}).Return(nil).Once()
+
+	var applied []string
+	engine.EXPECT().Set(mock.Anything, mock.Anything).Run(func(key, value string) {
+		applied = append(applied, key+"="+value)
+	}).Times(3)
+
+	assert.Equal(t, "OK", database.Execute(commandStr))
+	assert.Equal(t, []string{"a=1", "b=2", "a=3"}, applied, "pairs are applied in order after a single save")
+}
+
+func TestExecute_MsetWalError(t *testing.T) {
+	t.Parallel()
+
+	compute := NewMockCompute(t)
+	engine := NewMockEngine(t)
+	w := NewMockWal(t)
+
+	database, err := NewDatabase(compute, engine, w, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	compute.EXPECT().Parse("mset a 1 b 2").Return(&parser.Command{
+		Type: parser.MSET,
+		Args: []string{"a", "1", "b", "2"},
+	}, nil).Once()
+	w.EXPECT().SaveBatch([]*parser.Command{
+		{Type: parser.SET, Args: []string{"a", "1"}},
+		{Type: parser.SET, Args: []string{"b", "2"}},
+	}).Return(wal.ErrDiskFull).Once()
+
+	assert.Equal(t, "ERROR(disk is full)", database.Execute("mset a 1 b 2"))
+}
+
+func TestExecute_Health(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "healthy", expected: healthy},
+		{name: "read-only", err: fmt.Errorf("%w: %w: no space left on device", wal.ErrReadOnly, wal.ErrDiskFull), expected: "READ-ONLY(disk is full)"},
 	}
-	commandStr := "set name Daniil"
-	compute.EXPECT().Parse(commandStr).Return(command, nil).Once()
-	wal.EXPECT().Save(command).Return(false).Once()
 
-	res := database.Execute(commandStr)
-	assert.Contains(t, res, "ERROR")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compute := NewMockCompute(t)
+			w := NewMockWal(t)
+
+			database, err := NewDatabase(compute, NewMockEngine(t), w, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+			require.NoError(t, err)
+
+			compute.EXPECT().Parse("health").Return(&parser.Command{Type: parser.HEALTH}, nil).Once()
+			w.EXPECT().ReadOnly().Return(tt.err).Once()
+
+			assert.Equal(t, tt.expected, database.Execute("health"))
+		})
+	}
 }
 
 func TestRecover_Success(t *testing.T) {
@@ -161,11 +262,17 @@ func TestRecover_Success(t *testing.T) {
 	database, err := NewDatabase(compute, engine, w, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)))
 	require.NoError(t, err)
 
-	w.EXPECT().Recover().Return([]wal.Command{
-		{CommandType: 1, Args: []string{"name", "Daniil"}},
-		{CommandType: 2, Args: []string{"name"}},
-		{CommandType: 0, Args: []string{"name"}},
-	}, nil).Once()
+	w.EXPECT().RecoverTo(0, wal.RecoveryTarget{}, mock.Anything).RunAndReturn(func(_ int, _ wal.RecoveryTarget, apply func([]wal.Command)) error {
+		apply([]wal.Command{
+			{CommandType: 1, Args: []string{"name", "Daniil"}},
+			{CommandType: 2, Args: []string{"name"}},
+		})
+		apply([]wal.Command{
+			{CommandType: 0, Args: []string{"name"}},
+		})
+		return nil
+	}).Once()
+	engine.EXPECT().ShardsNumber().Return(1).Once()
 	engine.EXPECT().Set("name", "Daniil").Return().Once()
 	engine.EXPECT().Del("name").Return().Once()
 
@@ -173,6 +280,98 @@ func TestRecover_Success(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestRecover_FromSnapshot(t *testing.T) {
+	t.Parallel()
+
+	compute := NewMockCompute(t)
+	engine := NewMockEngine(t)
+	w := NewMockWal(t)
+	snapshots := NewMockSnapshotter(t)
+
+	database, err := NewDatabase(compute, engine, w, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithSnapshots(snapshots))
+	require.NoError(t, err)
+
+	snapshots.EXPECT().RestoreBefore(0, time.Time{}).Return(5, nil).Once()
+	w.EXPECT().RecoverTo(5, wal.RecoveryTarget{}, mock.Anything).RunAndReturn(func(_ int, _ wal.RecoveryTarget, apply func([]wal.Command)) error {
+		apply([]wal.Command{{LSN: 5, CommandType: 2, Args: []string{"name"}}})
+		return nil
+	}).Once()
+	engine.EXPECT().ShardsNumber().Return(1).Once()
+	engine.EXPECT().Del("name").Return().Once()
+
+	err = database.Recover()
+	assert.NoError(t, err)
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	compute := NewMockCompute(t)
+	engine := NewMockEngine(t)
+	w := NewMockWal(t)
+	snapshots := NewMockSnapshotter(t)
+
+	database, err := NewDatabase(compute, engine, w, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithSnapshots(snapshots))
+	require.NoError(t, err)
+
+	compute.EXPECT().Parse("SAVE").Return(&parser.Command{Type: parser.SAVE}, nil).Once()
+	w.EXPECT().LSN().Return(42).Once()
+	snapshots.EXPECT().Create(42).Return(nil).Once()
+	assert.Equal(t, "OK", database.Execute("SAVE"))
+
+	created := make(chan struct{})
+	compute.EXPECT().Parse("BGSAVE").Return(&parser.Command{Type: parser.BGSAVE}, nil).Once()
+	w.EXPECT().LSN().Return(43).Once()
+	snapshots.EXPECT().Create(43).RunAndReturn(func(int) error {
+		close(created)
+		return nil
+	}).Once()
+	assert.Equal(t, bgsaveStarted, database.Execute("BGSAVE"))
+	<-created
+}
+
+func TestSnapshot_InProgress(t *testing.T) {
+	t.Parallel()
+
+	compute := NewMockCompute(t)
+	engine := NewMockEngine(t)
+	w := NewMockWal(t)
+	snapshots := NewMockSnapshotter(t)
+
+	database, err := NewDatabase(compute, engine, w, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithSnapshots(snapshots))
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	w.EXPECT().LSN().Return(1).Once()
+	snapshots.EXPECT().Create(1).RunAndReturn(func(int) error {
+		<-release
+		return nil
+	}).Once()
+
+	done := make(chan error)
+	go func() {
+		done <- database.Snapshot()
+	}()
+	assert.Eventually(t, database.snapshotting.Load, time.Second, time.Millisecond)
+
+	assert.ErrorIs(t, database.Snapshot(), ErrSnapshotInProgress)
+	close(release)
+	assert.NoError(t, <-done)
+}
+
+func TestSnapshot_Disabled(t *testing.T) {
+	t.Parallel()
+
+	compute := NewMockCompute(t)
+	engine := NewMockEngine(t)
+
+	database, err := NewDatabase(compute, engine, nil, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	compute.EXPECT().Parse("BGSAVE").Return(&parser.Command{Type: parser.BGSAVE}, nil).Once()
+	assert.Contains(t, database.Execute("BGSAVE"), ErrSnapshotsDisabled.Error())
+}
+
 func TestRecover_NilWal(t *testing.T) {
 	t.Parallel()
 
@@ -207,6 +406,14 @@ func TestSlaveReplica_ForbiddenCommands(t *testing.T) {
 	res = database.Execute("del a")
 	assert.Equal(t, errReplicaNotSupport, res)
 
+	compute.EXPECT().Parse(mock.Anything).Return(&parser.Command{Type: parser.MSET, Args: []string{"a", "b"}}, nil).Once()
+	res = database.Execute("mset a b")
+	assert.Equal(t, errReplicaNotSupport, res)
+
+	compute.EXPECT().Parse(mock.Anything).Return(&parser.Command{Type: parser.RESTORE, Args: []string{"backup.jsonl"}}, nil).Once()
+	res = database.Execute("restore backup.jsonl")
+	assert.Equal(t, errReplicaNotSupport, res)
+
 	time.Sleep(100 * time.Millisecond)
 }
 
@@ -221,8 +428,10 @@ func TestReplicationStream(t *testing.T) {
 	engine.EXPECT().Del(mock.Anything).Return().Once()
 
 	replicationStream := make(chan []wal.Command)
+	applied := make(chan struct{})
 	replica.EXPECT().IsSlave().Return(true).Once()
 	replica.EXPECT().GetReplicationStream().Return(replicationStream).Once()
+	replica.EXPECT().Applied().Run(func() { close(applied) }).Return().Once()
 
 	_, err := NewDatabase(compute, engine, nil, replica, slog.New(slog.NewJSONHandler(io.Discard, nil)))
 	require.NoError(t, err)
@@ -232,9 +441,44 @@ func TestReplicationStream(t *testing.T) {
 		{CommandType: delCommand, Args: []string{"name"}},
 	}
 
-	go func() {
-		replicationStream <- commands
-	}()
+	replicationStream <- commands
 
-	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-applied:
+	case <-time.After(time.Second):
+		require.FailNow(t, "batch isn't reported applied")
+	}
+}
+
+func TestExecute_Admin(t *testing.T) {
+	t.Parallel()
+
+	compute := NewMockCompute(t)
+	engine := NewMockEngine(t)
+	admin := NewMockAdmin(t)
+
+	database, err := NewDatabase(compute, engine, nil, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithAdmin(admin))
+	require.NoError(t, err)
+
+	command := &parser.Command{Type: parser.INFO, Args: []string{}}
+	compute.EXPECT().Parse("info").Return(command, nil).Once()
+	admin.EXPECT().Execute(command).Return("# Server").Once()
+
+	res := database.Execute("info")
+	assert.Equal(t, "# Server", res)
+}
+
+func TestExecute_AdminDisabled(t *testing.T) {
+	t.Parallel()
+
+	compute := NewMockCompute(t)
+	engine := NewMockEngine(t)
+
+	database, err := NewDatabase(compute, engine, nil, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	compute.EXPECT().Parse("client list").Return(&parser.Command{Type: parser.CLIENT, Args: []string{parser.ClientList}}, nil).Once()
+
+	res := database.Execute("client list")
+	assert.Equal(t, errAdminNotSupport, res)
 }
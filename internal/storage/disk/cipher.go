@@ -0,0 +1,198 @@
+package disk
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Payloads of encrypted records are sealed with AES-256-GCM:
+//
+//	nonce      [12]byte random
+//	ciphertext []byte   payload with the tag, lsn and codec are authenticated
+//
+// Key id is stored in the segment header, zero means segment isn't encrypted.
+const keySize = 32
+
+var (
+	ErrUnknownKey = errors.New("unknown encryption key")
+	ErrDecryption = errors.New("failed to decrypt, wrong encryption key")
+)
+
+// Keyring holds encryption keys by id, new data is encrypted with the current
+// key, the rest are kept to read data written before key rotation.
+type Keyring struct {
+	keys    map[uint32]cipher.AEAD
+	current uint32
+}
+
+// NewKeyring returns keyring of 32 bytes keys, zero current stands for the
+// largest key id.
+func NewKeyring(keys map[uint32][]byte, current uint32) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption keys")
+	}
+
+	keyring := &Keyring{keys: make(map[uint32]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == 0 {
+			return nil, errors.New("encryption key id must be positive")
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("encryption key %d must be %d bytes", id, keySize)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if keyring.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+
+	if current == 0 {
+		current = slices.Max(keyIDs(keys))
+	}
+	if _, ok := keyring.keys[current]; !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownKey, current)
+	}
+	keyring.current = current
+
+	return keyring, nil
+}
+
+// ParseKeys parses keys written as "<id>:<hex key>" separated by commas or
+// new lines, lines starting with # are ignored.
+func ParseKeys(data string) (map[uint32][]byte, error) {
+	keys := make(map[uint32][]byte)
+	for _, line := range strings.FieldsFunc(data, func(r rune) bool { return r == '\n' || r == ',' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, key, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, errors.New("encryption key must be written as <id>:<hex key>")
+		}
+		keyID, err := strconv.ParseUint(strings.TrimSpace(id), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key id %q", id)
+		}
+		if _, ok := keys[uint32(keyID)]; ok {
+			return nil, fmt.Errorf("duplicated encryption key %d", keyID)
+		}
+		if keys[uint32(keyID)], err = hex.DecodeString(strings.TrimSpace(key)); err != nil {
+			return nil, fmt.Errorf("invalid encryption key %d: %w", keyID, err)
+		}
+	}
+
+	return keys, nil
+}
+
+// LoadKeyring reads keys from the file or, if it's empty, from the
+// environment variable, see ParseKeys. Zero current stands for the largest
+// key id.
+func LoadKeyring(keyFile, keyEnv string, current uint32) (*Keyring, error) {
+	var data string
+	switch {
+	case keyFile != "":
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption keys: %w", err)
+		}
+		data = string(content)
+	case keyEnv != "":
+		var ok bool
+		if data, ok = os.LookupEnv(keyEnv); !ok {
+			return nil, fmt.Errorf("encryption keys variable %s is not set", keyEnv)
+		}
+	default:
+		return nil, errors.New("encryption key file or env must be set")
+	}
+
+	keys, err := ParseKeys(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeyring(keys, current)
+}
+
+// CurrentKey returns id of the key new data is encrypted with, zero if keyring
+// is nil.
+func (k *Keyring) CurrentKey() uint32 {
+	if k == nil {
+		return 0
+	}
+
+	return k.current
+}
+
+// Seal encrypts payload with the key, lsn and codec are authenticated.
+func (k *Keyring) Seal(keyID uint32, lsn uint64, codec Codec, payload []byte) ([]byte, error) {
+	aead, err := k.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+	if _, err := rand.Read(sealed); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(sealed, sealed, payload, additionalData(lsn, codec)), nil
+}
+
+func (k *Keyring) Open(keyID uint32, lsn uint64, codec Codec, sealed []byte) ([]byte, error) {
+	aead, err := k.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrDecryption
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	payload, err := aead.Open(nil, nonce, ciphertext, additionalData(lsn, codec))
+	if err != nil {
+		return nil, fmt.Errorf("%w %d", ErrDecryption, keyID)
+	}
+
+	return payload, nil
+}
+
+func (k *Keyring) key(keyID uint32) (cipher.AEAD, error) {
+	if k == nil {
+		return nil, fmt.Errorf("%w %d: encryption is disabled", ErrUnknownKey, keyID)
+	}
+
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownKey, keyID)
+	}
+
+	return aead, nil
+}
+
+func additionalData(lsn uint64, codec Codec) []byte {
+	return append(binary.LittleEndian.AppendUint64(nil, lsn), byte(codec))
+}
+
+func keyIDs(keys map[uint32][]byte) []uint32 {
+	ids := make([]uint32, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
@@ -0,0 +1,91 @@
+package disk
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Codec is the compression of the record payload, it's stored in the record
+// header since FormatCompression.
+type Codec uint8
+
+const (
+	CodecNone Codec = iota
+	CodecFlate
+)
+
+// compressMinSize is the size of the smallest payload worth compressing.
+const compressMinSize = 128
+
+var codecNames = map[string]Codec{
+	"none":  CodecNone,
+	"flate": CodecFlate,
+}
+
+// ParseCodec returns codec by its name in config.
+func ParseCodec(name string) (Codec, bool) {
+	codec, ok := codecNames[name]
+	return codec, ok
+}
+
+func (c Codec) String() string {
+	for name, codec := range codecNames {
+		if codec == c {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("codec(%d)", c)
+}
+
+var (
+	flateWriters = sync.Pool{New: func() any {
+		writer, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return writer
+	}}
+	flateReaders = sync.Pool{New: func() any {
+		return flate.NewReader(nil)
+	}}
+)
+
+// compress returns compressed payload and its codec, payload is left as is if
+// compression doesn't make it smaller.
+func compress(codec Codec, payload []byte) (Codec, []byte) {
+	if codec != CodecFlate || len(payload) < compressMinSize {
+		return CodecNone, payload
+	}
+
+	buffer := bytes.NewBuffer(make([]byte, 0, len(payload)/2))
+	writer := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(writer)
+
+	writer.Reset(buffer)
+	if _, err := writer.Write(payload); err != nil {
+		return CodecNone, payload
+	}
+	if err := writer.Close(); err != nil || buffer.Len() >= len(payload) {
+		return CodecNone, payload
+	}
+
+	return CodecFlate, buffer.Bytes()
+}
+
+func decompress(codec Codec, payload []byte) ([]byte, error) {
+	switch codec {
+	case CodecNone:
+		return payload, nil
+	case CodecFlate:
+		reader := flateReaders.Get().(io.ReadCloser)
+		defer flateReaders.Put(reader)
+
+		if err := reader.(flate.Resetter).Reset(bytes.NewReader(payload), nil); err != nil {
+			return nil, err
+		}
+		return io.ReadAll(reader)
+	}
+
+	return nil, fmt.Errorf("unknown %s", codec)
+}
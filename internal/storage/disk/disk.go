@@ -2,105 +2,377 @@ package disk
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
+)
+
+const (
+	probeFilename = ".probe"
+	probeSize     = 4 << 10
 )
 
 type Disk struct {
 	directory string
 	segment   *Segment
+	keys      *Keyring
 	log       *slog.Logger
+
+	// removeMu serializes removal of segments by snapshots and retention.
+	removeMu  sync.Mutex
+	retention *retention
+	replicas  ReplicaTracker
 }
 
-func NewDisk(dataDirectory string, maxSegmentSize int, log *slog.Logger) *Disk {
+func NewDisk(dataDirectory string, maxSegmentSize int, log *slog.Logger, opts ...DiskOption) *Disk {
 	if err := os.MkdirAll(dataDirectory, 0777); err != nil && !errors.Is(err, fs.ErrExist) {
 		log.Error("failed to create directory", slog.String("directory", dataDirectory), slog.Any("error", err))
 		return nil
 	}
 
-	return &Disk{
+	disk := &Disk{
 		directory: dataDirectory,
 		segment:   NewSegment(maxSegmentSize, dataDirectory, log),
 		log:       log,
 	}
+
+	for _, opt := range opts {
+		opt(disk)
+	}
+
+	return disk
+}
+
+func (d *Disk) WriteSegment(lsn uint64, data []byte) error {
+	return d.segment.Write(lsn, data)
 }
 
-func (d *Disk) WriteSegment(data []byte) error {
-	return d.segment.Write(data)
+// Sync commits written records of the current segment to stable storage.
+func (d *Disk) Sync() error {
+	return d.segment.Sync()
 }
 
-func (d *Disk) ReadSegments() ([]byte, error) {
-	entries, err := os.ReadDir(d.directory)
+// ReadSegments calls fn for every record of the segments in order, segments
+// are read one by one, so only one of them is kept in memory. A corrupted tail
+// of the last segment is a torn write, it's truncated. Corruption of any other
+// segment can't be repaired, so it's returned as ErrCorruptedSegment.
+func (d *Disk) ReadSegments(fn func(Record) error) error {
+	segments, err := d.segments()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var data []byte
-	for i := range entries {
-		segment, err := os.ReadFile(filepath.Join(d.directory, entries[i].Name()))
+	progress := newReadProgress(len(segments))
+	var last uint64
+	var started bool
+	for i, segment := range segments {
+		records, size, err := d.readSegment(segment, i == len(segments)-1)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		data = append(data, segment...)
+		for _, record := range records {
+			// Legacy records may have no lsn, the rest must be ordered.
+			if record.Version != FormatLegacy {
+				if started && record.LSN <= last {
+					return fmt.Errorf("%w %s: lsn %d follows lsn %d", ErrCorruptedSegment, segment.name, record.LSN, last)
+				}
+				last, started = record.LSN, true
+			}
+
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+
+		if len(records) > 0 {
+			progress.lsn = records[len(records)-1].LSN
+		}
+		progress.segmentDone(size, d.log)
 	}
 
-	return data, nil
+	progress.done(d.log)
+	return nil
 }
 
-func (d *Disk) NextSegment(filename string) (string, error) {
-	entries, err := os.ReadDir(d.directory)
+func (d *Disk) readSegment(segment segmentFile, last bool) ([]Record, int, error) {
+	filename := segment.name
+	data, err := os.ReadFile(filepath.Join(d.directory, filename))
 	if err != nil {
-		return "", err
+		return nil, 0, err
 	}
 
-	return upperBound(entries, filename), nil
-}
+	// Preallocated segment the crash happened before the header of was
+	// written to.
+	if last && len(data) > 0 && zeroed(data) {
+		if err := d.truncate(filename, 0); err != nil {
+			return nil, 0, err
+		}
+		d.log.Info("truncated preallocated segment without records", slog.String("segment", filename))
+		return nil, len(data), nil
+	}
 
-func upperBound(entries []os.DirEntry, filename string) string {
-	l, r := 0, len(entries)-1
-	for l <= r {
-		m := (l + r) / 2
-		if entries[m].Name() <= filename {
-			l = m + 1
-		} else {
-			r = m - 1
+	if !segment.legacy {
+		header, ok, err := DecodeSegmentHeader(data)
+		if err == nil && len(data) > 0 && (!ok || header.FirstLSN != segment.lsn) {
+			return nil, 0, fmt.Errorf("%w %s: header doesn't match lsn of the segment", ErrCorruptedSegment, filename)
 		}
 	}
 
-	if l >= len(entries)-1 {
-		return ""
+	records, valid, err := DecodeSegment(data, d.keys)
+	if err == nil {
+		return records, len(data), nil
+	}
+
+	if errors.Is(err, ErrUnsupportedVersion) || errors.Is(err, ErrUnknownKey) || errors.Is(err, ErrDecryption) {
+		return nil, 0, fmt.Errorf("segment %s: %w", filename, err)
+	}
+	if !last {
+		d.log.Error("sealed segment is corrupted",
+			slog.String("segment", filename),
+			slog.Int("size", len(data)),
+			slog.Any("error", err),
+		)
+		return nil, 0, fmt.Errorf("%w %s: %w", ErrCorruptedSegment, filename, err)
+	}
+
+	if err := d.truncate(filename, valid); err != nil {
+		return nil, 0, err
+	}
+	if zeroed(data[valid:]) {
+		d.log.Info("truncated preallocated tail of the last segment",
+			slog.String("segment", filename),
+			slog.Int("offset", valid),
+		)
+		return records, len(data), nil
 	}
-	return entries[l].Name()
+	d.log.Warn("truncated torn write at the end of the last segment",
+		slog.String("segment", filename),
+		slog.Int("offset", valid),
+		slog.Int("dropped_bytes", len(data)-valid),
+		slog.Any("error", err),
+	)
+
+	return records, len(data), nil
 }
 
-func (d *Disk) LastSegment() (string, error) {
-	entries, err := os.ReadDir(d.directory)
+// zeroed reports whether data is a preallocated space nothing was written to.
+func zeroed(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d *Disk) truncate(filename string, size int) error {
+	file, err := os.OpenFile(filepath.Join(d.directory, filename), os.O_WRONLY, 0666)
 	if err != nil {
-		return "", err
+		return err
 	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			d.log.Warn("failed to close file", slog.String("filename", filename), slog.Any("error", err))
+		}
+	}()
 
-	if len(entries) == 0 {
-		return "", nil
+	if err := file.Truncate(int64(size)); err != nil {
+		return err
 	}
 
-	return entries[len(entries)-1].Name(), nil
+	return file.Sync()
 }
 
-func (d *Disk) WriteFile(filename string, data []byte) error {
-	file, err := os.OpenFile(filepath.Join(d.directory, filename), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+// ReadSegmentsFrom calls fn for every record of the segments starting from
+// the one holding the lsn, records of that segment preceding the lsn are
+// passed too. Unlike ReadSegments it's safe to call while the log is written:
+// the tail of the last segment which isn't a complete record yet is skipped,
+// nothing is truncated. If the segments holding the lsn were removed,
+// ErrSegmentsRemoved is returned.
+func (d *Disk) ReadSegmentsFrom(lsn uint64, fn func(Record) error) error {
+	segments, err := d.segments()
+	if err != nil || len(segments) == 0 {
+		return err
+	}
+
+	first, err := d.firstLSN(segments[0])
 	if err != nil {
 		return err
 	}
+	if first > lsn {
+		return ErrSegmentsRemoved
+	}
+
+	start := 0
+	for start+1 < len(segments) && !segments[start+1].legacy && segments[start+1].lsn <= lsn {
+		start++
+	}
+
+	for i := start; i < len(segments); i++ {
+		records, err := d.readWrittenSegment(segments[i], i == len(segments)-1)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readWrittenSegment reads records of the segment, the last segment may be
+// still written, so its incomplete tail is skipped.
+func (d *Disk) readWrittenSegment(segment segmentFile, last bool) ([]Record, error) {
+	data, err := os.ReadFile(filepath.Join(d.directory, segment.name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrSegmentsRemoved
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Preallocated segment nothing is written to yet.
+	if last && zeroed(data) {
+		return nil, nil
+	}
+
+	records, _, err := DecodeSegment(data, d.keys)
+	if err == nil {
+		return records, nil
+	}
+	if errors.Is(err, ErrUnsupportedVersion) || errors.Is(err, ErrUnknownKey) || errors.Is(err, ErrDecryption) {
+		return nil, fmt.Errorf("segment %s: %w", segment.name, err)
+	}
+	if !last {
+		return nil, fmt.Errorf("%w %s: %w", ErrCorruptedSegment, segment.name, err)
+	}
+
+	return records, nil
+}
+
+func (d *Disk) firstLSN(segment segmentFile) (uint64, error) {
+	if !segment.legacy {
+		return segment.lsn, nil
+	}
+
+	header, _, err := d.readSegmentHeader(segment.name)
+	return header.FirstLSN, err
+}
+
+// RemoveSegmentsBefore removes sealed segments with all records preceding the
+// lsn, the segment is known to be such if the following one starts before
+// the lsn. Segments connected replicas haven't received yet aren't removed,
+// unless the hard cap of retention policy is hit.
+func (d *Disk) RemoveSegmentsBefore(lsn uint64) ([]string, error) {
+	d.removeMu.Lock()
+	defer d.removeMu.Unlock()
+
+	if d.retention != nil {
+		d.retention.snapshotLSN, d.retention.snapshot = lsn, true
+	}
+
+	segments, total, err := d.segmentStats()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for i := 0; i+1 < len(segments); i++ {
+		next, ok, err := d.readSegmentHeader(segments[i+1].name)
+		if err != nil {
+			return removed, err
+		}
+		if !ok || next.FirstLSN > lsn {
+			break
+		}
+		if d.retention != nil && len(segments)-i <= d.retention.policy.MinSegments {
+			break
+		}
+		released, err := d.releasedByReplicas(segments[i].segmentFile, segments[i+1].segmentFile, total)
+		if err != nil {
+			return removed, err
+		}
+		if !released {
+			break
+		}
+
+		if err := os.Remove(filepath.Join(d.directory, segments[i].name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, segments[i].name)
+		total -= segments[i].size
+	}
+
+	if len(removed) > 0 {
+		d.log.Info("removed segments covered by snapshot",
+			slog.Uint64("lsn", lsn),
+			slog.Int("segments", len(removed)),
+		)
+	}
+
+	return removed, nil
+}
+
+func (d *Disk) readSegmentHeader(filename string) (SegmentHeader, bool, error) {
+	file, err := os.Open(filepath.Join(d.directory, filename))
+	if err != nil {
+		return SegmentHeader{}, false, err
+	}
 	defer func() {
 		if err := file.Close(); err != nil {
 			d.log.Warn("failed to close file", slog.String("filename", filename), slog.Any("error", err))
 		}
 	}()
 
-	if _, err = file.Write(data); err != nil {
+	data := make([]byte, segmentHeaderSize)
+	n, err := io.ReadFull(file, data)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return SegmentHeader{}, false, err
+	}
+
+	return DecodeSegmentHeader(data[:n])
+}
+
+func (d *Disk) LastSegment() (string, error) {
+	segments, err := d.segments()
+	if err != nil || len(segments) == 0 {
+		return "", err
+	}
+
+	return segments[len(segments)-1].name, nil
+}
+
+func (d *Disk) SegmentsCount() (int, error) {
+	segments, err := d.segments()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(segments), nil
+}
+
+// Probe checks that the directory is writable by writing and syncing a small
+// hidden file.
+func (d *Disk) Probe() (err error) {
+	filename := filepath.Join(d.directory, probeFilename)
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, file.Close(), os.Remove(filename))
+	}()
+
+	if _, err := file.Write(make([]byte, probeSize)); err != nil {
 		return err
 	}
 
@@ -0,0 +1,31 @@
+package disk
+
+type DiskOption func(*Disk)
+
+// WithCompression sets codec new records are compressed with, records are
+// decoded with the codec they were written with regardless of it.
+func WithCompression(codec Codec) DiskOption {
+	return func(d *Disk) {
+		d.segment.codec = codec
+	}
+}
+
+// WithKeyring enables encryption, new segments are encrypted with the current
+// key of the keyring, the rest of the keys are used to read old segments.
+func WithKeyring(keys *Keyring) DiskOption {
+	return func(d *Disk) {
+		d.keys = keys
+		d.segment.keys = keys
+	}
+}
+
+// WithPreallocation enables preallocation of new segments up to max segment
+// size, the file of the next segment is prepared in background.
+func WithPreallocation(enabled bool) DiskOption {
+	return func(d *Disk) {
+		d.segment.preallocated = enabled
+		if enabled {
+			d.segment.next = make(chan preparedSegment, 1)
+		}
+	}
+}
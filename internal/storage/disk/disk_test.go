@@ -1,11 +1,14 @@
 package disk
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -34,10 +37,10 @@ func TestWriteSegment_WithOverflow(t *testing.T) {
 	disk := NewDisk(dir, maxSegmentSize, slog.New(slog.NewJSONHandler(io.Discard, nil)))
 
 	testData := "testdata"
-	iterationsNumber := maxSegmentSize/len(testData) + 2
+	iterationsNumber := (maxSegmentSize-segmentHeaderSize)/(codecRecordHeaderSize+len(testData)) + 2
 
-	for range iterationsNumber {
-		err := disk.WriteSegment([]byte(testData))
+	for i := range iterationsNumber {
+		err := disk.WriteSegment(uint64(i), []byte(testData))
 		require.NoError(t, err)
 	}
 
@@ -46,40 +49,145 @@ func TestWriteSegment_WithOverflow(t *testing.T) {
 	assert.Len(t, entries, 2)
 }
 
+func readRecords(disk *Disk) ([]Record, error) {
+	var records []Record
+	err := disk.ReadSegments(func(record Record) error {
+		records = append(records, record)
+		return nil
+	})
+
+	return records, err
+}
+
+func encodeSegment(firstLSN uint64, payloads ...string) string {
+	data := EncodeSegmentHeader(SegmentHeader{Version: FormatVersion, FirstLSN: firstLSN})
+	for i, payload := range payloads {
+		data = append(data, EncodeCodecRecord(firstLSN+uint64(i), []byte(payload), CodecNone)...)
+	}
+
+	return string(data)
+}
+
 func TestReadSegments(t *testing.T) {
 	dir := t.TempDir()
 	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
-	testData1 := "lorem ipsum 1"
-	testData2 := "lorem ipsum 2"
-	testData3 := "lorem ipsum 3"
-	_ = createLogFiles(t, dir, 3, []string{testData1, testData2, testData3})
+	_ = createLogFiles(t, dir, 3, []string{
+		string(EncodeRecord(1, []byte("legacy"))),
+		encodeSegment(2, "lorem ipsum 2", "lorem ipsum 3"),
+		encodeSegment(4, "lorem ipsum 4"),
+	})
 
-	data, err := disk.ReadSegments()
+	records, err := readRecords(disk)
 	require.NoError(t, err)
-	assert.Equal(t, testData1+testData2+testData3, string(data))
+	assert.Equal(t, []Record{
+		{LSN: 1, Payload: []byte("legacy"), Version: FormatLegacy},
+		{LSN: 2, Payload: []byte("lorem ipsum 2"), Version: FormatVersion},
+		{LSN: 3, Payload: []byte("lorem ipsum 3"), Version: FormatVersion},
+		{LSN: 4, Payload: []byte("lorem ipsum 4"), Version: FormatVersion},
+	}, records)
 }
 
-func TestNextSegment(t *testing.T) {
+func TestReadSegments_WrittenSegments(t *testing.T) {
 	dir := t.TempDir()
 	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
 
-	segments := createLogFiles(t, dir, 3, nil)
+	require.NoError(t, disk.WriteSegment(7, []byte("lorem")))
+	require.NoError(t, disk.WriteSegment(8, []byte("ipsum")))
 
-	nextSegment, err := disk.NextSegment("")
+	records, err := readRecords(disk)
 	require.NoError(t, err)
-	assert.Equal(t, segments[0], nextSegment)
+	assert.Equal(t, []Record{
+		{LSN: 7, Payload: []byte("lorem"), Version: FormatVersion},
+		{LSN: 8, Payload: []byte("ipsum"), Version: FormatVersion},
+	}, records)
+}
 
-	nextSegment, err = disk.NextSegment(segments[0])
-	require.NoError(t, err)
-	assert.Equal(t, segments[1], nextSegment)
+func TestReadSegments_CallbackError(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	require.NoError(t, disk.WriteSegment(1, []byte("lorem")))
+	require.NoError(t, disk.WriteSegment(2, []byte("ipsum")))
+
+	expectedErr := errors.New("apply error")
+	calls := 0
+	err := disk.ReadSegments(func(Record) error {
+		calls++
+		return expectedErr
+	})
+	assert.ErrorIs(t, err, expectedErr)
+	assert.Equal(t, 1, calls)
+}
+
+func TestReadSegments_TornWrite(t *testing.T) {
+	tests := []struct {
+		name            string
+		segment         string
+		expectedSize    int
+		expectedRecords int
+	}{
+		{
+			name:            "torn record",
+			segment:         encodeSegment(2, "lorem ipsum 2", "lorem ipsum 3")[:segmentHeaderSize+2*codecRecordHeaderSize+20],
+			expectedSize:    segmentHeaderSize + codecRecordHeaderSize + len("lorem ipsum 2"),
+			expectedRecords: 2,
+		},
+		{
+			name:            "torn header",
+			segment:         encodeSegment(2)[:10],
+			expectedSize:    0,
+			expectedRecords: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+			segments := createLogFiles(t, dir, 2, []string{encodeSegment(1, "lorem ipsum 1"), tt.segment})
+
+			records, err := readRecords(disk)
+			require.NoError(t, err)
+			assert.Len(t, records, tt.expectedRecords)
+
+			info, err := os.Stat(filepath.Join(dir, segments[1]))
+			require.NoError(t, err)
+			assert.Equal(t, int64(tt.expectedSize), info.Size())
+		})
+	}
+}
+
+func TestReadSegments_CorruptedSealedSegment(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	segment := encodeSegment(1, "lorem ipsum")
+	corrupted := []byte(segment)
+	corrupted[segmentHeaderSize+recordHeaderSize] ^= 0xff
+	segments := createLogFiles(t, dir, 2, []string{string(corrupted), encodeSegment(2, "lorem ipsum")})
 
-	nextSegment, err = disk.NextSegment(segments[1])
+	_, err := readRecords(disk)
+	assert.ErrorIs(t, err, ErrCorruptedSegment)
+	assert.ErrorContains(t, err, segments[0])
+
+	data, err := os.ReadFile(filepath.Join(dir, segments[0]))
 	require.NoError(t, err)
-	assert.Equal(t, "", nextSegment)
+	assert.Equal(t, corrupted, data)
+}
+
+func TestReadSegments_UnsupportedVersion(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
 
-	nextSegment, err = disk.NextSegment(segments[2])
+	segment := string(EncodeSegmentHeader(SegmentHeader{Version: FormatVersion + 1, FirstLSN: 1}))
+	segments := createLogFiles(t, dir, 1, []string{segment})
+
+	_, err := readRecords(disk)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+
+	info, err := os.Stat(filepath.Join(dir, segments[0]))
 	require.NoError(t, err)
-	assert.Equal(t, "", nextSegment)
+	assert.Equal(t, int64(len(segment)), info.Size())
 }
 
 func TestSegments_EmptyDir(t *testing.T) {
@@ -91,12 +199,6 @@ func TestSegments_EmptyDir(t *testing.T) {
 		expectedErr error
 		call        func() (string, error)
 	}{
-		{
-			name: "next segment empty dir",
-			call: func() (string, error) {
-				return disk.NextSegment("")
-			},
-		},
 		{
 			name: "last segment empty dir",
 			call: func() (string, error) {
@@ -125,16 +227,669 @@ func TestLastSegment(t *testing.T) {
 	assert.Equal(t, segments[2], segment)
 }
 
-func TestWriteFile(t *testing.T) {
+func TestRemoveSegmentsBefore(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	segments := createLogFiles(t, dir, 4, []string{
+		encodeSegment(0, "lorem", "ipsum"),
+		encodeSegment(2, "lorem", "ipsum"),
+		encodeSegment(4, "lorem", "ipsum"),
+		encodeSegment(6, "lorem"),
+	})
+
+	removed, err := disk.RemoveSegmentsBefore(5)
+	require.NoError(t, err)
+	assert.Equal(t, segments[:2], removed)
+
+	removed, err = disk.RemoveSegmentsBefore(100)
+	require.NoError(t, err)
+	assert.Equal(t, segments[2:3], removed)
+
+	last, err := disk.LastSegment()
+	require.NoError(t, err)
+	assert.Equal(t, segments[3], last)
+
+	err = disk.ReadSegmentsFrom(3, func(Record) error { return nil })
+	assert.ErrorIs(t, err, ErrSegmentsRemoved)
+}
+
+func TestRemoveSegmentsBefore_Replicas(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	segments := writeTestSegments(t, disk, 1, 2, 3, 4, 5)
+	disk.TrackReplicas(testReplicas{lsn: 3, ok: true})
+
+	removed, err := disk.RemoveSegmentsBefore(5)
+	require.NoError(t, err)
+	assert.Equal(t, segments[:2], removed, "segments replica hasn't received are kept without retention policy")
+}
+
+func TestRemoveSegmentsBefore_LegacySegments(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	_ = createLogFiles(t, dir, 2, nil)
+
+	removed, err := disk.RemoveSegmentsBefore(100)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+}
+
+func TestWriteSegment_NamedByLSN(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	for _, lsn := range []uint64{7, 9, 12} {
+		require.NoError(t, disk.WriteSegment(lsn, []byte("lorem")))
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{
+		"wal_00000000000000000007.log",
+		"wal_00000000000000000009.log",
+		"wal_00000000000000000012.log",
+	}, names)
+
+	header, ok, err := disk.readSegmentHeader(names[1])
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, uint64(9), header.FirstLSN)
+}
+
+func TestSegments_LSNOrder(t *testing.T) {
 	dir := t.TempDir()
 	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
 
-	testdata := "lorem ipsum"
-	err := disk.WriteFile("newfile.log", []byte(testdata))
+	// Lexical order of the names differs from lsn order, legacy segments
+	// precede the ones named by lsn.
+	files := map[string]string{
+		"wal_1700000000000.log":        encodeSegment(0, "lorem"),
+		segmentFilename(1):             encodeSegment(1, "ipsum"),
+		segmentFilename(10):            encodeSegment(10, "dolor"),
+		segmentFilename(9):             encodeSegment(9, "sit"),
+		"wal_00000000000000000100.log": encodeSegment(100, "amet"),
+	}
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0666))
+	}
+
+	records, err := readRecords(disk)
+	require.NoError(t, err)
+	var lsns []uint64
+	for _, record := range records {
+		lsns = append(lsns, record.LSN)
+	}
+	assert.Equal(t, []uint64{0, 1, 9, 10, 100}, lsns)
+
+	last, err := disk.LastSegment()
+	require.NoError(t, err)
+	assert.Equal(t, segmentFilename(100), last)
+
+	lsns = nil
+	require.NoError(t, disk.ReadSegmentsFrom(9, func(record Record) error {
+		lsns = append(lsns, record.LSN)
+		return nil
+	}))
+	assert.Equal(t, []uint64{9, 10, 100}, lsns)
+}
+
+func TestReadSegmentsFrom(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	last := encodeSegment(5, "dolor", "sit")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(1)), []byte(encodeSegment(1, "lorem", "ipsum")), 0666))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(3)), []byte(encodeSegment(3, "amet", "consectetur")), 0666))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(5)), []byte(last[:len(last)-2]), 0666))
+
+	readFrom := func(lsn uint64) ([]uint64, error) {
+		var lsns []uint64
+		err := disk.ReadSegmentsFrom(lsn, func(record Record) error {
+			lsns = append(lsns, record.LSN)
+			return nil
+		})
+		return lsns, err
+	}
+
+	lsns, err := readFrom(0)
+	assert.ErrorIs(t, err, ErrSegmentsRemoved)
+	assert.Empty(t, lsns)
+
+	lsns, err = readFrom(4)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3, 4, 5}, lsns, "incomplete record is skipped")
+
+	info, err := os.Stat(filepath.Join(dir, segmentFilename(5)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(last)-2), info.Size(), "last segment isn't truncated")
+
+	lsns, err = readFrom(100)
 	require.NoError(t, err)
+	assert.Equal(t, []uint64{5}, lsns)
+}
+
+func TestReadSegments_HeaderLSNMismatch(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(1)), []byte(encodeSegment(5, "lorem")), 0666))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(6)), []byte(encodeSegment(6, "ipsum")), 0666))
+
+	_, err := readRecords(disk)
+	assert.ErrorIs(t, err, ErrCorruptedSegment)
+}
+
+func TestReadSegments_LSNOutOfOrder(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(5)), []byte(encodeSegment(5, "lorem", "ipsum")), 0666))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(6)), []byte(encodeSegment(6, "dolor")), 0666))
+
+	_, err := readRecords(disk)
+	assert.ErrorIs(t, err, ErrCorruptedSegment)
+}
+
+func TestWriteSegment_AfterTruncatedSegment(t *testing.T) {
+	dir := t.TempDir()
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	data := encodeSegment(3, "lorem")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(1)), []byte(encodeSegment(1, "lorem", "ipsum")), 0666))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(3)), []byte(data[:len(data)-1]), 0666))
 
-	data, err := os.ReadFile(filepath.Join(dir, "/newfile.log"))
+	disk := NewDisk(dir, 1000, log)
+	records, err := readRecords(disk)
 	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	require.NoError(t, disk.WriteSegment(3, []byte("dolor")))
 
-	assert.Equal(t, testdata, string(data))
+	records, err = readRecords(NewDisk(dir, 1000, log))
+	require.NoError(t, err)
+	assert.Equal(t, []Record{
+		{LSN: 1, Payload: []byte("lorem"), Version: FormatVersion},
+		{LSN: 2, Payload: []byte("ipsum"), Version: FormatVersion},
+		{LSN: 3, Payload: []byte("dolor"), Version: FormatVersion},
+	}, records)
+}
+
+func TestWriteSegment_Compression(t *testing.T) {
+	dir := t.TempDir()
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	payload := []byte(strings.Repeat(`{"name":"Daniil","city":"Moscow"}`, 20))
+	short := []byte("lorem")
+
+	// Records of the same segment may be written with different codecs.
+	plain := NewDisk(dir, 1<<20, log)
+	require.NoError(t, plain.WriteSegment(1, payload))
+	compressed := NewDisk(dir, 1<<20, log, WithCompression(CodecFlate))
+	require.NoError(t, compressed.WriteSegment(2, payload))
+	require.NoError(t, compressed.WriteSegment(3, short))
+
+	records, err := readRecords(NewDisk(dir, 1<<20, log))
+	require.NoError(t, err)
+	assert.Equal(t, []Record{
+		{LSN: 1, Payload: payload, Version: FormatVersion, Codec: CodecNone},
+		{LSN: 2, Payload: payload, Version: FormatVersion, Codec: CodecFlate},
+		{LSN: 3, Payload: short, Version: FormatVersion, Codec: CodecNone},
+	}, records)
+
+	assert.Less(t, len(EncodeCodecRecord(2, payload, CodecFlate)), len(payload)/4)
+}
+
+func newTestKeyring(t *testing.T, current uint32, ids ...uint32) *Keyring {
+	keys := make(map[uint32][]byte)
+	for _, id := range ids {
+		keys[id] = bytes.Repeat([]byte{byte(id)}, keySize)
+	}
+
+	keyring, err := NewKeyring(keys, current)
+	require.NoError(t, err)
+	return keyring
+}
+
+func TestWriteSegment_Encryption(t *testing.T) {
+	dir := t.TempDir()
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	payload := []byte(strings.Repeat("Daniil ", 30))
+	disk := NewDisk(dir, 1<<20, log, WithKeyring(newTestKeyring(t, 1, 1)), WithCompression(CodecFlate))
+	require.NoError(t, disk.WriteSegment(1, payload))
+
+	// Key is rotated, new segment is encrypted with the new key.
+	disk = NewDisk(dir, 1<<20, log, WithKeyring(newTestKeyring(t, 2, 1, 2)))
+	require.NoError(t, disk.WriteSegment(2, []byte("lorem")))
+
+	for _, name := range []string{segmentFilename(1), segmentFilename(2)} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "Daniil")
+		assert.NotContains(t, string(data), "lorem")
+	}
+
+	header, _, err := disk.readSegmentHeader(segmentFilename(2))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), header.KeyID)
+
+	records, err := readRecords(disk)
+	require.NoError(t, err)
+	assert.Equal(t, []Record{
+		{LSN: 1, Payload: payload, Version: FormatVersion, Codec: CodecFlate},
+		{LSN: 2, Payload: []byte("lorem"), Version: FormatVersion, Codec: CodecNone},
+	}, records)
+}
+
+func TestReadSegments_WrongKey(t *testing.T) {
+	dir := t.TempDir()
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	disk := NewDisk(dir, 1<<20, log, WithKeyring(newTestKeyring(t, 1, 1)))
+	require.NoError(t, disk.WriteSegment(1, []byte("lorem")))
+	info, err := os.Stat(filepath.Join(dir, segmentFilename(1)))
+	require.NoError(t, err)
+
+	wrongKey, err := NewKeyring(map[uint32][]byte{1: bytes.Repeat([]byte{7}, keySize)}, 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		disk        *Disk
+		expectedErr error
+	}{
+		{name: "wrong key", disk: NewDisk(dir, 1<<20, log, WithKeyring(wrongKey)), expectedErr: ErrDecryption},
+		{name: "unknown key", disk: NewDisk(dir, 1<<20, log, WithKeyring(newTestKeyring(t, 2, 2))), expectedErr: ErrUnknownKey},
+		{name: "encryption disabled", disk: NewDisk(dir, 1<<20, log), expectedErr: ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readRecords(tt.disk)
+			assert.ErrorIs(t, err, tt.expectedErr)
+
+			// Segment isn't truncated as a torn write.
+			after, err := os.Stat(filepath.Join(dir, segmentFilename(1)))
+			require.NoError(t, err)
+			assert.Equal(t, info.Size(), after.Size())
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	sealed := encodeSegment(1, "lorem", "ipsum")
+	last := encodeSegment(3, "dolor") + "torn"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(1)), []byte(sealed), 0666))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(3)), []byte(last), 0666))
+
+	var infos []SegmentInfo
+	var records int
+	err := disk.Inspect(func(info SegmentInfo, segmentRecords []Record) error {
+		infos = append(infos, info)
+		records += len(segmentRecords)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, records)
+
+	require.Len(t, infos, 2)
+	assert.NoError(t, infos[0].Err)
+	assert.Equal(t, uint64(1), infos[0].FirstLSN)
+	assert.Equal(t, uint64(2), infos[0].LastLSN)
+	assert.Equal(t, len(sealed), infos[0].Valid)
+	assert.ErrorIs(t, infos[1].Err, ErrCorruptedRecord)
+	assert.Equal(t, len(last)-len("torn"), infos[1].Valid)
+	assert.Equal(t, len(last), infos[1].Size)
+
+	// Inspect doesn't repair segments.
+	data, err := os.ReadFile(filepath.Join(dir, segmentFilename(3)))
+	require.NoError(t, err)
+	assert.Equal(t, last, string(data))
+}
+
+func TestTruncateSegment(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	segment := encodeSegment(3, "dolor")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(3)), []byte(segment+"torn"), 0666))
+
+	dropped, err := disk.TruncateSegment(segmentFilename(3))
+	require.NoError(t, err)
+	assert.Equal(t, len("torn"), dropped)
+
+	dropped, err = disk.TruncateSegment(segmentFilename(3))
+	require.NoError(t, err)
+	assert.Zero(t, dropped)
+
+	data, err := os.ReadFile(filepath.Join(dir, segmentFilename(3)))
+	require.NoError(t, err)
+	assert.Equal(t, segment, string(data))
+
+	_, err = disk.TruncateSegment("../" + segmentFilename(3))
+	assert.Error(t, err)
+}
+
+func TestTruncateSegment_HeaderLSNMismatch(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1000, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	segment := encodeSegment(3, "dolor")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segmentFilename(5)), []byte(segment), 0666))
+
+	_, err := disk.TruncateSegment(segmentFilename(5))
+	assert.ErrorIs(t, err, ErrCorruptedSegment)
+
+	data, err := os.ReadFile(filepath.Join(dir, segmentFilename(5)))
+	require.NoError(t, err)
+	assert.Equal(t, segment, string(data))
+}
+
+func TestWriteSegment_AfterFailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1<<20, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	require.NoError(t, disk.WriteSegment(1, []byte("lorem")))
+	require.NoError(t, disk.Sync())
+
+	// Tail of the record written partially before the failure.
+	_, err := disk.segment.file.Write([]byte("torn"))
+	require.NoError(t, err)
+	disk.segment.failed = true
+
+	require.NoError(t, disk.WriteSegment(2, []byte("ipsum")))
+	records, err := readRecords(disk)
+	require.NoError(t, err)
+	assert.Equal(t, []Record{
+		{LSN: 1, Payload: []byte("lorem"), Version: FormatVersion},
+		{LSN: 2, Payload: []byte("ipsum"), Version: FormatVersion},
+	}, records)
+}
+
+func TestWriteSegment_AfterFailedHeaderWrite(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1<<20, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	require.NoError(t, disk.segment.rotateSegment(1))
+	_, err := disk.segment.file.Write([]byte("torn"))
+	require.NoError(t, err)
+	disk.segment.failed = true
+
+	require.NoError(t, disk.WriteSegment(2, []byte("ipsum")))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, segmentFilename(2), entries[0].Name())
+}
+
+func TestProbe(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1<<20, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	require.NoError(t, disk.Probe())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Probe left by crash isn't a segment.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, probeFilename), []byte("probe"), 0666))
+	count, err := disk.SegmentsCount()
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	require.NoError(t, os.Chmod(dir, 0555))
+	t.Cleanup(func() { _ = os.Chmod(dir, 0777) })
+	if os.Geteuid() != 0 {
+		assert.Error(t, disk.Probe())
+	}
+}
+
+type testReplicas struct {
+	lsn uint64
+	ok  bool
+}
+
+func (r testReplicas) RetainedLSN() (uint64, bool) {
+	return r.lsn, r.ok
+}
+
+func writeTestSegments(t *testing.T, disk *Disk, lsns ...uint64) []string {
+	for _, lsn := range lsns {
+		require.NoError(t, disk.WriteSegment(lsn, []byte("lorem ipsum")))
+	}
+
+	segments, err := disk.segments()
+	require.NoError(t, err)
+	names := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		names = append(names, segment.name)
+	}
+
+	return names
+}
+
+func TestEnforceRetention(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	segments := writeTestSegments(t, disk, 1, 2, 3, 4, 5)
+	stats, _, err := disk.segmentStats()
+	require.NoError(t, err)
+	size := stats[0].size
+
+	removed, err := disk.EnforceRetention()
+	require.NoError(t, err)
+	assert.Empty(t, removed, "retention isn't started")
+
+	tests := []struct {
+		name     string
+		policy   RetentionPolicy
+		replicas ReplicaTracker
+		removed  []string
+	}{
+		{
+			name:    "within limits",
+			policy:  RetentionPolicy{MaxSize: 5 * size, MaxAge: time.Hour},
+			removed: nil,
+		},
+		{
+			name:    "max size",
+			policy:  RetentionPolicy{MaxSize: 3 * size},
+			removed: segments[:2],
+		},
+		{
+			name:    "last segment is kept",
+			policy:  RetentionPolicy{MaxSize: 1},
+			removed: segments[:4],
+		},
+		{
+			name:    "min segments",
+			policy:  RetentionPolicy{MaxSize: 1, MinSegments: 3},
+			removed: segments[:2],
+		},
+		{
+			name:     "replica lags",
+			policy:   RetentionPolicy{MaxSize: 1},
+			replicas: testReplicas{lsn: 3, ok: true},
+			removed:  segments[:2],
+		},
+		{
+			name:     "replica starts from the beginning",
+			policy:   RetentionPolicy{MaxSize: 1},
+			replicas: testReplicas{ok: true},
+			removed:  nil,
+		},
+		{
+			name:     "no connected replicas",
+			policy:   RetentionPolicy{MaxSize: 1},
+			replicas: testReplicas{},
+			removed:  segments[:4],
+		},
+		{
+			name:     "hard cap",
+			policy:   RetentionPolicy{MaxSize: 1, HardMaxSize: 3 * size},
+			replicas: testReplicas{ok: true},
+			removed:  segments[:2],
+		},
+		{
+			name:    "not covered by snapshot",
+			policy:  RetentionPolicy{MaxSize: 1, Snapshots: true},
+			removed: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			disk := NewDisk(dir, 1, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+			_ = writeTestSegments(t, disk, 1, 2, 3, 4, 5)
+			disk.retention = &retention{policy: tt.policy}
+			disk.replicas = tt.replicas
+
+			removed, err := disk.EnforceRetention()
+			require.NoError(t, err)
+			assert.Equal(t, tt.removed, removed)
+		})
+	}
+}
+
+func TestEnforceRetention_MaxAge(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	segments := writeTestSegments(t, disk, 1, 2, 3)
+	old := time.Now().Add(-2 * time.Hour)
+	for _, segment := range segments {
+		require.NoError(t, os.Chtimes(filepath.Join(dir, segment), old, old))
+	}
+	now := time.Now()
+	require.NoError(t, os.Chtimes(filepath.Join(dir, segments[1]), now, now))
+	disk.retention = &retention{policy: RetentionPolicy{MaxAge: time.Hour}}
+
+	removed, err := disk.EnforceRetention()
+	require.NoError(t, err)
+	assert.Equal(t, segments[:1], removed)
+}
+
+func TestEnforceRetention_Snapshot(t *testing.T) {
+	dir := t.TempDir()
+	disk := NewDisk(dir, 1, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	segments := writeTestSegments(t, disk, 1, 2, 3, 4, 5)
+	disk.retention = &retention{policy: RetentionPolicy{MaxSize: 1, MinSegments: 4, Snapshots: true}}
+	disk.replicas = testReplicas{lsn: 2, ok: true}
+
+	removed, err := disk.RemoveSegmentsBefore(4)
+	require.NoError(t, err)
+	assert.Equal(t, segments[:1], removed, "kept for min segments")
+
+	disk.retention.policy.MinSegments = 0
+	removed, err = disk.EnforceRetention()
+	require.NoError(t, err)
+	assert.Empty(t, removed, "kept for replica")
+
+	disk.replicas = testReplicas{lsn: 5, ok: true}
+	removed, err = disk.EnforceRetention()
+	require.NoError(t, err)
+	assert.Equal(t, segments[1:3], removed, "segments after the snapshot are kept")
+}
+
+func TestWriteSegment_RotatesBeforeLimit(t *testing.T) {
+	dir := t.TempDir()
+	const maxSegmentSize = 100
+	disk := NewDisk(dir, maxSegmentSize, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	for i := range 10 {
+		require.NoError(t, disk.WriteSegment(uint64(i), []byte("lorem ipsum")))
+	}
+	require.NoError(t, disk.WriteSegment(10, bytes.Repeat([]byte("x"), 2*maxSegmentSize)))
+
+	stats, _, err := disk.segmentStats()
+	require.NoError(t, err)
+	require.Len(t, stats, 6)
+	for _, stat := range stats[:len(stats)-1] {
+		assert.LessOrEqual(t, stat.size, maxSegmentSize, stat.name)
+	}
+	assert.Greater(t, stats[len(stats)-1].size, maxSegmentSize, "record larger than segment")
+}
+
+func skipWithoutPreallocation(t *testing.T, dir string) {
+	file, err := os.Create(filepath.Join(dir, ".check"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, file.Close())
+		require.NoError(t, os.Remove(file.Name()))
+	}()
+
+	if err := preallocate(file, 1); err != nil {
+		t.Skip("preallocation is not supported:", err)
+	}
+}
+
+func TestWriteSegment_Preallocation(t *testing.T) {
+	dir := t.TempDir()
+	skipWithoutPreallocation(t, dir)
+	const maxSegmentSize = 100
+	disk := NewDisk(dir, maxSegmentSize, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithPreallocation(true))
+
+	for i := range 10 {
+		require.NoError(t, disk.WriteSegment(uint64(i), []byte("lorem ipsum")))
+	}
+	require.NoError(t, disk.Sync())
+
+	stats, _, err := disk.segmentStats()
+	require.NoError(t, err)
+	require.Len(t, stats, 5)
+	for _, stat := range stats[:len(stats)-1] {
+		assert.Less(t, stat.size, maxSegmentSize, "sealed segment is truncated")
+	}
+	current := stats[len(stats)-1]
+	info, err := os.Stat(filepath.Join(dir, current.name))
+	require.NoError(t, err)
+	assert.Equal(t, int64(maxSegmentSize), info.Size(), "current segment is preallocated")
+	assert.Less(t, current.size, maxSegmentSize, "only written records of current segment are counted")
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, nextSegmentFilename))
+		return err == nil
+	}, time.Second, 10*time.Millisecond, "next segment is prepared")
+
+	// Restarted node reads the segments and cuts off preallocated tail.
+	restarted := NewDisk(dir, maxSegmentSize, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	records, err := readRecords(restarted)
+	require.NoError(t, err)
+	assert.Len(t, records, 10)
+
+	info, err = os.Stat(filepath.Join(dir, current.name))
+	require.NoError(t, err)
+	assert.Less(t, info.Size(), int64(maxSegmentSize))
+}
+
+func TestReadSegments_PreallocatedSegment(t *testing.T) {
+	dir := t.TempDir()
+	segments := []string{segmentFilename(1), segmentFilename(3)}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segments[0]), []byte(encodeSegment(1, "lorem", "ipsum")), 0666))
+	// Crash happened before the header of the preallocated segment was written.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, segments[1]), make([]byte, 100), 0666))
+	disk := NewDisk(dir, 100, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	var infos []SegmentInfo
+	require.NoError(t, disk.Inspect(func(info SegmentInfo, _ []Record) error {
+		infos = append(infos, info)
+		return nil
+	}))
+	require.Len(t, infos, 2)
+	assert.NoError(t, infos[1].Err)
+
+	records, err := readRecords(disk)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	data, err := os.ReadFile(filepath.Join(dir, segments[1]))
+	require.NoError(t, err)
+	assert.Empty(t, data)
 }
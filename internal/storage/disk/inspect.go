@@ -0,0 +1,128 @@
+package disk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SegmentInfo describes segment as it's stored on disk. Valid is the size of
+// the prefix of the segment which was decoded, Err is the reason the rest of
+// it wasn't.
+type SegmentInfo struct {
+	Name      string
+	Size      int
+	Header    SegmentHeader
+	HasHeader bool
+	Records   int
+	FirstLSN  uint64
+	LastLSN   uint64
+	Valid     int
+	Err       error
+}
+
+// Inspect calls fn for every segment in lsn order with its records decoded so
+// far. Unlike ReadSegments it doesn't stop on corrupted segments and doesn't
+// modify them.
+func (d *Disk) Inspect(fn func(SegmentInfo, []Record) error) error {
+	segments, err := d.segments()
+	if err != nil {
+		return err
+	}
+
+	var last uint64
+	var started bool
+	for _, segment := range segments {
+		info, records, err := d.inspectSegment(segment)
+		if err != nil {
+			return err
+		}
+
+		for i, record := range records {
+			if record.Version == FormatLegacy {
+				continue
+			}
+			if started && record.LSN <= last && info.Err == nil {
+				info.Err = fmt.Errorf("%w: lsn %d follows lsn %d", ErrCorruptedSegment, record.LSN, last)
+				info.Records = i
+				records = records[:i]
+				break
+			}
+			last, started = record.LSN, true
+		}
+
+		if err := fn(info, records); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Disk) inspectSegment(segment segmentFile) (SegmentInfo, []Record, error) {
+	data, err := os.ReadFile(filepath.Join(d.directory, segment.name))
+	if err != nil {
+		return SegmentInfo{}, nil, err
+	}
+
+	info := SegmentInfo{Name: segment.name, Size: len(data)}
+	// Segment is preallocated, nothing is written to it yet.
+	if zeroed(data) {
+		return info, nil, nil
+	}
+	info.Header, info.HasHeader, info.Err = DecodeSegmentHeader(data)
+	if info.Err != nil {
+		return info, nil, nil
+	}
+	if !segment.legacy && len(data) > 0 && (!info.HasHeader || info.Header.FirstLSN != segment.lsn) {
+		info.Err = fmt.Errorf("%w: header doesn't match lsn of the segment", ErrCorruptedSegment)
+		return info, nil, nil
+	}
+
+	records, valid, err := DecodeSegment(data, d.keys)
+	info.Records, info.Valid, info.Err = len(records), valid, err
+	if err == nil {
+		info.Valid = len(data)
+	}
+	// Preallocated tail of the segment being written isn't corruption.
+	if err != nil && valid > 0 && zeroed(data[valid:]) && !errors.Is(err, ErrDecryption) && !errors.Is(err, ErrUnknownKey) {
+		info.Err = nil
+	}
+	if len(records) > 0 {
+		info.FirstLSN, info.LastLSN = records[0].LSN, records[len(records)-1].LSN
+	}
+
+	return info, records, nil
+}
+
+// TruncateSegment cuts off corrupted tail of the segment and returns amount
+// of dropped bytes. Segments which can't be decrypted or are written by newer
+// versions aren't truncated, their records are valid.
+func (d *Disk) TruncateSegment(filename string) (int, error) {
+	if filepath.Base(filename) != filename {
+		return 0, fmt.Errorf("invalid segment name %s", filename)
+	}
+
+	segment := parseSegmentFilename(filename)
+	info, _, err := d.inspectSegment(segment)
+	if err != nil {
+		return 0, err
+	}
+	if info.Err == nil {
+		return 0, nil
+	}
+	if errors.Is(info.Err, ErrUnsupportedVersion) || errors.Is(info.Err, ErrUnknownKey) || errors.Is(info.Err, ErrDecryption) {
+		return 0, fmt.Errorf("segment %s: %w", filename, info.Err)
+	}
+	// Renamed segment is intact, it's its name which is wrong.
+	if !segment.legacy && info.HasHeader && info.Header.FirstLSN != segment.lsn {
+		return 0, fmt.Errorf("segment %s: %w", filename, info.Err)
+	}
+
+	if err := d.truncate(filename, info.Valid); err != nil {
+		return 0, err
+	}
+
+	return info.Size - info.Valid, nil
+}
@@ -0,0 +1,84 @@
+package disk
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	segmentPrefix    = "wal_"
+	segmentSuffix    = ".log"
+	segmentLSNDigits = 20
+)
+
+// segmentFile is a segment of the directory. Segments are named by the lsn
+// of their first record, legacy ones were named by creation time, they
+// precede the rest and are ordered by name.
+type segmentFile struct {
+	name   string
+	lsn    uint64
+	legacy bool
+}
+
+func segmentFilename(lsn uint64) string {
+	return fmt.Sprintf("%s%0*d%s", segmentPrefix, segmentLSNDigits, lsn, segmentSuffix)
+}
+
+func parseSegmentFilename(name string) segmentFile {
+	digits, ok := strings.CutPrefix(name, segmentPrefix)
+	if ok {
+		digits, ok = strings.CutSuffix(digits, segmentSuffix)
+	}
+	if !ok || len(digits) != segmentLSNDigits {
+		return segmentFile{name: name, legacy: true}
+	}
+
+	lsn, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return segmentFile{name: name, legacy: true}
+	}
+
+	return segmentFile{name: name, lsn: lsn}
+}
+
+func compareSegments(a, b segmentFile) int {
+	switch {
+	case a.legacy && b.legacy:
+		return cmp.Compare(a.name, b.name)
+	case a.legacy:
+		return -1
+	case b.legacy:
+		return 1
+	}
+
+	return cmp.Compare(a.lsn, b.lsn)
+}
+
+// segments returns segments of the directory in lsn order.
+func (d *Disk) segments() ([]segmentFile, error) {
+	entries, err := os.ReadDir(d.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]segmentFile, 0, len(entries))
+	for _, entry := range entries {
+		// Hidden files aren't segments, see Probe.
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		segments = append(segments, parseSegmentFilename(entry.Name()))
+	}
+	slices.SortFunc(segments, compareSegments)
+
+	return segments, nil
+}
+
+// CompareSegmentNames compares segments by lsn order.
+func CompareSegmentNames(a, b string) int {
+	return compareSegments(parseSegmentFilename(a), parseSegmentFilename(b))
+}
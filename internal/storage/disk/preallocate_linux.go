@@ -0,0 +1,18 @@
+package disk
+
+import (
+	"os"
+	"syscall"
+)
+
+// preallocate allocates blocks of the file up to the size, so appends don't
+// grow it.
+func preallocate(file *os.File, size int) error {
+	return syscall.Fallocate(int(file.Fd()), 0, 0, int64(size))
+}
+
+// syncData commits data of the file, metadata such as modification time is
+// committed only if it's needed to read the data.
+func syncData(file *os.File) error {
+	return syscall.Fdatasync(int(file.Fd()))
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package disk
+
+import (
+	"errors"
+	"os"
+)
+
+func preallocate(*os.File, int) error {
+	return errors.ErrUnsupported
+}
+
+func syncData(file *os.File) error {
+	return file.Sync()
+}
@@ -0,0 +1,56 @@
+package disk
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const progressLogInterval = 5 * time.Second
+
+// readProgress logs progress of reading segments, at most once per
+// progressLogInterval.
+type readProgress struct {
+	started  time.Time
+	logged   time.Time
+	total    int
+	segments int
+	bytes    int64
+	lsn      uint64
+}
+
+func newReadProgress(total int) *readProgress {
+	now := time.Now()
+	return &readProgress{started: now, logged: now, total: total}
+}
+
+func (p *readProgress) segmentDone(size int, log *slog.Logger) {
+	p.segments++
+	p.bytes += int64(size)
+
+	if time.Since(p.logged) >= progressLogInterval {
+		p.logged = time.Now()
+		log.Info("reading segments", p.attrs()...)
+	}
+}
+
+func (p *readProgress) done(log *slog.Logger) {
+	log.Info("segments read", p.attrs()...)
+}
+
+func (p *readProgress) attrs() []any {
+	elapsed := time.Since(p.started)
+	rate := 0.0
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		rate = float64(p.bytes) / seconds / (1 << 20)
+	}
+
+	return []any{
+		slog.Int("segments", p.segments),
+		slog.Int("total", p.total),
+		slog.Uint64("lsn", p.lsn),
+		slog.Int64("bytes", p.bytes),
+		slog.String("rate", fmt.Sprintf("%.1fMB/s", rate)),
+		slog.Duration("elapsed", elapsed),
+	}
+}
@@ -0,0 +1,288 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"reflect"
+)
+
+// Segment starts with a header:
+//
+//	magic    [8]byte "INMEMWAL"
+//	version  uint16  format version
+//	flags    uint16  reserved, zero
+//	key      uint32  id of the key records are encrypted with, zero if they
+//	                 aren't, see Keyring
+//	lsn      uint64  lsn of the first record in the segment
+//	crc      uint32  CRC32C of the fields above
+//
+// Header is followed by records:
+//
+//	length  uint32  length of the payload
+//	crc     uint32  CRC32C of the fields following it
+//	lsn     uint64  lsn of the first command in the batch
+//	codec   uint8   compression of the payload, since FormatCompression
+//	payload []byte
+//
+// All integers are little endian. Payload format is defined by the version of
+// the segment. Segments without the header are legacy ones, their payloads are
+// gob encoded, records of the oldest segments aren't even framed.
+const (
+	FormatLegacy uint16 = 0
+	// FormatBinary payloads are binary encoded batches.
+	FormatBinary uint16 = 1
+	// FormatTimestamps payloads are FormatBinary ones preceded by the time
+	// the batch was written.
+	FormatTimestamps uint16 = 2
+	// FormatCompression records have codec of the payload in the header.
+	FormatCompression uint16 = 3
+	FormatVersion            = FormatCompression
+
+	segmentHeaderSize     = 28
+	recordHeaderSize      = 16
+	codecRecordHeaderSize = recordHeaderSize + 1
+)
+
+var (
+	ErrCorruptedRecord    = errors.New("corrupted record")
+	ErrCorruptedSegment   = errors.New("corrupted segment")
+	ErrUnsupportedVersion = errors.New("unsupported format version")
+	ErrSegmentsRemoved    = errors.New("segments are removed, replica must be resynced")
+
+	segmentMagic = []byte("INMEMWAL")
+	crcTable     = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type Record struct {
+	LSN     uint64
+	Payload []byte
+	Version uint16
+	// Codec is the compression the payload was stored with, the payload is
+	// already decompressed.
+	Codec Codec
+}
+
+type SegmentHeader struct {
+	Version  uint16
+	FirstLSN uint64
+	KeyID    uint32
+}
+
+func EncodeSegmentHeader(header SegmentHeader) []byte {
+	data := make([]byte, segmentHeaderSize)
+	copy(data[0:8], segmentMagic)
+	binary.LittleEndian.PutUint16(data[8:10], header.Version)
+	binary.LittleEndian.PutUint32(data[12:16], header.KeyID)
+	binary.LittleEndian.PutUint64(data[16:24], header.FirstLSN)
+	binary.LittleEndian.PutUint32(data[24:28], crc32.Checksum(data[:24], crcTable))
+
+	return data
+}
+
+// DecodeSegmentHeader decodes header of the segment, ok is false for legacy
+// segments which have no header.
+func DecodeSegmentHeader(data []byte) (header SegmentHeader, ok bool, err error) {
+	if !bytes.HasPrefix(data, segmentMagic) {
+		if len(data) > 0 && bytes.HasPrefix(segmentMagic, data) {
+			return SegmentHeader{}, true, fmt.Errorf("%w: truncated segment header", ErrCorruptedRecord)
+		}
+		return SegmentHeader{Version: FormatLegacy}, false, nil
+	}
+	if len(data) < segmentHeaderSize {
+		return SegmentHeader{}, true, fmt.Errorf("%w: truncated segment header", ErrCorruptedRecord)
+	}
+	if crc32.Checksum(data[:24], crcTable) != binary.LittleEndian.Uint32(data[24:28]) {
+		return SegmentHeader{}, true, fmt.Errorf("%w: segment header checksum mismatch", ErrCorruptedRecord)
+	}
+
+	header = SegmentHeader{
+		Version:  binary.LittleEndian.Uint16(data[8:10]),
+		KeyID:    binary.LittleEndian.Uint32(data[12:16]),
+		FirstLSN: binary.LittleEndian.Uint64(data[16:24]),
+	}
+	if header.Version < FormatBinary || header.Version > FormatVersion {
+		return header, true, fmt.Errorf("%w %d", ErrUnsupportedVersion, header.Version)
+	}
+
+	return header, true, nil
+}
+
+// EncodeRecord encodes record without codec, as records of segments before
+// FormatCompression and of snapshots are.
+func EncodeRecord(lsn uint64, payload []byte) []byte {
+	record := make([]byte, recordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint64(record[8:16], lsn)
+	copy(record[recordHeaderSize:], payload)
+	binary.LittleEndian.PutUint32(record[4:8], crc32.Checksum(record[8:], crcTable))
+
+	return record
+}
+
+// EncodeCodecRecord encodes record of FormatCompression segment, payload is
+// compressed with the codec if it makes it smaller.
+func EncodeCodecRecord(lsn uint64, payload []byte, codec Codec) []byte {
+	record, _ := encodeRecord(lsn, payload, codec, nil, 0)
+	return record
+}
+
+// EncodeSealedRecord encodes record of FormatCompression segment encrypted
+// with the key unless its id is zero, batches are sent to replicas so.
+func EncodeSealedRecord(lsn uint64, payload []byte, codec Codec, keys *Keyring, keyID uint32) ([]byte, error) {
+	return encodeRecord(lsn, payload, codec, keys, keyID)
+}
+
+// DecodeSealedRecord decodes the record encoded by EncodeSealedRecord, keys
+// may be nil if key id is zero.
+func DecodeSealedRecord(data []byte, keys *Keyring, keyID uint32) (Record, error) {
+	record, size, err := decodeRecord(data, SegmentHeader{Version: FormatCompression, KeyID: keyID}, keys)
+	if errors.Is(err, ErrDecryption) || errors.Is(err, ErrUnknownKey) {
+		return Record{}, err
+	}
+	if err != nil {
+		return Record{}, fmt.Errorf("%w: %w", ErrCorruptedRecord, err)
+	}
+	if size != len(data) {
+		return Record{}, fmt.Errorf("%w: trailing data", ErrCorruptedRecord)
+	}
+
+	return record, nil
+}
+
+// encodeRecord encodes record of FormatCompression segment, payload is
+// compressed and then encrypted with the key unless its id is zero.
+func encodeRecord(lsn uint64, payload []byte, codec Codec, keys *Keyring, keyID uint32) ([]byte, error) {
+	codec, payload = compress(codec, payload)
+	if keyID != 0 {
+		var err error
+		if payload, err = keys.Seal(keyID, lsn, codec, payload); err != nil {
+			return nil, err
+		}
+	}
+
+	record := make([]byte, codecRecordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint64(record[8:16], lsn)
+	record[16] = byte(codec)
+	copy(record[codecRecordHeaderSize:], payload)
+	binary.LittleEndian.PutUint32(record[4:8], crc32.Checksum(record[8:], crcTable))
+
+	return record, nil
+}
+
+// DecodeRecords decodes records without codec from data. On error it returns
+// records decoded so far and the size of the valid prefix of data, so the
+// corrupted tail can be truncated.
+func DecodeRecords(data []byte) ([]Record, int, error) {
+	return decodeRecords(data, 0, SegmentHeader{Version: FormatLegacy}, nil)
+}
+
+func decodeRecords(data []byte, offset int, header SegmentHeader, keys *Keyring) ([]Record, int, error) {
+	var records []Record
+	for offset < len(data) {
+		record, size, err := decodeRecord(data[offset:], header, keys)
+		// Record with valid checksum which can't be decrypted isn't a torn
+		// write, the key is wrong.
+		if errors.Is(err, ErrDecryption) || errors.Is(err, ErrUnknownKey) {
+			return records, offset, err
+		}
+		if err != nil {
+			return records, offset, fmt.Errorf("%w at offset %d: %w", ErrCorruptedRecord, offset, err)
+		}
+
+		records = append(records, record)
+		offset += size
+	}
+
+	return records, offset, nil
+}
+
+func decodeRecord(data []byte, header SegmentHeader, keys *Keyring) (Record, int, error) {
+	version := header.Version
+	headerSize := recordHeaderSize
+	if version >= FormatCompression {
+		headerSize = codecRecordHeaderSize
+	}
+	if len(data) < headerSize {
+		return Record{}, 0, errors.New("truncated header")
+	}
+
+	length := int(binary.LittleEndian.Uint32(data[0:4]))
+	if length > len(data)-headerSize {
+		return Record{}, 0, errors.New("truncated payload")
+	}
+
+	size := headerSize + length
+	if crc32.Checksum(data[8:size], crcTable) != binary.LittleEndian.Uint32(data[4:8]) {
+		return Record{}, 0, errors.New("checksum mismatch")
+	}
+
+	record := Record{
+		LSN:     binary.LittleEndian.Uint64(data[8:16]),
+		Payload: data[headerSize:size],
+		Version: version,
+	}
+	if version >= FormatCompression {
+		record.Codec = Codec(data[16])
+		if header.KeyID != 0 {
+			payload, err := keys.Open(header.KeyID, record.LSN, record.Codec, record.Payload)
+			if err != nil {
+				return Record{}, 0, err
+			}
+			record.Payload = payload
+		}
+
+		payload, err := decompress(record.Codec, record.Payload)
+		if err != nil {
+			return Record{}, 0, fmt.Errorf("failed to decompress payload: %w", err)
+		}
+		record.Payload = payload
+	}
+
+	return record, size, nil
+}
+
+// DecodeSegment returns records of the segment and the size of its valid
+// prefix. Unframed legacy segment is returned as a single record. Keys may be
+// nil if segment isn't encrypted.
+func DecodeSegment(data []byte, keys *Keyring) ([]Record, int, error) {
+	header, ok, err := DecodeSegmentHeader(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if header.KeyID != 0 {
+		if _, err := keys.key(header.KeyID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if !ok {
+		records, valid, err := DecodeRecords(data)
+		if err != nil && valid == 0 && isLegacySegment(data) {
+			return []Record{{Payload: data, Version: FormatLegacy}}, len(data), nil
+		}
+		return records, valid, err
+	}
+
+	return decodeRecords(data, segmentHeaderSize, header, keys)
+}
+
+// isLegacySegment reports whether data is a sequence of gob streams, every
+// batch was encoded with its own encoder. Values are only skipped, not decoded.
+func isLegacySegment(data []byte) bool {
+	buffer := bytes.NewBuffer(data)
+	for {
+		err := gob.NewDecoder(buffer).DecodeValue(reflect.Value{})
+		if errors.Is(err, io.EOF) {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeRecords(t *testing.T) {
+	t.Parallel()
+
+	record1 := EncodeRecord(1, []byte("lorem"))
+	record2 := EncodeRecord(5, []byte("ipsum"))
+	data := append(record1, record2...)
+
+	records, valid, err := DecodeRecords(data)
+	require.NoError(t, err)
+	assert.Equal(t, len(data), valid)
+	assert.Equal(t, []Record{{LSN: 1, Payload: []byte("lorem")}, {LSN: 5, Payload: []byte("ipsum")}}, records)
+}
+
+func TestDecodeRecords_Corrupted(t *testing.T) {
+	t.Parallel()
+
+	record := EncodeRecord(1, []byte("lorem"))
+
+	tests := []struct {
+		name string
+		tail []byte
+	}{
+		{name: "truncated header", tail: record[:recordHeaderSize-1]},
+		{name: "truncated payload", tail: record[:len(record)-1]},
+		{name: "checksum mismatch", tail: append(EncodeRecord(2, []byte("ipsum"))[:recordHeaderSize], []byte("lorem")...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append(append([]byte{}, record...), tt.tail...)
+			records, valid, err := DecodeRecords(data)
+			assert.ErrorIs(t, err, ErrCorruptedRecord)
+			assert.ErrorContains(t, err, tt.name)
+			assert.Equal(t, len(record), valid)
+			assert.Len(t, records, 1)
+		})
+	}
+}
+
+func TestDecodeSegment_CorruptedCompressedPayload(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(strings.Repeat("lorem ipsum ", 20))
+	record := EncodeCodecRecord(1, payload, CodecFlate)
+	require.Equal(t, byte(CodecFlate), record[16])
+
+	// Checksum is valid, but the payload can't be decompressed.
+	record = append(record[:codecRecordHeaderSize], 0xff, 0xff)
+	binary.LittleEndian.PutUint32(record[0:4], 2)
+	binary.LittleEndian.PutUint32(record[4:8], crc32.Checksum(record[8:], crcTable))
+
+	segment := append(EncodeSegmentHeader(SegmentHeader{Version: FormatVersion, FirstLSN: 1}), record...)
+	_, valid, err := DecodeSegment(segment, nil)
+	assert.ErrorIs(t, err, ErrCorruptedRecord)
+	assert.Equal(t, segmentHeaderSize, valid)
+}
+
+func TestParseCodec(t *testing.T) {
+	t.Parallel()
+
+	codec, ok := ParseCodec("flate")
+	assert.True(t, ok)
+	assert.Equal(t, CodecFlate, codec)
+	assert.Equal(t, "flate", codec.String())
+
+	_, ok = ParseCodec("zip")
+	assert.False(t, ok)
+}
+
+func TestParseKeys(t *testing.T) {
+	t.Parallel()
+
+	key1, key2 := strings.Repeat("01", keySize), strings.Repeat("ab", keySize)
+	keys, err := ParseKeys("# keys\n1:" + key1 + "\n 2 : " + key2 + "\n")
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, byte(0xab), keys[2][0])
+
+	keys, err = ParseKeys("1:" + key1 + ",2:" + key2)
+	require.NoError(t, err)
+	keyring, err := NewKeyring(keys, 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), keyring.CurrentKey())
+
+	for _, data := range []string{"1" + key1, "x:" + key1, "1:zz", "1:" + key1 + ",1:" + key2} {
+		_, err := ParseKeys(data)
+		assert.Error(t, err, data)
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	key1, key2 := strings.Repeat("01", keySize), strings.Repeat("ab", keySize)
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(path, []byte("1:"+key1+"\n2:"+key2+"\n"), 0o600))
+
+	keyring, err := LoadKeyring(path, "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), keyring.CurrentKey())
+
+	t.Setenv("TEST_WAL_KEYS", "1:"+key1+",2:"+key2)
+	keyring, err = LoadKeyring("", "TEST_WAL_KEYS", 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), keyring.CurrentKey())
+
+	_, err = LoadKeyring("", "TEST_WAL_KEYS_MISSING", 0)
+	assert.Error(t, err)
+	_, err = LoadKeyring("", "", 0)
+	assert.Error(t, err)
+}
+
+func TestNewKeyring_Invalid(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{1}, keySize)
+	tests := []struct {
+		name    string
+		keys    map[uint32][]byte
+		current uint32
+	}{
+		{name: "no keys"},
+		{name: "zero id", keys: map[uint32][]byte{0: key}},
+		{name: "short key", keys: map[uint32][]byte{1: key[:16]}},
+		{name: "unknown current", keys: map[uint32][]byte{1: key}, current: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyring(tt.keys, tt.current)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestKeyring_SealOpen(t *testing.T) {
+	t.Parallel()
+
+	keyring, err := NewKeyring(map[uint32][]byte{1: bytes.Repeat([]byte{1}, keySize)}, 0)
+	require.NoError(t, err)
+
+	sealed, err := keyring.Seal(1, 10, CodecNone, []byte("lorem"))
+	require.NoError(t, err)
+
+	payload, err := keyring.Open(1, 10, CodecNone, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("lorem"), payload)
+
+	// Record moved to another lsn isn't accepted.
+	_, err = keyring.Open(1, 11, CodecNone, sealed)
+	assert.ErrorIs(t, err, ErrDecryption)
+	_, err = keyring.Open(2, 10, CodecNone, sealed)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
@@ -0,0 +1,195 @@
+package disk
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RetentionPolicy limits disk usage of the log. Sealed segments are removed
+// oldest first while total size exceeds MaxSize or they are older than
+// MaxAge, zero values disable the limits. MinSegments most recent segments are
+// always kept. Segments a connected replica hasn't received yet are removed
+// only while total size exceeds HardMaxSize. If Snapshots is set, segments
+// not covered by snapshot yet are never removed, otherwise commands of the
+// removed segments are lost on restart.
+type RetentionPolicy struct {
+	MaxSize     int
+	MaxAge      time.Duration
+	MinSegments int
+	HardMaxSize int
+	Snapshots   bool
+}
+
+// ReplicaTracker reports the LSN the most lagging connected replica needs
+// next, ok is false if there are no connected replicas.
+type ReplicaTracker interface {
+	RetainedLSN() (lsn uint64, ok bool)
+}
+
+type retention struct {
+	policy      RetentionPolicy
+	snapshotLSN uint64
+	snapshot    bool
+}
+
+type segmentStat struct {
+	segmentFile
+	size    int
+	modTime time.Time
+}
+
+// TrackReplicas sets replicas segments are kept for, they aren't removed by
+// retention nor after snapshot until every connected replica received them.
+func (d *Disk) TrackReplicas(replicas ReplicaTracker) {
+	d.removeMu.Lock()
+	defer d.removeMu.Unlock()
+	d.replicas = replicas
+}
+
+// StartRetention enforces the policy every interval until ctx is done. The
+// policy is also applied to segments removed after snapshot.
+func (d *Disk) StartRetention(ctx context.Context, interval time.Duration, policy RetentionPolicy) {
+	d.removeMu.Lock()
+	d.retention = &retention{policy: policy}
+	d.removeMu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.EnforceRetention(); err != nil {
+				d.log.Error("failed to enforce wal retention", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// EnforceRetention removes segments exceeding the retention policy and
+// returns their names.
+func (d *Disk) EnforceRetention() ([]string, error) {
+	d.removeMu.Lock()
+	defer d.removeMu.Unlock()
+
+	if d.retention == nil {
+		return nil, nil
+	}
+	policy := d.retention.policy
+
+	segments, total, err := d.segmentStats()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var removed []string
+	for i := 0; i+1 < len(segments) && len(segments)-i > policy.MinSegments; i++ {
+		overSize := policy.MaxSize > 0 && total > policy.MaxSize
+		expired := policy.MaxAge > 0 && now.Sub(segments[i].modTime) > policy.MaxAge
+		if !overSize && !expired {
+			break
+		}
+
+		if policy.Snapshots {
+			next, err := d.firstLSN(segments[i+1].segmentFile)
+			if err != nil {
+				return removed, err
+			}
+			if !d.retention.snapshot || next > d.retention.snapshotLSN {
+				break
+			}
+		}
+		released, err := d.releasedByReplicas(segments[i].segmentFile, segments[i+1].segmentFile, total)
+		if err != nil {
+			return removed, err
+		}
+		if !released {
+			break
+		}
+
+		if err := os.Remove(filepath.Join(d.directory, segments[i].name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, segments[i].name)
+		total -= segments[i].size
+	}
+
+	if len(removed) > 0 {
+		d.log.Info("removed segments by retention policy",
+			slog.Int("segments", len(removed)),
+			slog.String("last_removed", removed[len(removed)-1]),
+			slog.Int("total_size", total),
+		)
+	}
+
+	return removed, nil
+}
+
+// releasedByReplicas reports whether the segment followed by the next one may
+// be removed as far as replicas are concerned: either it's received by every
+// connected replica or the hard cap of retention policy is hit.
+func (d *Disk) releasedByReplicas(segment, next segmentFile, total int) (bool, error) {
+	if d.replicas == nil {
+		return true, nil
+	}
+
+	retained, ok := d.replicas.RetainedLSN()
+	if !ok {
+		return true, nil
+	}
+	nextLSN, err := d.firstLSN(next)
+	if err != nil {
+		return false, err
+	}
+	if nextLSN <= retained {
+		return true, nil
+	}
+
+	if d.retention == nil {
+		return false, nil
+	}
+	hardMaxSize := d.retention.policy.HardMaxSize
+	if hardMaxSize <= 0 || total <= hardMaxSize {
+		return false, nil
+	}
+
+	d.log.Warn("removing segment not received by replica, hard cap exceeded",
+		slog.String("segment", segment.name),
+		slog.Uint64("replica_lsn", retained),
+		slog.Int("total_size", total),
+	)
+	return true, nil
+}
+
+func (d *Disk) segmentStats() ([]segmentStat, int, error) {
+	segments, err := d.segments()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Preallocated file of the current segment is larger than its records.
+	current, written, writing := d.segment.Written()
+
+	stats := make([]segmentStat, 0, len(segments))
+	var total int
+	for _, segment := range segments {
+		info, err := os.Stat(filepath.Join(d.directory, segment.name))
+		if err != nil {
+			return nil, 0, err
+		}
+		size := int(info.Size())
+		if writing && segment.name == current {
+			size = written
+		}
+		stats = append(stats, segmentStat{segmentFile: segment, size: size, modTime: info.ModTime()})
+		total += size
+	}
+
+	return stats, total, nil
+}
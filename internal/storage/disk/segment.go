@@ -1,19 +1,52 @@
 package disk
 
 import (
-	"fmt"
+	"errors"
+	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
-	"time"
+	"sync/atomic"
 )
 
+const nextSegmentFilename = ".next_segment"
+
 type Segment struct {
 	maxSegmentSize int
 	curSegmentSize int
-	directory      string
-	file           *os.File
-	log            *slog.Logger
+	syncedSize     int
+	// failed is set if the file may have a tail after curSegmentSize left by
+	// failed write or sync, it's cut off before the next write.
+	failed    bool
+	codec     Codec
+	keys      *Keyring
+	keyID     uint32
+	directory string
+	file      *os.File
+	path      string
+	log       *slog.Logger
+
+	// preallocated segment files are allocated up to maxSegmentSize, the
+	// next one is prepared in background while the current one is written.
+	preallocated bool
+	next         chan preparedSegment
+	preparing    bool
+
+	// written is the current segment and size of its records for readers
+	// outside the writing goroutine, preallocated file is larger.
+	written atomic.Pointer[writtenSegment]
+}
+
+type writtenSegment struct {
+	name string
+	size int
+}
+
+// preparedSegment is a preallocated file the next segment is renamed from.
+type preparedSegment struct {
+	file *os.File
+	err  error
 }
 
 func NewSegment(maxSegmentSize int, directory string, log *slog.Logger) *Segment {
@@ -24,38 +57,209 @@ func NewSegment(maxSegmentSize int, directory string, log *slog.Logger) *Segment
 	}
 }
 
-func (s *Segment) rotateSegment() (err error) {
+func (s *Segment) rotateSegment(lsn uint64) (err error) {
 	if s.file != nil {
+		// Preallocated tail of the sealed segment isn't a part of it.
+		if s.preallocated {
+			if err := s.file.Truncate(int64(s.curSegmentSize)); err != nil {
+				return err
+			}
+		}
+		if err := s.file.Sync(); err != nil {
+			return err
+		}
 		if err := s.file.Close(); err != nil {
-			s.log.Error("failed to close file", slog.String("filename", s.file.Name()), slog.Any("error", err))
+			s.log.Error("failed to close file", slog.String("filename", s.path), slog.Any("error", err))
 		}
+		s.file = nil
 	}
 
-	filename := fmt.Sprintf("wal_%d.log", time.Now().UnixMilli())
-	s.file, err = os.OpenFile(filepath.Join(s.directory, filename), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
+	// Segment is named by lsn of its first record. The segment with the same
+	// name may exist only if recovery truncated all of its records as a torn
+	// write, so it's overwritten.
+	path := filepath.Join(s.directory, segmentFilename(lsn))
+	if s.file, err = s.openSegment(path); err != nil {
 		return err
 	}
 
+	s.path = path
 	s.curSegmentSize = 0
+	s.syncedSize = 0
 	return nil
 }
 
-func (s *Segment) Write(data []byte) error {
-	if s.file == nil || s.curSegmentSize >= s.maxSegmentSize {
-		if err := s.rotateSegment(); err != nil {
+// openSegment creates file of the new segment. Preallocated one is renamed
+// from the file prepared in background, if it isn't ready yet, the file is
+// preallocated in place.
+func (s *Segment) openSegment(path string) (*os.File, error) {
+	if !s.preallocated {
+		return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	}
+
+	defer s.prepareNext()
+
+	select {
+	case prepared := <-s.next:
+		s.preparing = false
+		if prepared.err == nil {
+			if err := os.Rename(prepared.file.Name(), path); err == nil {
+				return prepared.file, nil
+			}
+			_ = prepared.file.Close()
+		}
+		s.log.Warn("failed to use prepared segment", slog.Any("error", prepared.err))
+	default:
+	}
+
+	return s.createPreallocated(path)
+}
+
+// prepareNext starts preparing file for the next segment unless it's already
+// prepared or being prepared.
+func (s *Segment) prepareNext() {
+	if s.preparing {
+		return
+	}
+	s.preparing = true
+
+	path := filepath.Join(s.directory, nextSegmentFilename)
+	go func() {
+		file, err := s.createPreallocated(path)
+		s.next <- preparedSegment{file: file, err: err}
+	}()
+}
+
+func (s *Segment) createPreallocated(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := preallocate(file, s.maxSegmentSize); err != nil {
+		s.log.Debug("failed to preallocate segment", slog.String("filename", path), slog.Any("error", err))
+	}
+
+	return file, nil
+}
+
+// Write appends data framed as a record, new segment starts with the header,
+// see EncodeSegmentHeader and EncodeRecord. Segment is rotated before the
+// record which would exceed maxSegmentSize, so only a segment of a single
+// record may exceed it. Written data isn't synced, see Sync, but sealed
+// segments are synced on rotation.
+func (s *Segment) Write(lsn uint64, data []byte) error {
+	defer s.publish()
+	if err := s.repair(); err != nil {
+		return err
+	}
+
+	record, err := encodeRecord(lsn, data, s.codec, s.keys, s.keyID)
+	if err != nil {
+		return err
+	}
+
+	rotate := s.file == nil || s.curSegmentSize+len(record) > s.maxSegmentSize
+	keyID := s.keyID
+	if rotate && s.keys.CurrentKey() != keyID {
+		keyID = s.keys.CurrentKey()
+		if record, err = encodeRecord(lsn, data, s.codec, s.keys, keyID); err != nil {
 			return err
 		}
 	}
 
-	written, err := s.file.Write(data)
+	if rotate {
+		if err := s.rotateSegment(lsn); err != nil {
+			if s.file != nil && s.curSegmentSize > s.syncedSize {
+				s.curSegmentSize, s.failed = s.syncedSize, true
+			}
+			return err
+		}
+		s.keyID = keyID
+		record = append(EncodeSegmentHeader(SegmentHeader{Version: FormatVersion, FirstLSN: lsn, KeyID: keyID}), record...)
+	}
+
+	written, err := s.file.Write(record)
 	if err != nil {
+		s.failed = written > 0 || s.curSegmentSize == 0
+		return err
+	}
+
+	s.curSegmentSize += written
+	return nil
+}
+
+// Sync syncs the written records. If it fails, records written after the
+// last successful sync may be lost by OS, so they are cut off.
+func (s *Segment) Sync() error {
+	if s.file == nil {
+		return nil
+	}
+	defer s.publish()
+	if err := s.repair(); err != nil {
+		return err
+	}
+
+	if err := syncData(s.file); err != nil {
+		s.curSegmentSize, s.failed = s.syncedSize, true
+		return err
+	}
+
+	s.syncedSize = s.curSegmentSize
+	return nil
+}
+
+func (s *Segment) publish() {
+	if s.file == nil {
+		s.written.Store(nil)
+		return
+	}
+	s.written.Store(&writtenSegment{name: filepath.Base(s.path), size: s.curSegmentSize})
+}
+
+// Written returns name of the current segment and size of its records, ok is
+// false if no segment is written yet.
+func (s *Segment) Written() (name string, size int, ok bool) {
+	written := s.written.Load()
+	if written == nil {
+		return "", 0, false
+	}
+	return written.name, written.size, true
+}
+
+// repair cuts off the tail left by failed write or sync. Segment without
+// records is removed, so the next write starts a new one with the header.
+func (s *Segment) repair() error {
+	if !s.failed {
+		return nil
+	}
+
+	name := s.path
+	if s.curSegmentSize == 0 {
+		if err := s.file.Close(); err != nil {
+			s.log.Error("failed to close file", slog.String("filename", name), slog.Any("error", err))
+		}
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		s.file, s.failed = nil, false
+		s.log.Warn("removed segment without records after failed write", slog.String("segment", name))
+		return nil
+	}
+
+	if err := s.file.Truncate(int64(s.curSegmentSize)); err != nil {
+		return err
+	}
+	if _, err := s.file.Seek(int64(s.curSegmentSize), io.SeekStart); err != nil {
 		return err
 	}
 	if err := s.file.Sync(); err != nil {
 		return err
 	}
 
-	s.curSegmentSize += written
+	s.syncedSize, s.failed = s.curSegmentSize, false
+	s.log.Warn("cut off segment tail after failed write",
+		slog.String("segment", name),
+		slog.Int("size", s.curSegmentSize),
+	)
 	return nil
 }
@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
+	"github.com/DaniilZ77/InMemDB/internal/storage/dump"
+)
+
+const (
+	defaultDumpDirectory = "./data/dumps"
+	dumpTempSuffix       = ".tmp"
+	// restoreBatchSize is amount of entries saved in WAL by one append.
+	restoreBatchSize = 1000
+
+	errDumpNotStreamed = "ERROR(dump without file is sent only over connection)"
+)
+
+var (
+	ErrNotEmpty        = errors.New("database is not empty")
+	ErrInvalidDumpName = errors.New("invalid dump name")
+)
+
+// Dump writes keys with the prefix to the writer in dump format, see package
+// dump, empty prefix dumps every key. Writes are paused only to capture LSN,
+// as for snapshots.
+func (d *Database) Dump(writer io.Writer, prefix string) (int, error) {
+	lsn := 0
+	if d.wal != nil {
+		d.writes.Lock()
+		lsn = d.wal.LSN()
+		d.writes.Unlock()
+	}
+
+	dumpWriter, err := dump.NewWriter(writer, time.Now(), lsn)
+	if err != nil {
+		return 0, err
+	}
+
+	var writeErr error
+	d.engine.Range(func(key, value string) {
+		if writeErr == nil && strings.HasPrefix(key, prefix) {
+			writeErr = dumpWriter.Write(key, value)
+		}
+	})
+	if writeErr != nil {
+		return 0, writeErr
+	}
+
+	return dumpWriter.Keys(), dumpWriter.Close()
+}
+
+// Restore loads the dump into empty database. Entries are saved in WAL in
+// order as SET commands, so replicas receive them. Writes are paused till
+// it's loaded, so nothing is written between the check the database is empty
+// and the load. The dump is validated before it's loaded, but if saving
+// fails, part of it is loaded.
+func (d *Database) Restore(reader io.ReadSeeker) (int, error) {
+	if d.replica != nil && d.replica.IsSlave() {
+		return 0, errors.New("replica is read-only")
+	}
+	if _, err := dump.Read(reader, nil); err != nil {
+		return 0, err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	d.writes.Lock()
+	defer d.writes.Unlock()
+
+	if d.engine.Len() > 0 {
+		return 0, ErrNotEmpty
+	}
+
+	restored := 0
+	batch := make([]*parser.Command, 0, restoreBatchSize)
+	save := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		// every batch waits to be flushed, so restore doesn't overrun
+		// pending writes of WAL
+		batch[len(batch)-1].Sync = true
+		if d.wal != nil {
+			if err := d.wal.SaveBatch(batch); err != nil {
+				return err
+			}
+		}
+
+		for _, command := range batch {
+			d.engine.Set(command.Args[0], command.Args[1])
+		}
+		restored += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	_, err := dump.Read(reader, func(entry dump.Entry) error {
+		batch = append(batch, &parser.Command{Type: parser.SET, Args: []string{entry.Key, entry.Value}})
+		if len(batch) < restoreBatchSize {
+			return nil
+		}
+		return save()
+	})
+	if err == nil {
+		err = save()
+	}
+
+	return restored, err
+}
+
+// Stream returns the stream of the response to DUMP without file, so the dump
+// is sent as it's written instead of being held in memory.
+func (d *Database) Stream(source string) (func(io.Writer) error, bool) {
+	if commandType, ok := parser.Peek([]byte(source)); !ok || commandType != parser.DUMP {
+		return nil, false
+	}
+
+	command, err := d.compute.Parse(source)
+	if err != nil {
+		return nil, false
+	}
+	prefix, ok := dumpPrefix(command)
+	if !ok {
+		return nil, false
+	}
+
+	return func(writer io.Writer) error {
+		keys, err := d.Dump(writer, prefix)
+		if err != nil {
+			return err
+		}
+
+		d.log.Info("database dumped", slog.String("prefix", prefix), slog.Int("keys", keys))
+		return nil
+	}, true
+}
+
+// dumpPrefix returns prefix of the keys of DUMP which is streamed, DUMP to
+// file isn't.
+func dumpPrefix(command *parser.Command) (string, bool) {
+	switch {
+	case len(command.Args) == 0:
+		return "", true
+	case len(command.Args) == 2 && command.Args[0] == parser.DumpPrefix:
+		return command.Args[1], true
+	}
+
+	return "", false
+}
+
+// dumpCommand writes the dump to the file of dump directory, the dump without
+// file is sent by Stream.
+func (d *Database) dumpCommand(command *parser.Command) string {
+	if _, ok := dumpPrefix(command); ok {
+		return errDumpNotStreamed
+	}
+
+	path, err := d.dumpPath(command.Args[0])
+	if err != nil {
+		return fmt.Sprintf("ERROR(%s)", err.Error())
+	}
+
+	keys, err := d.dumpToFile(path)
+	if err != nil {
+		d.log.Error("failed to dump database", slog.String("path", path), slog.Any("error", err))
+		return errInternal
+	}
+
+	d.log.Info("database dumped", slog.String("path", path), slog.Int("keys", keys))
+	return "OK"
+}
+
+func (d *Database) dumpToFile(path string) (keys int, err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return 0, err
+	}
+
+	file, err := os.OpenFile(path+dumpTempSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if file != nil {
+			_ = file.Close()
+		}
+		if err != nil {
+			_ = os.Remove(path + dumpTempSuffix)
+		}
+	}()
+
+	if keys, err = d.Dump(file, ""); err != nil {
+		return 0, err
+	}
+	if err := file.Sync(); err != nil {
+		return 0, err
+	}
+	closeErr := file.Close()
+	file = nil
+	if closeErr != nil {
+		return 0, closeErr
+	}
+
+	return keys, os.Rename(path+dumpTempSuffix, path)
+}
+
+func (d *Database) restoreCommand(command *parser.Command) string {
+	if d.replica != nil && d.replica.IsSlave() {
+		return errReplicaNotSupport
+	}
+
+	path, err := d.dumpPath(command.Args[0])
+	if err != nil {
+		return fmt.Sprintf("ERROR(%s)", err.Error())
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		d.log.Warn("failed to open dump", slog.String("path", path), slog.Any("error", err))
+		return "ERROR(no such dump)"
+	}
+	defer file.Close() // nolint
+
+	keys, err := d.Restore(file)
+	if err != nil {
+		d.log.Error("failed to restore dump", slog.String("path", path), slog.Int("keys", keys), slog.Any("error", err))
+		switch {
+		case errors.Is(err, ErrNotEmpty), errors.Is(err, dump.ErrInvalidDump), errors.Is(err, dump.ErrUnsupportedVersion):
+			return fmt.Sprintf("ERROR(%s)", err.Error())
+		}
+		return d.walError(err)
+	}
+
+	d.log.Info("dump restored", slog.String("path", path), slog.Int("keys", keys))
+	return "OK"
+}
+
+// dumpPath returns path of the dump in dump directory, the name must not
+// point outside of it.
+func (d *Database) dumpPath(name string) (string, error) {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") || strings.HasSuffix(name, dumpTempSuffix) {
+		return "", ErrInvalidDumpName
+	}
+
+	return filepath.Join(d.dumpDirectory, name), nil
+}
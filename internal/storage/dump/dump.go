@@ -0,0 +1,180 @@
+package dump
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// Dump is a logical backup of the keyspace, a JSON lines file:
+//
+//	{"format":"inmemdb-dump","version":1,"created_at":"2024-05-01T10:00:00Z","lsn":42}
+//	{"key":"name","type":"string","value":"Daniil"}
+//	{"end":true,"keys":1,"checksum":"5f1c3a2b"}
+//
+// The first line is the header, every command before lsn is in the dump,
+// commands following it may be in it too. It's followed by a line per key,
+// type is the type of the value, string is the only one. Keys don't expire,
+// so entries have no TTL. The last line ends the dump: keys is amount of the
+// entries and checksum is CRC32C of the entry lines with their line feeds in
+// hex, a dump without it is truncated.
+const (
+	Format  = "inmemdb-dump"
+	Version = 1
+
+	TypeString = "string"
+)
+
+var (
+	ErrInvalidDump        = errors.New("invalid dump")
+	ErrUnsupportedVersion = errors.New("unsupported dump version")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	LSN       int       `json:"lsn"`
+}
+
+type Entry struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type trailer struct {
+	End      bool   `json:"end"`
+	Keys     int    `json:"keys"`
+	Checksum string `json:"checksum"`
+}
+
+// line is any line following the header.
+type line struct {
+	Entry
+	trailer
+}
+
+// Writer writes dump, Close must be called to end it.
+type Writer struct {
+	writer *bufio.Writer
+	crc    hash.Hash32
+	keys   int
+}
+
+func NewWriter(writer io.Writer, createdAt time.Time, lsn int) (*Writer, error) {
+	w := &Writer{writer: bufio.NewWriter(writer), crc: crc32.New(crcTable)}
+	header := Header{Format: Format, Version: Version, CreatedAt: createdAt.UTC(), LSN: lsn}
+	if err := w.writeLine(header, nil); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *Writer) Write(key, value string) error {
+	w.keys++
+	return w.writeLine(Entry{Key: key, Type: TypeString, Value: value}, w.crc)
+}
+
+// Close writes the last line and flushes the dump, it doesn't close the
+// underlying writer.
+func (w *Writer) Close() error {
+	end := trailer{End: true, Keys: w.keys, Checksum: fmt.Sprintf("%08x", w.crc.Sum32())}
+	if err := w.writeLine(end, nil); err != nil {
+		return err
+	}
+
+	return w.writer.Flush()
+}
+
+// Keys returns amount of written entries.
+func (w *Writer) Keys() int {
+	return w.keys
+}
+
+func (w *Writer) writeLine(value any, crc hash.Hash32) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if crc != nil {
+		_, _ = crc.Write(data)
+	}
+	_, err = w.writer.Write(data)
+	return err
+}
+
+// Read calls fn for every entry of the dump, fn may be nil to only validate
+// it. Entries are passed before the dump is known to be complete, validate it
+// first if they must not be applied partially.
+func Read(reader io.Reader, fn func(Entry) error) (Header, error) {
+	buffered := bufio.NewReader(reader)
+
+	data, err := readLine(buffered)
+	if err != nil {
+		return Header{}, err
+	}
+	var header Header
+	if err := json.Unmarshal(data, &header); err != nil || header.Format != Format {
+		return Header{}, fmt.Errorf("%w: bad header", ErrInvalidDump)
+	}
+	if header.Version < 1 || header.Version > Version {
+		return header, fmt.Errorf("%w %d", ErrUnsupportedVersion, header.Version)
+	}
+
+	crc := crc32.New(crcTable)
+	for number := 2; ; number++ {
+		data, err := readLine(buffered)
+		if err != nil {
+			return header, err
+		}
+
+		var l line
+		if err := json.Unmarshal(data, &l); err != nil {
+			return header, fmt.Errorf("%w: line %d: %w", ErrInvalidDump, number, err)
+		}
+		if l.End {
+			return header, checkTrailer(l.trailer, number-2, crc.Sum32())
+		}
+		if l.Type != TypeString {
+			return header, fmt.Errorf("%w: line %d: unsupported type %q", ErrInvalidDump, number, l.Type)
+		}
+
+		_, _ = crc.Write(data)
+		if fn != nil {
+			if err := fn(l.Entry); err != nil {
+				return header, err
+			}
+		}
+	}
+}
+
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	data, err := reader.ReadBytes('\n')
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: truncated", ErrInvalidDump)
+	}
+
+	return data, err
+}
+
+func checkTrailer(end trailer, keys int, checksum uint32) error {
+	if end.Keys != keys {
+		return fmt.Errorf("%w: %d keys, %d expected", ErrInvalidDump, keys, end.Keys)
+	}
+	if end.Checksum != fmt.Sprintf("%08x", checksum) {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidDump)
+	}
+
+	return nil
+}
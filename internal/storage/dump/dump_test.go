@@ -0,0 +1,79 @@
+package dump
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeDump(t *testing.T, entries ...Entry) string {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), 42)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		require.NoError(t, writer.Write(entry.Key, entry.Value))
+	}
+	require.NoError(t, writer.Close())
+	assert.Equal(t, len(entries), writer.Keys())
+
+	return buf.String()
+}
+
+func TestWriteRead(t *testing.T) {
+	t.Parallel()
+
+	entries := []Entry{
+		{Key: "name", Type: TypeString, Value: "Daniil"},
+		{Key: "city", Type: TypeString, Value: "Санкт-Петербург"},
+	}
+	data := writeDump(t, entries...)
+	assert.True(t, strings.HasPrefix(data,
+		`{"format":"inmemdb-dump","version":1,"created_at":"2024-05-01T10:00:00Z","lsn":42}`+"\n"+
+			`{"key":"name","type":"string","value":"Daniil"}`+"\n"), data)
+
+	var read []Entry
+	header, err := Read(strings.NewReader(data), func(entry Entry) error {
+		read = append(read, entry)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 42, header.LSN)
+	assert.Equal(t, entries, read)
+
+	_, err = Read(strings.NewReader(writeDump(t)), nil)
+	assert.NoError(t, err)
+}
+
+func TestRead_Invalid(t *testing.T) {
+	t.Parallel()
+
+	data := writeDump(t, Entry{Key: "name", Value: "Daniil"}, Entry{Key: "city", Value: "Moscow"})
+	lines := strings.SplitAfter(data, "\n")
+
+	tests := []struct {
+		name string
+		data string
+		err  error
+	}{
+		{name: "empty", data: "", err: ErrInvalidDump},
+		{name: "not a dump", data: "{\"key\":\"name\"}\n", err: ErrInvalidDump},
+		{name: "truncated", data: strings.Join(lines[:3], ""), err: ErrInvalidDump},
+		{name: "changed value", data: strings.Replace(data, "Moscow", "Kazan", 1), err: ErrInvalidDump},
+		{name: "missing entry", data: lines[0] + lines[1] + lines[3], err: ErrInvalidDump},
+		{name: "newer version", data: strings.Replace(data, `"version":1`, `"version":2`, 1), err: ErrUnsupportedVersion},
+		{name: "unknown type", data: strings.Replace(data, `"type":"string"`, `"type":"list"`, 1), err: ErrInvalidDump},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Read(strings.NewReader(tt.data), nil)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/DaniilZ77/InMemDB/internal/common"
+	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
+	"github.com/DaniilZ77/InMemDB/internal/storage/dump"
+	"github.com/DaniilZ77/InMemDB/internal/storage/engine"
+	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newDumpTestDatabase(t *testing.T, directory string, entries map[string]string) (*Database, *engine.Engine, *MockWal) {
+	store, err := engine.NewEngine(4)
+	require.NoError(t, err)
+	for key, value := range entries {
+		store.Set(key, value)
+	}
+
+	wal := NewMockWal(t)
+	database, err := NewDatabase(parserCompute(t), store, wal, nil, slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithDumpDirectory(directory))
+	require.NoError(t, err)
+
+	return database, store, wal
+}
+
+func parserCompute(t *testing.T) Compute {
+	compute, err := parser.NewParser(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	return compute
+}
+
+func TestDumpRestore(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	entries := map[string]string{"name": "Daniil", "city": "Moscow", "lang": "go"}
+	source, _, sourceWal := newDumpTestDatabase(t, dir, entries)
+	sourceWal.EXPECT().LSN().Return(42)
+
+	assert.Equal(t, "OK", source.Execute("DUMP backup.jsonl"))
+	_, err := os.Stat(filepath.Join(dir, "backup.jsonl"+dumpTempSuffix))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	file, err := os.Open(filepath.Join(dir, "backup.jsonl"))
+	require.NoError(t, err)
+	defer file.Close() // nolint
+	var order []string
+	_, err = dump.Read(file, func(entry dump.Entry) error {
+		order = append(order, entry.Key)
+		return nil
+	})
+	require.NoError(t, err)
+
+	target, store, targetWal := newDumpTestDatabase(t, dir, nil)
+	var saved []*parser.Command
+	targetWal.EXPECT().SaveBatch(mock.Anything).RunAndReturn(func(commands []*parser.Command) error {
+		saved = append(saved, commands...)
+		return nil
+	}).Once()
+
+	assert.Equal(t, "OK", target.Execute("RESTORE backup.jsonl"))
+	restored := make(map[string]string)
+	store.Range(func(key, value string) {
+		restored[key] = value
+	})
+	assert.Equal(t, entries, restored)
+
+	require.Len(t, saved, len(entries))
+	for i, command := range saved {
+		assert.Equal(t, parser.SET, command.Type)
+		assert.Equal(t, []string{order[i], entries[order[i]]}, command.Args, "entries are saved in dump order")
+	}
+	assert.True(t, saved[len(saved)-1].Sync, "batch waits to be flushed")
+
+	assert.Equal(t, "ERROR(database is not empty)", target.Execute("RESTORE backup.jsonl"))
+}
+
+func TestDump_Stream(t *testing.T) {
+	t.Parallel()
+
+	database, _, wal := newDumpTestDatabase(t, t.TempDir(), map[string]string{"name": "Daniil", "user:1": "Ivan", "user:2": "Oleg"})
+	wal.EXPECT().LSN().Return(7)
+
+	assert.Equal(t, errDumpNotStreamed, database.Execute("DUMP"))
+	assert.Equal(t, errDumpNotStreamed, database.Execute("DUMP PREFIX user:"))
+	_, ok := database.Stream("DUMP backup.jsonl")
+	assert.False(t, ok, "dump to file isn't streamed")
+	_, ok = database.Stream("GET name")
+	assert.False(t, ok)
+
+	tests := []struct {
+		query string
+		keys  []string
+	}{
+		{query: "dump", keys: []string{"name", "user:1", "user:2"}},
+		{query: "DUMP PREFIX user:", keys: []string{"user:1", "user:2"}},
+		{query: "DUMP PREFIX missing", keys: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			stream, ok := database.Stream(tt.query)
+			require.True(t, ok)
+			var response bytes.Buffer
+			require.NoError(t, stream(&response))
+
+			var keys []string
+			header, err := dump.Read(&response, func(entry dump.Entry) error {
+				keys = append(keys, entry.Key)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, 7, header.LSN)
+			assert.ElementsMatch(t, tt.keys, keys)
+		})
+	}
+}
+
+func TestRestore_Errors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.jsonl"), []byte("{}\n"), 0666))
+	database, _, _ := newDumpTestDatabase(t, dir, nil)
+
+	assert.Equal(t, "ERROR(invalid dump name)", database.Execute("RESTORE ../backup.jsonl"))
+	assert.Equal(t, "ERROR(invalid dump name)", database.Execute("DUMP .hidden"))
+	assert.Equal(t, "ERROR(no such dump)", database.Execute("RESTORE missing.jsonl"))
+	assert.Equal(t, "ERROR(invalid dump: bad header)", database.Execute("RESTORE broken.jsonl"))
+}
+
+func TestRestore_Batches(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	entries := make(map[string]string)
+	for i := range 2*restoreBatchSize + 1 {
+		entries["key"+strconv.Itoa(i)] = strconv.Itoa(i)
+	}
+	source, _, sourceWal := newDumpTestDatabase(t, dir, entries)
+	sourceWal.EXPECT().LSN().Return(0)
+	require.Equal(t, "OK", source.Execute("DUMP backup.jsonl"))
+
+	target, store, targetWal := newDumpTestDatabase(t, dir, nil)
+	var sizes []int
+	targetWal.EXPECT().SaveBatch(mock.Anything).RunAndReturn(func(commands []*parser.Command) error {
+		sizes = append(sizes, len(commands))
+		if len(sizes) == 2 {
+			return wal.ErrBusy
+		}
+		return nil
+	}).Times(2)
+
+	assert.Equal(t, common.BusyResponse, target.Execute("RESTORE backup.jsonl"))
+	assert.Equal(t, []int{restoreBatchSize, restoreBatchSize}, sizes)
+	assert.Equal(t, restoreBatchSize, store.Len(), "batches saved before the failure stay loaded")
+}
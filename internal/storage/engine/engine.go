@@ -36,6 +36,45 @@ func (e *Engine) Del(key string) {
 	e.shards[e.getHash(key)].Del(key)
 }
 
+func (e *Engine) Stats() []ShardStats {
+	stats := make([]ShardStats, 0, len(e.shards))
+	for _, shard := range e.shards {
+		stats = append(stats, shard.Stats())
+	}
+
+	return stats
+}
+
+// Len returns amount of keys.
+func (e *Engine) Len() int {
+	keys := 0
+	for _, shard := range e.shards {
+		keys += shard.Len()
+	}
+
+	return keys
+}
+
+// Range calls fn for every key, shards are copied one by one, so it doesn't
+// block writers and the result isn't a point in time view of the engine.
+func (e *Engine) Range(fn func(key, value string)) {
+	for _, shard := range e.shards {
+		for key, value := range shard.Entries() {
+			fn(key, value)
+		}
+	}
+}
+
+// ShardIndex returns index of the shard the key belongs to, commands on keys
+// of different shards can be applied concurrently.
+func (e *Engine) ShardIndex(key string) int {
+	return int(e.getHash(key))
+}
+
+func (e *Engine) ShardsNumber() int {
+	return len(e.shards)
+}
+
 func (e *Engine) getHash(key string) uint32 {
 	h := fnv.New32a()
 	h.Write([]byte(key))
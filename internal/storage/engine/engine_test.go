@@ -61,3 +61,55 @@ func TestEngineDel(t *testing.T) {
 	_, ok := engine.shards[hash].data["name"]
 	assert.False(t, ok)
 }
+
+func TestEngineRange(t *testing.T) {
+	t.Parallel()
+
+	engine, err := NewEngine(testLogShardsAmount)
+	require.NoError(t, err)
+
+	expected := map[string]string{"name": "Daniil", "city": "Moscow", "lang": "Go"}
+	for key, value := range expected {
+		engine.Set(key, value)
+	}
+
+	entries := make(map[string]string)
+	engine.Range(func(key, value string) {
+		entries[key] = value
+	})
+	assert.Equal(t, expected, entries)
+}
+
+func TestEngineShardIndex(t *testing.T) {
+	t.Parallel()
+
+	engine, err := NewEngine(testLogShardsAmount)
+	require.NoError(t, err)
+
+	assert.Equal(t, testLogShardsAmount, engine.ShardsNumber())
+	for _, key := range []string{"name", "surname", "age"} {
+		index := engine.ShardIndex(key)
+		assert.Equal(t, index, engine.ShardIndex(key))
+		assert.Less(t, index, engine.ShardsNumber())
+
+		engine.Set(key, "value")
+		_, ok := engine.shards[index].data[key]
+		assert.True(t, ok)
+	}
+}
+
+func TestEngineLen(t *testing.T) {
+	t.Parallel()
+
+	engine, err := NewEngine(testLogShardsAmount)
+	require.NoError(t, err)
+	assert.Zero(t, engine.Len())
+
+	engine.Set("name", "Daniil")
+	engine.Set("city", "Moscow")
+	engine.Set("name", "Ivan")
+	assert.Equal(t, 2, engine.Len())
+
+	engine.Del("city")
+	assert.Equal(t, 1, engine.Len())
+}
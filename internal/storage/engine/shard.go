@@ -1,10 +1,19 @@
 package engine
 
-import "sync"
+import (
+	"maps"
+	"sync"
+)
 
 type Shard struct {
-	mu   sync.RWMutex
-	data map[string]string
+	mu    sync.RWMutex
+	data  map[string]string
+	bytes int
+}
+
+type ShardStats struct {
+	Keys  int
+	Bytes int
 }
 
 func NewShard() *Shard {
@@ -23,11 +32,40 @@ func (e *Shard) Get(key string) (string, bool) {
 func (e *Shard) Set(key, value string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	if old, ok := e.data[key]; ok {
+		e.bytes -= len(key) + len(old)
+	}
 	e.data[key] = value
+	e.bytes += len(key) + len(value)
 }
 
 func (e *Shard) Del(key string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	if old, ok := e.data[key]; ok {
+		e.bytes -= len(key) + len(old)
+	}
 	delete(e.data, key)
 }
+
+// Stats returns amount of keys and bytes taken by keys and values, map and
+// strings headers overhead is not counted.
+func (e *Shard) Stats() ShardStats {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return ShardStats{Keys: len(e.data), Bytes: e.bytes}
+}
+
+func (e *Shard) Len() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.data)
+}
+
+// Entries returns copy of the shard data, the shard is locked only while it is
+// copied.
+func (e *Shard) Entries() map[string]string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return maps.Clone(e.data)
+}
@@ -49,3 +49,18 @@ func TestShardDel(t *testing.T) {
 	_, ok := engine.data["name"]
 	assert.False(t, ok)
 }
+
+func TestShardStats(t *testing.T) {
+	t.Parallel()
+
+	engine := NewShard()
+
+	engine.Set("name", "Daniil")
+	engine.Set("name", "Dan")
+	engine.Set("age", "22")
+	assert.Equal(t, ShardStats{Keys: 2, Bytes: len("name") + len("Dan") + len("age") + len("22")}, engine.Stats())
+
+	engine.Del("name")
+	engine.Del("unknown")
+	assert.Equal(t, ShardStats{Keys: 1, Bytes: len("age") + len("22")}, engine.Stats())
+}
@@ -106,6 +106,84 @@ func (_c *MockEngine_Get_Call) RunAndReturn(run func(string) (string, bool)) *Mo
 	return _c
 }
 
+// Len provides a mock function with no fields
+func (_m *MockEngine) Len() int {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Len")
+	}
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
+// MockEngine_Len_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Len'
+type MockEngine_Len_Call struct {
+	*mock.Call
+}
+
+// Len is a helper method to define mock.On call
+func (_e *MockEngine_Expecter) Len() *MockEngine_Len_Call {
+	return &MockEngine_Len_Call{Call: _e.mock.On("Len")}
+}
+
+func (_c *MockEngine_Len_Call) Run(run func()) *MockEngine_Len_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockEngine_Len_Call) Return(_a0 int) *MockEngine_Len_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockEngine_Len_Call) RunAndReturn(run func() int) *MockEngine_Len_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Range provides a mock function with given fields: fn
+func (_m *MockEngine) Range(fn func(string, string)) {
+	_m.Called(fn)
+}
+
+// MockEngine_Range_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Range'
+type MockEngine_Range_Call struct {
+	*mock.Call
+}
+
+// Range is a helper method to define mock.On call
+//   - fn func(string , string)
+func (_e *MockEngine_Expecter) Range(fn interface{}) *MockEngine_Range_Call {
+	return &MockEngine_Range_Call{Call: _e.mock.On("Range", fn)}
+}
+
+func (_c *MockEngine_Range_Call) Run(run func(fn func(string, string))) *MockEngine_Range_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(func(string, string)))
+	})
+	return _c
+}
+
+func (_c *MockEngine_Range_Call) Return() *MockEngine_Range_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockEngine_Range_Call) RunAndReturn(run func(func(string, string))) *MockEngine_Range_Call {
+	_c.Run(run)
+	return _c
+}
+
 // Set provides a mock function with given fields: key, value
 func (_m *MockEngine) Set(key string, value string) {
 	_m.Called(key, value)
@@ -140,6 +218,97 @@ func (_c *MockEngine_Set_Call) RunAndReturn(run func(string, string)) *MockEngin
 	return _c
 }
 
+// ShardIndex provides a mock function with given fields: key
+func (_m *MockEngine) ShardIndex(key string) int {
+	ret := _m.Called(key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ShardIndex")
+	}
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(string) int); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
+// MockEngine_ShardIndex_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ShardIndex'
+type MockEngine_ShardIndex_Call struct {
+	*mock.Call
+}
+
+// ShardIndex is a helper method to define mock.On call
+//   - key string
+func (_e *MockEngine_Expecter) ShardIndex(key interface{}) *MockEngine_ShardIndex_Call {
+	return &MockEngine_ShardIndex_Call{Call: _e.mock.On("ShardIndex", key)}
+}
+
+func (_c *MockEngine_ShardIndex_Call) Run(run func(key string)) *MockEngine_ShardIndex_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *MockEngine_ShardIndex_Call) Return(_a0 int) *MockEngine_ShardIndex_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockEngine_ShardIndex_Call) RunAndReturn(run func(string) int) *MockEngine_ShardIndex_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ShardsNumber provides a mock function with no fields
+func (_m *MockEngine) ShardsNumber() int {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ShardsNumber")
+	}
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
+// MockEngine_ShardsNumber_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ShardsNumber'
+type MockEngine_ShardsNumber_Call struct {
+	*mock.Call
+}
+
+// ShardsNumber is a helper method to define mock.On call
+func (_e *MockEngine_Expecter) ShardsNumber() *MockEngine_ShardsNumber_Call {
+	return &MockEngine_ShardsNumber_Call{Call: _e.mock.On("ShardsNumber")}
+}
+
+func (_c *MockEngine_ShardsNumber_Call) Run(run func()) *MockEngine_ShardsNumber_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockEngine_ShardsNumber_Call) Return(_a0 int) *MockEngine_ShardsNumber_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockEngine_ShardsNumber_Call) RunAndReturn(run func() int) *MockEngine_ShardsNumber_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockEngine creates a new instance of MockEngine. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockEngine(t interface {
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
+)
+
+const (
+	replayQueueSize = 64
+	replayBatchSize = 512
+)
+
+// replayer applies WAL commands on several goroutines. Commands on one key
+// belong to one shard and every shard is served by a single worker, so order
+// of commands is preserved per key.
+type replayer struct {
+	shard   func(key string) int
+	queues  []chan []wal.Command
+	batches [][]wal.Command
+	wg      sync.WaitGroup
+}
+
+func newReplayer(workers int, shard func(key string) int, execute func([]wal.Command)) *replayer {
+	r := &replayer{
+		shard:   shard,
+		queues:  make([]chan []wal.Command, workers),
+		batches: make([][]wal.Command, workers),
+	}
+
+	r.wg.Add(workers)
+	for i := range r.queues {
+		r.queues[i] = make(chan []wal.Command, replayQueueSize)
+		go func(queue <-chan []wal.Command) {
+			defer r.wg.Done()
+			for commands := range queue {
+				execute(commands)
+			}
+		}(r.queues[i])
+	}
+
+	return r
+}
+
+func (r *replayer) apply(commands []wal.Command) {
+	for _, command := range commands {
+		worker := 0
+		if len(command.Args) > 0 {
+			worker = r.shard(command.Args[0]) % len(r.queues)
+		}
+		if r.batches[worker] == nil {
+			r.batches[worker] = make([]wal.Command, 0, replayBatchSize)
+		}
+		r.batches[worker] = append(r.batches[worker], command)
+		if len(r.batches[worker]) >= replayBatchSize {
+			r.flush(worker)
+		}
+	}
+}
+
+func (r *replayer) flush(worker int) {
+	if len(r.batches[worker]) > 0 {
+		r.queues[worker] <- r.batches[worker]
+		r.batches[worker] = nil
+	}
+}
+
+// wait blocks until all the commands are applied.
+func (r *replayer) wait() {
+	for i, queue := range r.queues {
+		r.flush(i)
+		close(queue)
+	}
+	r.wg.Wait()
+}
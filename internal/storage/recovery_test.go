@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"testing"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/engine"
+	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// replayBatches returns batches of WAL commands, every key is set several
+// times and some keys are deleted at the end, so the result depends on order.
+func replayBatches(keys, rounds, batchSize int) [][]wal.Command {
+	var commands []wal.Command
+	for round := range rounds {
+		for key := range keys {
+			commands = append(commands, wal.Command{
+				LSN:         len(commands),
+				CommandType: setCommand,
+				Args:        []string{"key" + strconv.Itoa(key), strconv.Itoa(round)},
+			})
+		}
+	}
+	for key := 0; key < keys; key += 3 {
+		commands = append(commands, wal.Command{
+			LSN:         len(commands),
+			CommandType: delCommand,
+			Args:        []string{"key" + strconv.Itoa(key)},
+		})
+	}
+
+	var batches [][]wal.Command
+	for len(commands) > 0 {
+		size := min(batchSize, len(commands))
+		batches = append(batches, commands[:size])
+		commands = commands[size:]
+	}
+
+	return batches
+}
+
+func newRecoveringDatabase(t testing.TB, batches [][]wal.Command, workers int) (*Database, *engine.Engine) {
+	engine, err := engine.NewEngine(16)
+	require.NoError(t, err)
+
+	w := NewMockWal(t)
+	w.EXPECT().RecoverTo(0, wal.RecoveryTarget{}, mock.Anything).RunAndReturn(func(_ int, _ wal.RecoveryTarget, apply func([]wal.Command)) error {
+		for _, batch := range batches {
+			apply(batch)
+		}
+		return nil
+	}).Maybe()
+
+	database, err := NewDatabase(NewMockCompute(t), engine, w, nil,
+		slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithRecoveryWorkers(workers),
+	)
+	require.NoError(t, err)
+
+	return database, engine
+}
+
+func TestRecover_Parallel(t *testing.T) {
+	t.Parallel()
+
+	const keys, rounds = 100, 5
+	database, engine := newRecoveringDatabase(t, replayBatches(keys, rounds, 7), 4)
+
+	require.NoError(t, database.Recover())
+
+	for key := range keys {
+		value, ok := engine.Get("key" + strconv.Itoa(key))
+		if key%3 == 0 {
+			assert.False(t, ok)
+			continue
+		}
+		assert.True(t, ok)
+		assert.Equal(t, strconv.Itoa(rounds-1), value)
+	}
+}
+
+func BenchmarkRecover(b *testing.B) {
+	batches := replayBatches(10000, 10, 100)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for range b.N {
+				database, _ := newRecoveringDatabase(b, batches, workers)
+				if err := database.Recover(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -62,6 +62,63 @@ func (_c *MockClient_Close_Call) RunAndReturn(run func() error) *MockClient_Clos
 	return _c
 }
 
+// Receive provides a mock function with no fields
+func (_m *MockClient) Receive() ([]byte, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Receive")
+	}
+
+	var r0 []byte
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]byte, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []byte); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockClient_Receive_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Receive'
+type MockClient_Receive_Call struct {
+	*mock.Call
+}
+
+// Receive is a helper method to define mock.On call
+func (_e *MockClient_Expecter) Receive() *MockClient_Receive_Call {
+	return &MockClient_Receive_Call{Call: _e.mock.On("Receive")}
+}
+
+func (_c *MockClient_Receive_Call) Run(run func()) *MockClient_Receive_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockClient_Receive_Call) Return(_a0 []byte, _a1 error) *MockClient_Receive_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockClient_Receive_Call) RunAndReturn(run func() ([]byte, error)) *MockClient_Receive_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Send provides a mock function with given fields: request
 func (_m *MockClient) Send(request []byte) ([]byte, error) {
 	ret := _m.Called(request)
@@ -120,6 +177,52 @@ func (_c *MockClient_Send_Call) RunAndReturn(run func([]byte) ([]byte, error)) *
 	return _c
 }
 
+// Write provides a mock function with given fields: message
+func (_m *MockClient) Write(message []byte) error {
+	ret := _m.Called(message)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Write")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]byte) error); ok {
+		r0 = rf(message)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockClient_Write_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Write'
+type MockClient_Write_Call struct {
+	*mock.Call
+}
+
+// Write is a helper method to define mock.On call
+//   - message []byte
+func (_e *MockClient_Expecter) Write(message interface{}) *MockClient_Write_Call {
+	return &MockClient_Write_Call{Call: _e.mock.On("Write", message)}
+}
+
+func (_c *MockClient_Write_Call) Run(run func(message []byte)) *MockClient_Write_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].([]byte))
+	})
+	return _c
+}
+
+func (_c *MockClient_Write_Call) Return(_a0 error) *MockClient_Write_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockClient_Write_Call) RunAndReturn(run func([]byte) error) *MockClient_Write_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockClient creates a new instance of MockClient. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockClient(t interface {
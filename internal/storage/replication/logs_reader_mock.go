@@ -0,0 +1,82 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package replication
+
+import (
+	wal "github.com/DaniilZ77/InMemDB/internal/storage/wal"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockLogsReader is an autogenerated mock type for the LogsReader type
+type MockLogsReader struct {
+	mock.Mock
+}
+
+type MockLogsReader_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockLogsReader) EXPECT() *MockLogsReader_Expecter {
+	return &MockLogsReader_Expecter{mock: &_m.Mock}
+}
+
+// ReadLogsFrom provides a mock function with given fields: lsn, fn
+func (_m *MockLogsReader) ReadLogsFrom(lsn int, fn func([]wal.Command) error) error {
+	ret := _m.Called(lsn, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReadLogsFrom")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, func([]wal.Command) error) error); ok {
+		r0 = rf(lsn, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockLogsReader_ReadLogsFrom_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReadLogsFrom'
+type MockLogsReader_ReadLogsFrom_Call struct {
+	*mock.Call
+}
+
+// ReadLogsFrom is a helper method to define mock.On call
+//   - lsn int
+//   - fn func([]wal.Command) error
+func (_e *MockLogsReader_Expecter) ReadLogsFrom(lsn interface{}, fn interface{}) *MockLogsReader_ReadLogsFrom_Call {
+	return &MockLogsReader_ReadLogsFrom_Call{Call: _e.mock.On("ReadLogsFrom", lsn, fn)}
+}
+
+func (_c *MockLogsReader_ReadLogsFrom_Call) Run(run func(lsn int, fn func([]wal.Command) error)) *MockLogsReader_ReadLogsFrom_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(int), args[1].(func([]wal.Command) error))
+	})
+	return _c
+}
+
+func (_c *MockLogsReader_ReadLogsFrom_Call) Return(_a0 error) *MockLogsReader_ReadLogsFrom_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockLogsReader_ReadLogsFrom_Call) RunAndReturn(run func(int, func([]wal.Command) error) error) *MockLogsReader_ReadLogsFrom_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockLogsReader creates a new instance of MockLogsReader. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockLogsReader(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockLogsReader {
+	mock := &MockLogsReader{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,126 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package replication
+
+import (
+	wal "github.com/DaniilZ77/InMemDB/internal/storage/wal"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockLogsWriter is an autogenerated mock type for the LogsWriter type
+type MockLogsWriter struct {
+	mock.Mock
+}
+
+type MockLogsWriter_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockLogsWriter) EXPECT() *MockLogsWriter_Expecter {
+	return &MockLogsWriter_Expecter{mock: &_m.Mock}
+}
+
+// Sync provides a mock function with no fields
+func (_m *MockLogsWriter) Sync() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Sync")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockLogsWriter_Sync_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Sync'
+type MockLogsWriter_Sync_Call struct {
+	*mock.Call
+}
+
+// Sync is a helper method to define mock.On call
+func (_e *MockLogsWriter_Expecter) Sync() *MockLogsWriter_Sync_Call {
+	return &MockLogsWriter_Sync_Call{Call: _e.mock.On("Sync")}
+}
+
+func (_c *MockLogsWriter_Sync_Call) Run(run func()) *MockLogsWriter_Sync_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockLogsWriter_Sync_Call) Return(_a0 error) *MockLogsWriter_Sync_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockLogsWriter_Sync_Call) RunAndReturn(run func() error) *MockLogsWriter_Sync_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WriteLogs provides a mock function with given fields: commands
+func (_m *MockLogsWriter) WriteLogs(commands []wal.Command) error {
+	ret := _m.Called(commands)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WriteLogs")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]wal.Command) error); ok {
+		r0 = rf(commands)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockLogsWriter_WriteLogs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WriteLogs'
+type MockLogsWriter_WriteLogs_Call struct {
+	*mock.Call
+}
+
+// WriteLogs is a helper method to define mock.On call
+//   - commands []wal.Command
+func (_e *MockLogsWriter_Expecter) WriteLogs(commands interface{}) *MockLogsWriter_WriteLogs_Call {
+	return &MockLogsWriter_WriteLogs_Call{Call: _e.mock.On("WriteLogs", commands)}
+}
+
+func (_c *MockLogsWriter_WriteLogs_Call) Run(run func(commands []wal.Command)) *MockLogsWriter_WriteLogs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].([]wal.Command))
+	})
+	return _c
+}
+
+func (_c *MockLogsWriter_WriteLogs_Call) Return(_a0 error) *MockLogsWriter_WriteLogs_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockLogsWriter_WriteLogs_Call) RunAndReturn(run func([]wal.Command) error) *MockLogsWriter_WriteLogs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockLogsWriter creates a new instance of MockLogsWriter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockLogsWriter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockLogsWriter {
+	mock := &MockLogsWriter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -1,81 +1,478 @@
 package replication
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"log/slog"
-	"os"
-	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/DaniilZ77/InMemDB/internal/common"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
 	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
 )
 
-//go:generate mockery --name=NextSegmentProvider --case=snake --inpackage --inpackage-suffix --with-expecter
-type NextSegmentProvider interface {
-	NextSegment(filename string) (string, error)
+//go:generate mockery --name=LogsReader --case=snake --inpackage --inpackage-suffix --with-expecter
+type LogsReader interface {
+	ReadLogsFrom(lsn int, fn func([]wal.Command) error) error
+}
+
+const (
+	defaultReplicaTimeout    = time.Minute
+	defaultHeartbeatInterval = time.Second
+	defaultLagThreshold      = 10 * time.Second
+	// subscriberBuffer is amount of committed batches queued for a stream,
+	// the stream which falls further behind catches up from disk.
+	subscriberBuffer = 1024
+	// maxPendingBatches limits unacknowledged batches remembered for a
+	// replica, the oldest ones are forgotten if it doesn't acknowledge them.
+	maxPendingBatches = 1 << 16
+)
+
+// replicaState is the next LSN sent to the replica and when it was sent a
+// message last time, streams is amount of its open streams. ackedLSN is the
+// next LSN the replica applies, batches sent after it are pending.
+type replicaState struct {
+	lsn      int
+	lastSync time.Time
+	streams  int
+	ackedLSN int
+	lastAck  time.Time
+	pending  []pendingBatch
+	lagging  bool
+}
+
+// pendingBatch is a batch sent to replica, lsn is the next LSN after it.
+type pendingBatch struct {
+	lsn       int
+	bytes     int
+	committed time.Time
+}
+
+// subscriber receives batches committed while the stream is open.
+type subscriber struct {
+	batches    chan []wal.Command
+	overflowed atomic.Bool
 }
 
 type Master struct {
-	segmentProvider NextSegmentProvider
-	walDirectory    string
-	log             *slog.Logger
+	logs              LogsReader
+	keys              *disk.Keyring
+	codec             disk.Codec
+	replicaTimeout    time.Duration
+	heartbeatInterval time.Duration
+	lagThreshold      time.Duration
+	log               *slog.Logger
+
+	mu          sync.Mutex
+	lsn         int
+	lastSync    time.Time
+	committed   int
+	replicas    map[string]*replicaState
+	subscribers map[*subscriber]struct{}
 }
 
-func NewMaster(segmentProvider NextSegmentProvider, walDirectory string, log *slog.Logger) (*Master, error) {
-	if segmentProvider == nil {
-		return nil, errors.New("segment provider is nil")
+func NewMaster(logs LogsReader, log *slog.Logger, opts ...MasterOption) (*Master, error) {
+	if logs == nil {
+		return nil, errors.New("logs reader is nil")
 	}
 	if log == nil {
 		return nil, errors.New("log is nil")
 	}
 
-	return &Master{
-		segmentProvider: segmentProvider,
-		walDirectory:    walDirectory,
-		log:             log,
-	}, nil
+	master := &Master{
+		logs:              logs,
+		replicaTimeout:    defaultReplicaTimeout,
+		heartbeatInterval: defaultHeartbeatInterval,
+		lagThreshold:      defaultLagThreshold,
+		log:               log,
+		replicas:          make(map[string]*replicaState),
+		subscribers:       make(map[*subscriber]struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(master)
+	}
+
+	return master, nil
 }
 
 func (m *Master) IsSlave() bool {
 	return false
 }
 
+// Stats returns lag of the most lagging connected replica.
+func (m *Master) Stats() Stats {
+	stats := Stats{Role: RoleMaster}
+	for _, replica := range m.Replicas() {
+		stats.LagRecords = max(stats.LagRecords, replica.LagRecords)
+		stats.Lag = max(stats.Lag, replica.Lag)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats.LSN = m.lsn
+	stats.LastSync = m.lastSync
+	return stats
+}
+
+// Replicas returns connected replicas ordered by id.
+func (m *Master) Replicas() []ReplicaInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	replicas := make([]ReplicaInfo, 0, len(m.replicas))
+	for id, replica := range m.replicas {
+		if replica.streams > 0 {
+			replicas = append(replicas, m.replicaInfo(id, replica, now))
+		}
+	}
+	slices.SortFunc(replicas, func(a, b ReplicaInfo) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return replicas
+}
+
+// replicaInfo must be called with mu held. Lag of replica which has nothing
+// pending is counted from its last acknowledgement.
+func (m *Master) replicaInfo(id string, replica *replicaState, now time.Time) ReplicaInfo {
+	info := ReplicaInfo{
+		ID:         id,
+		LSN:        replica.lsn,
+		AckedLSN:   replica.ackedLSN,
+		LastAck:    replica.lastAck,
+		LagRecords: max(0, m.committed-replica.ackedLSN),
+	}
+	for _, batch := range replica.pending {
+		info.LagBytes += batch.bytes
+	}
+	if info.LagRecords > 0 {
+		if len(replica.pending) > 0 {
+			info.Lag = now.Sub(replica.pending[0].committed)
+		} else {
+			info.Lag = now.Sub(replica.lastAck)
+		}
+	}
+
+	return info
+}
+
+// RetainedLSN returns the acknowledged LSN of the most lagging replica among
+// the ones streaming or disconnected within replica timeout. Replicas
+// disconnected for longer are forgotten.
+func (m *Master) RetainedLSN() (uint64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var retained int
+	var ok bool
+	for id, replica := range m.replicas {
+		if replica.streams == 0 && time.Since(replica.lastSync) > m.replicaTimeout {
+			delete(m.replicas, id)
+			continue
+		}
+		if !ok || replica.ackedLSN < retained {
+			retained, ok = replica.ackedLSN, true
+		}
+	}
+
+	return uint64(retained), ok
+}
+
 func (m *Master) GetReplicationStream() <-chan []wal.Command {
 	return nil
 }
 
-func (m *Master) HandleRequest(request []byte) (response []byte, err error) {
-	defer func() {
-		if err != nil {
-			m.log.Warn("failed to handle request", slog.Any("error", err))
-			response, err = common.Encode(NewErrorResponse())
+// Applied does nothing, master has no replication stream.
+func (m *Master) Applied() {}
+
+// Publish passes committed commands to the open streams, see
+// wal.WithCommitHook. It doesn't block: stream which can't keep up catches
+// up from disk.
+func (m *Master) Publish(commands []wal.Command) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.committed = max(m.committed, commands[len(commands)-1].LSN+1)
+	for subscriber := range m.subscribers {
+		select {
+		case subscriber.batches <- commands:
+		default:
+			subscriber.overflowed.Store(true)
 		}
-	}()
+	}
+}
 
-	var decodedRequest Request
-	decodedRequest, err = common.DecodeOne[Request](request)
+// Stream sends commands to the replica starting from the LSN of its request:
+// first the logged ones, then batches as they are committed. Heartbeat is
+// sent every heartbeat interval nothing else is sent. Acknowledgements of the
+// replica are read by receive.
+func (m *Master) Stream(ctx context.Context, request []byte, send func([]byte) error, receive func() ([]byte, error)) error {
+	decodedRequest, err := common.DecodeOne[Request](request)
 	if err != nil {
+		return m.sendError(send, err)
+	}
+
+	log := m.log.With(slog.String("replica_id", decodedRequest.ReplicaID))
+	log.Info("replica connected", slog.Int("lsn", decodedRequest.LSN))
+	defer log.Info("replica disconnected")
+
+	subscriber := m.subscribe(decodedRequest.ReplicaID, decodedRequest.LSN)
+	defer m.unsubscribe(decodedRequest.ReplicaID, subscriber)
+
+	go m.receiveAcks(decodedRequest.ReplicaID, receive, log)
+
+	stream := &replicaStream{master: m, id: decodedRequest.ReplicaID, lsn: decodedRequest.LSN, send: send}
+	if err := stream.catchUp(); err != nil {
+		return m.sendError(send, err)
+	}
+
+	ticker := time.NewTicker(m.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case commands := <-subscriber.batches:
+			// Dropped batches and the ones committed before the stream
+			// caught up are read from disk.
+			if subscriber.overflowed.Swap(false) || commands[0].LSN > stream.lsn {
+				err = stream.catchUp()
+			} else {
+				err = stream.sendBatch(commands)
+			}
+		case <-ticker.C:
+			if subscriber.overflowed.Swap(false) {
+				err = stream.catchUp()
+			} else if !stream.sent {
+				err = stream.sendHeartbeat()
+			}
+			stream.sent = false
+			m.checkLag(decodedRequest.ReplicaID, log)
+		}
+		if err != nil {
+			return m.sendError(send, err)
+		}
+	}
+}
+
+func (m *Master) subscribe(replicaID string, lsn int) *subscriber {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	replica, ok := m.replicas[replicaID]
+	if !ok {
+		replica = &replicaState{}
+		m.replicas[replicaID] = replica
+	}
+	now := time.Now()
+	replica.lsn, replica.ackedLSN = lsn, lsn
+	replica.lastSync, replica.lastAck = now, now
+	replica.pending = nil
+	replica.streams++
+
+	subscriber := &subscriber{batches: make(chan []wal.Command, subscriberBuffer)}
+	m.subscribers[subscriber] = struct{}{}
+	return subscriber
+}
+
+func (m *Master) unsubscribe(replicaID string, subscriber *subscriber) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.subscribers, subscriber)
+	if replica, ok := m.replicas[replicaID]; ok {
+		replica.streams--
+		replica.lastSync = time.Now()
+	}
+}
+
+// SetCommittedLSN sets the next LSN master commits, it's the one recovered
+// from WAL at startup, until the first batch is committed.
+func (m *Master) SetCommittedLSN(lsn int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.committed = max(m.committed, lsn)
+}
+
+// committedLSN returns the next LSN master commits.
+func (m *Master) committedLSN() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.committed
+}
+
+// sent records that the replica was sent everything before the lsn, batch is
+// nil for heartbeats.
+func (m *Master) sent(replicaID string, lsn int, batch *pendingBatch) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.lsn, m.lastSync = lsn, now
+	m.committed = max(m.committed, lsn)
+
+	replica, ok := m.replicas[replicaID]
+	if !ok {
 		return
 	}
+	replica.lsn, replica.lastSync = lsn, now
+	if batch != nil {
+		if len(replica.pending) == maxPendingBatches {
+			replica.pending = replica.pending[1:]
+		}
+		replica.pending = append(replica.pending, *batch)
+	}
+}
 
-	m.log.Debug("received request from slave", slog.String("last_segment", decodedRequest.LastSegment))
+// ack records that the replica applied commands before the lsn.
+func (m *Master) ack(replicaID string, lsn int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	var filename string
-	filename, err = m.segmentProvider.NextSegment(decodedRequest.LastSegment)
-	if err != nil {
+	replica, ok := m.replicas[replicaID]
+	if !ok {
 		return
 	}
+	replica.ackedLSN, replica.lastAck = lsn, time.Now()
 
-	if filename == "" {
-		m.log.Debug("no new segments")
-		return common.Encode(NewSuccessResponse("", nil))
+	acked := 0
+	for acked < len(replica.pending) && replica.pending[acked].lsn <= lsn {
+		acked++
 	}
+	replica.pending = replica.pending[acked:]
+}
 
-	var segment []byte
-	segment, err = os.ReadFile(filepath.Join(m.walDirectory, filename))
-	if err != nil {
+func (m *Master) receiveAcks(replicaID string, receive func() ([]byte, error), log *slog.Logger) {
+	for {
+		message, err := receive()
+		if err != nil {
+			log.Debug("stopped receiving acks", slog.Any("error", err))
+			return
+		}
+
+		ack, err := common.DecodeOne[Ack](message)
+		if err != nil {
+			log.Warn("bad ack", slog.Any("error", err))
+			return
+		}
+		m.ack(replicaID, ack.LSN)
+	}
+}
+
+// checkLag logs when the replica falls behind for longer than lag threshold
+// and when it catches up.
+func (m *Master) checkLag(replicaID string, log *slog.Logger) {
+	m.mu.Lock()
+	replica, ok := m.replicas[replicaID]
+	if !ok {
+		m.mu.Unlock()
 		return
 	}
+	info := m.replicaInfo(replicaID, replica, time.Now())
+	lagging := info.Lag > m.lagThreshold
+	changed := lagging != replica.lagging
+	replica.lagging = lagging
+	m.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if lagging {
+		log.Warn("replica is lagging",
+			slog.Int("lag_records", info.LagRecords),
+			slog.Int("lag_bytes", info.LagBytes),
+			slog.Duration("lag", info.Lag),
+		)
+	} else {
+		log.Info("replica caught up")
+	}
+}
+
+// sendError sends the error to the replica and returns it. The stream may be
+// already closed, so failure to send it is ignored.
+func (m *Master) sendError(send func([]byte) error, err error) error {
+	if response, encodeErr := common.Encode(NewErrorResponse(err)); encodeErr == nil {
+		_ = send(response)
+	}
+
+	return err
+}
+
+// replicaStream is the stream of the replica, lsn is the next LSN to send.
+type replicaStream struct {
+	master *Master
+	id     string
+	lsn    int
+	send   func([]byte) error
+	// sent is set if the batch was sent since the last heartbeat tick.
+	sent bool
+}
+
+// catchUp sends logged commands starting from the next LSN.
+func (s *replicaStream) catchUp() error {
+	return s.master.logs.ReadLogsFrom(s.lsn, s.sendBatch)
+}
+
+// sendBatch sends the commands following the next LSN.
+func (s *replicaStream) sendBatch(commands []wal.Command) error {
+	first := 0
+	for first < len(commands) && commands[first].LSN < s.lsn {
+		first++
+	}
+	if first == len(commands) {
+		return nil
+	}
+	commands = commands[first:]
+
+	response, err := NewBatchResponse(commands, s.master.codec, s.master.keys)
+	if err != nil {
+		return err
+	}
+	lsn := commands[len(commands)-1].LSN + 1
+	response.CommittedLSN = max(s.master.committedLSN(), lsn)
+
+	size, err := s.sendResponse(response)
+	if err != nil {
+		return err
+	}
+
+	// Batches read from legacy segments have no commit time.
+	committed := commands[0].Timestamp
+	if committed.IsZero() {
+		committed = time.Now()
+	}
+
+	s.lsn = lsn
+	s.sent = true
+	s.master.sent(s.id, s.lsn, &pendingBatch{lsn: lsn, bytes: size, committed: committed})
+	return nil
+}
+
+func (s *replicaStream) sendHeartbeat() error {
+	response := NewHeartbeatResponse()
+	response.CommittedLSN = s.master.committedLSN()
+	if _, err := s.sendResponse(response); err != nil {
+		return err
+	}
+
+	s.master.sent(s.id, s.lsn, nil)
+	return nil
+}
+
+// sendResponse returns size of the sent message.
+func (s *replicaStream) sendResponse(response Response) (int, error) {
+	data, err := common.Encode(response)
+	if err != nil {
+		return 0, err
+	}
 
-	return common.Encode(NewSuccessResponse(filename, segment))
+	return len(data), s.send(data)
 }
@@ -0,0 +1,55 @@
+package replication
+
+import (
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+)
+
+type MasterOption func(*Master)
+
+// WithReplicaTimeout sets how long disconnected replica is remembered,
+// segments it still needs are kept meanwhile.
+func WithReplicaTimeout(timeout time.Duration) MasterOption {
+	return func(m *Master) {
+		if timeout > 0 {
+			m.replicaTimeout = timeout
+		}
+	}
+}
+
+// WithHeartbeatInterval sets how often heartbeat is sent to replica which is
+// up to date.
+func WithHeartbeatInterval(interval time.Duration) MasterOption {
+	return func(m *Master) {
+		if interval > 0 {
+			m.heartbeatInterval = interval
+		}
+	}
+}
+
+// WithMasterKeyring sets keys batches sent to replicas are encrypted with,
+// they must be the same as slaves' ones.
+func WithMasterKeyring(keys *disk.Keyring) MasterOption {
+	return func(m *Master) {
+		m.keys = keys
+	}
+}
+
+// WithMasterCompression sets codec batches sent to replicas are compressed
+// with, e.g. the one of WAL.
+func WithMasterCompression(codec disk.Codec) MasterOption {
+	return func(m *Master) {
+		m.codec = codec
+	}
+}
+
+// WithMasterLagThreshold sets how long replica may be behind before warning
+// is logged.
+func WithMasterLagThreshold(threshold time.Duration) MasterOption {
+	return func(m *Master) {
+		if threshold > 0 {
+			m.lagThreshold = threshold
+		}
+	}
+}
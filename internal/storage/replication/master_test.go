@@ -1,91 +1,298 @@
 package replication
 
 import (
-	"errors"
+	"context"
 	"io"
 	"log/slog"
-	"os"
 	"testing"
+	"time"
 
 	"github.com/DaniilZ77/InMemDB/internal/common"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
-func TestHandleRequest(t *testing.T) {
-	t.Parallel()
+// committedAt is the time test batches are written at, commands of the batch
+// share it as they do once decoded.
+var committedAt = time.Unix(1700000000, 0)
+
+func batch(from, to int) []wal.Command {
+	return batchAt(from, to, committedAt)
+}
+
+func batchAt(from, to int, timestamp time.Time) []wal.Command {
+	commands := make([]wal.Command, 0, to-from+1)
+	for lsn := from; lsn <= to; lsn++ {
+		commands = append(commands, wal.Command{LSN: lsn, CommandType: 1, Args: []string{"key", "value"}, Timestamp: timestamp})
+	}
+	return commands
+}
+
+// testStream is the stream of the replica: responses are sent to it, acks
+// are received from it and done gets the error the stream finishes with.
+type testStream struct {
+	responses chan Response
+	acks      chan int
+	done      chan error
+}
+
+func startStream(t *testing.T, ctx context.Context, master *Master, replicaID string, lsn int) *testStream {
+	t.Helper()
 
-	dir := t.TempDir()
-	disk := NewMockNextSegmentProvider(t)
-	master, err := NewMaster(disk, dir, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	request, err := common.Encode(NewRequest(replicaID, lsn))
 	require.NoError(t, err)
 
-	firstSegment, secondSegment, testData := "first_segment.log", "second_segment.log", "testdata"
-	data, err := common.Encode(NewRequest("first_segment.log"))
+	stream := &testStream{responses: make(chan Response, 16), acks: make(chan int), done: make(chan error, 1)}
+	send := func(data []byte) error {
+		response, err := common.DecodeOne[Response](data)
+		if err != nil {
+			return err
+		}
+		stream.responses <- response
+		return nil
+	}
+	receive := func() ([]byte, error) {
+		select {
+		case lsn := <-stream.acks:
+			return common.Encode(NewAck(lsn))
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	go func() {
+		stream.done <- master.Stream(ctx, request, send, receive)
+	}()
+
+	return stream
+}
+
+func receive(t *testing.T, responses <-chan Response) Response {
+	t.Helper()
+
+	select {
+	case response := <-responses:
+		return response
+	case <-time.After(time.Second):
+		require.FailNow(t, "no response")
+		return Response{}
+	}
+}
+
+func receiveCommands(t *testing.T, responses <-chan Response) []wal.Command {
+	t.Helper()
+
+	response := receive(t, responses)
+	require.True(t, response.Ok, response.Error)
+	commands, err := response.Commands(nil)
+	require.NoError(t, err)
+	return commands
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	logs := NewMockLogsReader(t)
+	master, err := NewMaster(logs, slog.New(slog.NewJSONHandler(io.Discard, nil)))
 	require.NoError(t, err)
 
-	disk.EXPECT().NextSegment(firstSegment).
-		Return(secondSegment, nil).Once()
+	logs.EXPECT().ReadLogsFrom(2, mock.Anything).RunAndReturn(func(_ int, fn func([]wal.Command) error) error {
+		return fn(batch(1, 3))
+	}).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := startStream(t, ctx, master, "replica", 2)
+
+	assert.Equal(t, batch(2, 3), receiveCommands(t, stream.responses), "logged commands before lsn are skipped")
+
+	master.Publish(batch(3, 5))
+	assert.Equal(t, batch(4, 5), receiveCommands(t, stream.responses), "committed commands are streamed")
+
+	stats := master.Stats()
+	assert.Equal(t, RoleMaster, stats.Role)
+	assert.Equal(t, 6, stats.LSN)
+	assert.False(t, stats.LastSync.IsZero())
+
+	cancel()
+	assert.NoError(t, <-stream.done)
+}
+
+func TestStream_CatchUpAfterGap(t *testing.T) {
+	t.Parallel()
 
-	err = os.WriteFile(dir+"/"+secondSegment, []byte(testData), 0666)
+	logs := NewMockLogsReader(t)
+	master, err := NewMaster(logs, slog.New(slog.NewJSONHandler(io.Discard, nil)))
 	require.NoError(t, err)
 
-	response, err := master.HandleRequest(data)
+	logs.EXPECT().ReadLogsFrom(1, mock.Anything).RunAndReturn(func(_ int, fn func([]wal.Command) error) error {
+		return fn(batch(1, 2))
+	}).Once()
+	logs.EXPECT().ReadLogsFrom(3, mock.Anything).RunAndReturn(func(_ int, fn func([]wal.Command) error) error {
+		if err := fn(batch(3, 4)); err != nil {
+			return err
+		}
+		return fn(batch(5, 5))
+	}).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := startStream(t, ctx, master, "replica", 1)
+
+	assert.Equal(t, batch(1, 2), receiveCommands(t, stream.responses))
+
+	master.Publish(batch(5, 5))
+	assert.Equal(t, batch(3, 4), receiveCommands(t, stream.responses), "missed commands are read from disk")
+	assert.Equal(t, batch(5, 5), receiveCommands(t, stream.responses))
+}
+
+func TestStream_Heartbeat(t *testing.T) {
+	t.Parallel()
+
+	logs := NewMockLogsReader(t)
+	master, err := NewMaster(logs, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithHeartbeatInterval(10*time.Millisecond))
 	require.NoError(t, err)
+	master.SetCommittedLSN(10)
+
+	logs.EXPECT().ReadLogsFrom(1, mock.Anything).Return(nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := startStream(t, ctx, master, "replica", 1)
 
-	decodedResponse, err := common.DecodeOne[Response](response)
+	response := receive(t, stream.responses)
+	assert.True(t, response.Ok)
+	assert.Empty(t, response.Batch)
+	assert.Equal(t, 10, response.CommittedLSN, "committed lsn recovered at startup is reported")
+}
+
+func TestStream_Error(t *testing.T) {
+	t.Parallel()
+
+	logs := NewMockLogsReader(t)
+	master, err := NewMaster(logs, slog.New(slog.NewJSONHandler(io.Discard, nil)))
 	require.NoError(t, err)
 
-	assert.True(t, decodedResponse.Ok)
-	assert.Equal(t, []byte(testData), decodedResponse.Segment)
-	assert.Equal(t, secondSegment, decodedResponse.Filename)
+	t.Run("invalid request", func(t *testing.T) {
+		var sent []byte
+		err := master.Stream(context.Background(), []byte("invalid"), func(data []byte) error {
+			sent = data
+			return nil
+		}, nil)
+		assert.Error(t, err)
+
+		response, err := common.DecodeOne[Response](sent)
+		require.NoError(t, err)
+		assert.False(t, response.Ok)
+		assert.NotEmpty(t, response.Error)
+	})
+
+	t.Run("segments removed", func(t *testing.T) {
+		logs.EXPECT().ReadLogsFrom(1, mock.Anything).Return(disk.ErrSegmentsRemoved).Once()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stream := startStream(t, ctx, master, "replica", 1)
+		assert.ErrorIs(t, <-stream.done, disk.ErrSegmentsRemoved)
+
+		response := receive(t, stream.responses)
+		assert.False(t, response.Ok)
+		assert.True(t, response.Resync)
+		assert.Contains(t, response.Error, "resynced")
+	})
 }
 
-func TestHandleRequest_Error(t *testing.T) {
-	disk := NewMockNextSegmentProvider(t)
-	master, err := NewMaster(disk, "testdir", slog.New(slog.NewJSONHandler(io.Discard, nil)))
+func TestReplicas(t *testing.T) {
+	t.Parallel()
+
+	logs := NewMockLogsReader(t)
+	master, err := NewMaster(logs, slog.New(slog.NewJSONHandler(io.Discard, nil)))
 	require.NoError(t, err)
 
-	okRequest, err := common.Encode(NewRequest("segment.log"))
+	logs.EXPECT().ReadLogsFrom(1, mock.Anything).Return(nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := startStream(t, ctx, master, "replica", 1)
+
+	committed := time.Now().Add(-time.Second)
+	commands := batch(1, 4)
+	commands[0].Timestamp = committed
+	assert.Eventually(t, func() bool {
+		return len(master.Replicas()) == 1
+	}, time.Second, 10*time.Millisecond)
+	master.Publish(commands)
+
+	response := receive(t, stream.responses)
+	assert.Equal(t, 5, response.CommittedLSN)
+
+	replicas := master.Replicas()
+	require.Len(t, replicas, 1)
+	replica := replicas[0]
+	assert.Equal(t, "replica", replica.ID)
+	assert.Equal(t, 5, replica.LSN)
+	assert.Equal(t, 1, replica.AckedLSN)
+	assert.Equal(t, 4, replica.LagRecords)
+	assert.Positive(t, replica.LagBytes)
+	assert.GreaterOrEqual(t, replica.Lag, time.Second, "lag is counted from commit of the oldest unacknowledged batch")
+	assert.Equal(t, 4, master.Stats().LagRecords)
+
+	stream.acks <- 5
+	assert.Eventually(t, func() bool {
+		replica := master.Replicas()[0]
+		return replica.AckedLSN == 5 && replica.LagRecords == 0 && replica.LagBytes == 0 && replica.Lag == 0
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-stream.done)
+	assert.Empty(t, master.Replicas(), "disconnected replicas aren't listed")
+}
+
+func TestRetainedLSN(t *testing.T) {
+	t.Parallel()
+
+	logs := NewMockLogsReader(t)
+	master, err := NewMaster(logs, slog.New(slog.NewJSONHandler(io.Discard, nil)), WithReplicaTimeout(100*time.Millisecond))
 	require.NoError(t, err)
 
-	tests := []struct {
-		name    string
-		request []byte
-		mock    func()
-	}{
-		{
-			name:    "empty request",
-			request: []byte(nil),
-			mock:    func() {},
-		},
-		{
-			name:    "invalid request",
-			request: []byte("invalid"),
-			mock:    func() {},
-		},
-		{
-			name:    "next segment error",
-			request: okRequest,
-			mock: func() {
-				disk.EXPECT().NextSegment("segment.log").Return("", errors.New("failed to get next segment")).Once()
-			},
-		},
-	}
+	_, ok := master.RetainedLSN()
+	assert.False(t, ok)
+
+	logs.EXPECT().ReadLogsFrom(mock.Anything, mock.Anything).Return(nil)
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	first := startStream(t, ctx, master, "first", 10)
+	second := startStream(t, ctx, master, "second", 2)
+	third := startStream(t, ctx, master, "third", 5)
 
-			tt.mock()
+	assert.Eventually(t, func() bool {
+		retained, ok := master.RetainedLSN()
+		return ok && retained == 2
+	}, time.Second, 10*time.Millisecond)
 
-			response, err := master.HandleRequest(tt.request)
-			require.NoError(t, err)
+	master.Publish(batch(2, 6))
+	receiveCommands(t, second.responses)
+	receiveCommands(t, third.responses)
+	retained, _ := master.RetainedLSN()
+	assert.Equal(t, uint64(2), retained, "sent commands are retained until acknowledged")
 
-			decodedResponse, err := common.DecodeOne[Response](response)
-			require.NoError(t, err)
+	second.acks <- 7
+	third.acks <- 7
+	assert.Eventually(t, func() bool {
+		retained, _ := master.RetainedLSN()
+		return retained == 7
+	}, time.Second, 10*time.Millisecond, "replicas acknowledged the batch")
 
-			assert.False(t, decodedResponse.Ok)
-		})
+	cancel()
+	for _, stream := range []*testStream{first, second, third} {
+		require.NoError(t, <-stream.done)
 	}
+
+	_, ok = master.RetainedLSN()
+	assert.True(t, ok, "disconnected replicas are retained within timeout")
+
+	time.Sleep(150 * time.Millisecond)
+	_, ok = master.RetainedLSN()
+	assert.False(t, ok, "replicas timed out")
 }
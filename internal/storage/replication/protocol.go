@@ -1,27 +1,123 @@
 package replication
 
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
+)
+
+const (
+	RoleMaster = "master"
+	RoleSlave  = "slave"
+)
+
+// Stats describes replication state: for slave LSN is the next LSN to apply
+// and LastSync is when the last message was received from master, for master
+// they are the next LSN of the replica streamed to last time and when it was
+// sent a message. Lag is how far slave is behind master: LagRecords is amount
+// of commands master committed and slave hasn't applied yet, Lag is the age
+// of the last applied batch while there are such commands.
+type Stats struct {
+	Role       string
+	LSN        int
+	LastSync   time.Time
+	LagRecords int
+	Lag        time.Duration
+}
+
+// ReplicaInfo describes a replica known to master. AckedLSN is the next LSN
+// the replica acknowledged applying, LagBytes is size of batches sent to the
+// replica and not acknowledged yet and Lag is the age of the oldest of them.
+type ReplicaInfo struct {
+	ID         string
+	LSN        int
+	AckedLSN   int
+	LastAck    time.Time
+	LagRecords int
+	LagBytes   int
+	Lag        time.Duration
+}
+
+// Request starts the stream of commands from LSN, the first one the replica
+// hasn't logged yet. ReplicaID lets master keep segments the replica still
+// needs.
 type Request struct {
-	LastSegment string
+	ReplicaID string
+	LSN       int
 }
 
+// Response is a message of the stream. Batch holds commands starting from LSN
+// encoded as a WAL record, see disk.EncodeSealedRecord: it's compressed as
+// WAL is and sealed with KeyID if it isn't zero, so the stream is as
+// protected as WAL is. Heartbeats have no batch. CommittedLSN is the next LSN
+// master commits, replica measures its lag by it. Resync is set if master no
+// longer has the commands the replica needs, see disk.ErrSegmentsRemoved.
 type Response struct {
-	Ok       bool
-	Filename string
-	Segment  []byte
+	Ok           bool
+	LSN          int
+	KeyID        uint32
+	Batch        []byte
+	CommittedLSN int
+	Error        string
+	Resync       bool
+}
+
+// Ack is sent by replica for every message of the stream, LSN is the next LSN
+// it's going to apply.
+type Ack struct {
+	LSN int
 }
 
-func NewRequest(lastSegment string) Request {
-	return Request{LastSegment: lastSegment}
+func NewRequest(replicaID string, lsn int) Request {
+	return Request{ReplicaID: replicaID, LSN: lsn}
 }
 
-func NewSuccessResponse(filename string, segment []byte) Response {
-	return Response{
-		Ok:       true,
-		Filename: filename,
-		Segment:  segment,
+// NewBatchResponse encodes the commands compressed with the codec, they are
+// sealed with the current key of keys, keys may be nil.
+func NewBatchResponse(commands []wal.Command, codec disk.Codec, keys *disk.Keyring) (Response, error) {
+	payload, err := wal.EncodePayload(commands)
+	if err != nil {
+		return Response{}, err
 	}
+
+	lsn := commands[0].LSN
+	keyID := keys.CurrentKey()
+	batch, err := disk.EncodeSealedRecord(uint64(lsn), payload, codec, keys, keyID)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return Response{Ok: true, LSN: lsn, KeyID: keyID, Batch: batch}, nil
 }
 
-func NewErrorResponse() Response {
-	return Response{}
+func NewAck(lsn int) Ack {
+	return Ack{LSN: lsn}
+}
+
+func NewHeartbeatResponse() Response {
+	return Response{Ok: true}
+}
+
+func NewErrorResponse(err error) Response {
+	return Response{Error: err.Error(), Resync: errors.Is(err, disk.ErrSegmentsRemoved)}
+}
+
+// Commands decodes commands of the batch, keys may be nil if it isn't sealed.
+func (r Response) Commands(keys *disk.Keyring) ([]wal.Command, error) {
+	if len(r.Batch) == 0 {
+		return nil, nil
+	}
+
+	record, err := disk.DecodeSealedRecord(r.Batch, keys, r.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if record.LSN != uint64(r.LSN) {
+		return nil, fmt.Errorf("%w: batch lsn %d, expected %d", disk.ErrCorruptedRecord, record.LSN, r.LSN)
+	}
+
+	return wal.DecodeRecord(record)
 }
@@ -0,0 +1,54 @@
+package replication
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchResponse(t *testing.T) {
+	t.Parallel()
+
+	keyring, err := disk.NewKeyring(map[uint32][]byte{1: bytes.Repeat([]byte{1}, 32)}, 1)
+	require.NoError(t, err)
+
+	commands := batch(5, 104)
+	for i := range commands {
+		commands[i].Args = []string{"key", strings.Repeat("value", 10)}
+	}
+
+	plain, err := NewBatchResponse(commands, disk.CodecNone, nil)
+	require.NoError(t, err)
+	compressed, err := NewBatchResponse(commands, disk.CodecFlate, keyring)
+	require.NoError(t, err)
+	assert.Less(t, len(compressed.Batch), len(plain.Batch)/2, "batch is compressed as WAL")
+	assert.Equal(t, uint32(1), compressed.KeyID)
+
+	for _, response := range []Response{plain, compressed} {
+		decoded, err := response.Commands(keyring)
+		require.NoError(t, err)
+		assert.Equal(t, commands, decoded)
+	}
+
+	_, err = compressed.Commands(nil)
+	assert.Error(t, err, "sealed batch can't be read without keys")
+
+	moved := compressed
+	moved.LSN = 6
+	_, err = moved.Commands(keyring)
+	assert.ErrorIs(t, err, disk.ErrCorruptedRecord)
+
+	corrupted := plain
+	corrupted.Batch = bytes.Clone(plain.Batch)
+	corrupted.Batch[len(corrupted.Batch)-1] ^= 1
+	_, err = corrupted.Commands(nil)
+	assert.ErrorIs(t, err, disk.ErrCorruptedRecord)
+
+	empty, err := NewHeartbeatResponse().Commands(nil)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
@@ -1,100 +1,186 @@
 package replication
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/DaniilZ77/InMemDB/internal/common"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
 	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
 )
 
-//go:generate mockery --name=SegmentManager --case=snake --inpackage --inpackage-suffix --with-expecter
-type SegmentManager interface {
-	LastSegment() (string, error)
-	WriteFile(filename string, data []byte) error
+//go:generate mockery --name=LogsWriter --case=snake --inpackage --inpackage-suffix --with-expecter
+type LogsWriter interface {
+	WriteLogs(commands []wal.Command) error
+	Sync() error
 }
 
 //go:generate mockery --name=Client --case=snake --inpackage --inpackage-suffix --with-expecter
 type Client interface {
 	Send(request []byte) ([]byte, error)
+	Receive() ([]byte, error)
+	Write(message []byte) error
 	Close() error
 }
 
+// Slave streams commands from master. lsn is the next LSN to apply, applied
+// is when master committed the last applied batch and committed is the next
+// LSN master commits.
+const idTempSuffix = ".tmp"
+
+// ErrResyncRequired is returned by Start if master no longer has the commands
+// the slave needs, streaming can't be resumed until the slave is resynced.
+var ErrResyncRequired = errors.New("replica must be resynced from master data")
+
 type Slave struct {
+	id                string
+	idFile            string
 	syncInterval      time.Duration
-	lastSegment       string
+	lagThreshold      time.Duration
+	mu                sync.Mutex
+	lsn               int
+	lastSync          time.Time
+	applied           time.Time
+	committed         int
+	lagging           bool
 	replicationStream chan []wal.Command
-	client            Client
-	segmentManager    SegmentManager
-	log               *slog.Logger
+	// appliedBatches is signaled by Applied, the batch is acknowledged to
+	// master only once it's applied.
+	appliedBatches chan struct{}
+	dial           func() (Client, error)
+	logs           LogsWriter
+	keys           *disk.Keyring
+	log            *slog.Logger
 }
 
+// NewSlave creates slave which streams commands from master through clients
+// created by dial, syncInterval is the delay before the failed stream is
+// reopened.
 func NewSlave(
 	syncInterval time.Duration,
-	client Client,
-	segmentManager SegmentManager,
-	log *slog.Logger) (*Slave, error) {
-	if segmentManager == nil {
-		return nil, errors.New("segment manager is nil")
+	dial func() (Client, error),
+	logs LogsWriter,
+	log *slog.Logger,
+	opts ...SlaveOption) (*Slave, error) {
+	if logs == nil {
+		return nil, errors.New("logs writer is nil")
 	}
 	if log == nil {
 		return nil, errors.New("log is nil")
 	}
-	if client == nil {
-		return nil, errors.New("client is nil")
-	}
-
-	lastSegment, err := segmentManager.LastSegment()
-	if err != nil {
-		return nil, err
+	if dial == nil {
+		return nil, errors.New("dial is nil")
 	}
 
-	return &Slave{
+	slave := &Slave{
 		syncInterval:      syncInterval,
-		lastSegment:       lastSegment,
+		lagThreshold:      defaultLagThreshold,
 		replicationStream: make(chan []wal.Command),
-		client:            client,
-		segmentManager:    segmentManager,
+		appliedBatches:    make(chan struct{}, 1),
+		dial:              dial,
+		logs:              logs,
 		log:               log,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(slave)
+	}
+
+	if slave.id == "" {
+		id, err := loadReplicaID(slave.idFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load replica id: %w", err)
+		}
+		slave.id = id
+	}
+
+	return slave, nil
+}
+
+// loadReplicaID reads id from the file, new random id is generated and saved
+// to the file if it doesn't exist. Path may be empty, then id isn't saved.
+func loadReplicaID(path string) (string, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil && len(bytes.TrimSpace(data)) > 0 {
+			return string(bytes.TrimSpace(data)), nil
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(raw)
+	if path == "" {
+		return id, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path+idTempSuffix, []byte(id+"\n"), 0o644); err != nil {
+		return "", err
+	}
+	return id, os.Rename(path+idTempSuffix, path)
 }
 
 func (s *Slave) GetReplicationStream() <-chan []wal.Command {
 	return s.replicationStream
 }
 
-func (s *Slave) Start(ctx context.Context) {
-	ticker := time.NewTicker(s.syncInterval)
+// Applied tells the slave the batch received from the stream is applied, so
+// it may be acknowledged to master.
+func (s *Slave) Applied() {
+	select {
+	case s.appliedBatches <- struct{}{}:
+	default:
+	}
+}
+
+// Start streams commands from master starting from lsn, the first one the
+// slave hasn't logged, until ctx is done. Failed stream is reopened, unless
+// the slave must be resynced, then ErrResyncRequired is returned.
+func (s *Slave) Start(ctx context.Context, lsn int) error {
+	s.mu.Lock()
+	s.lsn = lsn
+	s.mu.Unlock()
 
 	defer func() {
-		ticker.Stop()
 		close(s.replicationStream)
-		if err := s.client.Close(); err != nil {
-			s.log.Warn("failed to close client", slog.Any("error", err))
-		}
 		if v := recover(); v != nil {
 			s.log.Error("panic recovered", slog.Any("error", v))
 		}
 	}()
 
 	for {
-		select {
-		case <-ctx.Done():
+		err := s.stream(ctx)
+		if ctx.Err() != nil {
 			s.log.Info("stopping slave")
-			return
-		default:
+			return nil
 		}
+		if errors.Is(err, ErrResyncRequired) {
+			return err
+		}
+		s.log.Error("replication stream failed", slog.Any("error", err))
 
 		select {
 		case <-ctx.Done():
 			s.log.Info("stopping slave")
-			return
-		case <-ticker.C:
-			if err := s.handle(ctx); err != nil {
-				s.log.Error("failed to handle replication", slog.Any("error", err))
-			}
+			return nil
+		case <-time.After(s.syncInterval):
 		}
 	}
 }
@@ -103,62 +189,171 @@ func (s *Slave) IsSlave() bool {
 	return true
 }
 
-func (s *Slave) receiveSegment() (*Response, error) {
-	request := NewRequest(s.lastSegment)
-	encodedRequest, err := common.Encode(request)
-	if err != nil {
-		return nil, err
+func (s *Slave) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats(time.Now())
+}
+
+// Replicas returns nil, slaves have no replicas.
+func (s *Slave) Replicas() []ReplicaInfo {
+	return nil
+}
+
+// stats must be called with mu held.
+func (s *Slave) stats(now time.Time) Stats {
+	stats := Stats{
+		Role:       RoleSlave,
+		LSN:        s.lsn,
+		LastSync:   s.lastSync,
+		LagRecords: max(0, s.committed-s.lsn),
+	}
+	if stats.LagRecords > 0 && !s.applied.IsZero() {
+		stats.Lag = now.Sub(s.applied)
 	}
 
-	response, err := s.client.Send(encodedRequest)
+	return stats
+}
+
+func (s *Slave) stream(ctx context.Context) error {
+	client, err := s.dial()
 	if err != nil {
-		s.log.Warn("failed to receive segment from master", slog.Any("error", err))
-		return nil, err
+		return err
 	}
+	// Receive blocks until master sends something, so the client is closed
+	// to stop it once ctx is done.
+	stop := context.AfterFunc(ctx, func() {
+		_ = client.Close()
+	})
+	defer func() {
+		if stop() {
+			if err := client.Close(); err != nil {
+				s.log.Warn("failed to close client", slog.Any("error", err))
+			}
+		}
+	}()
 
-	decodedResponse, err := common.DecodeOne[Response](response)
+	s.mu.Lock()
+	request, err := common.Encode(NewRequest(s.id, s.lsn))
+	s.mu.Unlock()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &decodedResponse, nil
+	message, err := client.Send(request)
+	for err == nil {
+		if err = s.handle(ctx, message); err != nil {
+			return err
+		}
+		if err = s.ack(client); err != nil {
+			return err
+		}
+		message, err = client.Receive()
+	}
+
+	return err
 }
 
-func (s *Slave) handle(ctx context.Context) error {
-	response, err := s.receiveSegment()
+// ack tells master the next LSN to apply.
+func (s *Slave) ack(client Client) error {
+	s.mu.Lock()
+	ack, err := common.Encode(NewAck(s.lsn))
+	s.mu.Unlock()
 	if err != nil {
 		return err
 	}
 
-	s.log.Debug("received response from master",
-		slog.Bool("ok", response.Ok),
-		slog.String("filename", response.Filename),
-	)
-
-	if !response.Ok {
-		return nil
-	}
+	return client.Write(ack)
+}
 
-	if response.Filename == "" {
-		s.log.Debug("no new segments")
-		return nil
+func (s *Slave) handle(ctx context.Context, message []byte) error {
+	if message == nil {
+		return errors.New("master closed the stream")
 	}
 
-	err = s.segmentManager.WriteFile(response.Filename, response.Segment)
+	response, err := common.DecodeOne[Response](message)
 	if err != nil {
 		return err
 	}
 
-	decodedData, err := common.DecodeMany[[]wal.Command](response.Segment)
+	if response.Resync {
+		return fmt.Errorf("%w: %s", ErrResyncRequired, response.Error)
+	}
+	if !response.Ok {
+		return fmt.Errorf("master failed to stream: %s", response.Error)
+	}
+
+	s.mu.Lock()
+	s.lastSync = time.Now()
+	s.committed = response.CommittedLSN
+	lsn := s.lsn
+	s.mu.Unlock()
+
+	commands, err := response.Commands(s.keys)
 	if err != nil {
 		return err
 	}
+	if len(commands) == 0 {
+		s.checkLag()
+		return nil
+	}
 
-	s.lastSegment = response.Filename
+	if commands[0].LSN != lsn {
+		return fmt.Errorf("received batch from lsn %d, expected %d", commands[0].LSN, lsn)
+	}
+
+	s.log.Debug("received batch from master",
+		slog.Int("lsn", commands[0].LSN),
+		slog.Int("commands", len(commands)),
+	)
+
+	if err := s.logs.WriteLogs(commands); err != nil {
+		return err
+	}
+	if err := s.logs.Sync(); err != nil {
+		return err
+	}
 
 	select {
-	case s.replicationStream <- decodedData:
+	case s.replicationStream <- commands:
 	case <-ctx.Done():
+		return ctx.Err()
 	}
+	select {
+	case <-s.appliedBatches:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	s.mu.Lock()
+	s.lsn = commands[len(commands)-1].LSN + 1
+	s.applied = commands[0].Timestamp
+	s.mu.Unlock()
+
+	s.checkLag()
 	return nil
 }
+
+// checkLag logs when the slave falls behind master for longer than lag
+// threshold and when it catches up.
+func (s *Slave) checkLag() {
+	s.mu.Lock()
+	stats := s.stats(time.Now())
+	lagging := stats.Lag > s.lagThreshold
+	changed := lagging != s.lagging
+	s.lagging = lagging
+	s.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if lagging {
+		s.log.Warn("slave is lagging behind master",
+			slog.Int("lag_records", stats.LagRecords),
+			slog.Duration("lag", stats.Lag),
+		)
+	} else {
+		s.log.Info("slave caught up with master")
+	}
+}
@@ -0,0 +1,44 @@
+package replication
+
+import (
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+)
+
+type SlaveOption func(*Slave)
+
+// WithKeyring sets keys batches received from master are decrypted with,
+// they must be the same as master's ones.
+func WithKeyring(keys *disk.Keyring) SlaveOption {
+	return func(s *Slave) {
+		s.keys = keys
+	}
+}
+
+// WithReplicaID sets id the slave is known to master by, random one is used
+// by default.
+func WithReplicaID(id string) SlaveOption {
+	return func(s *Slave) {
+		s.id = id
+	}
+}
+
+// WithReplicaIDFile sets file random id is saved to, so that the slave is
+// known to master by the same id after restart. It's used unless the id is
+// set by WithReplicaID.
+func WithReplicaIDFile(path string) SlaveOption {
+	return func(s *Slave) {
+		s.idFile = path
+	}
+}
+
+// WithLagThreshold sets how long slave may be behind master before warning is
+// logged.
+func WithLagThreshold(threshold time.Duration) SlaveOption {
+	return func(s *Slave) {
+		if threshold > 0 {
+			s.lagThreshold = threshold
+		}
+	}
+}
@@ -1,68 +1,225 @@
 package replication
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"io"
 	"log/slog"
-	"sync"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/DaniilZ77/InMemDB/internal/common"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
 	"github.com/DaniilZ77/InMemDB/internal/storage/wal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
+// mustEncode encodes the response, it panics if the response failed to be
+// created.
+func mustEncode(response Response, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	data, err := common.Encode(response)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// dialOnce returns dial which connects with the client once and fails after.
+func dialOnce(client Client) func() (Client, error) {
+	var dialed atomic.Bool
+	return func() (Client, error) {
+		if dialed.Swap(true) {
+			return nil, errors.New("connection refused")
+		}
+		return client, nil
+	}
+}
+
+func runSlave(t *testing.T, slave *Slave, lsn int) [][]wal.Command {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	go slave.Start(ctx, lsn)
+
+	var received [][]wal.Command
+	for commands := range slave.GetReplicationStream() {
+		received = append(received, commands)
+		slave.Applied()
+	}
+	return received
+}
+
 func TestStart(t *testing.T) {
 	t.Parallel()
 
-	disk, client := NewMockSegmentManager(t), NewMockClient(t)
+	logs, client := NewMockLogsWriter(t), NewMockClient(t)
+	keyring, err := disk.NewKeyring(map[uint32][]byte{1: bytes.Repeat([]byte{1}, 32)}, 1)
+	require.NoError(t, err)
+
+	slave, err := NewSlave(50*time.Millisecond, dialOnce(client), logs, slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithReplicaID("replica"), WithKeyring(keyring))
+	require.NoError(t, err)
+
+	request, err := common.Encode(NewRequest("replica", 1))
+	require.NoError(t, err)
+
+	committed := time.Now().Add(-time.Minute).Round(0)
+	first, second := batchAt(1, 3, committed), batchAt(4, 4, committed)
+	heartbeat := NewHeartbeatResponse()
+	heartbeat.CommittedLSN = 6
 
-	disk.EXPECT().LastSegment().Return("segment.log", nil).Once()
+	client.EXPECT().Send(request).Return(mustEncode(NewBatchResponse(first, disk.CodecFlate, keyring)), nil).Once()
+	client.EXPECT().Receive().Return(mustEncode(heartbeat, nil), nil).Once()
+	secondResponse, err := NewBatchResponse(second, disk.CodecFlate, keyring)
+	require.NoError(t, err)
+	secondResponse.CommittedLSN = 6
+	client.EXPECT().Receive().Return(mustEncode(secondResponse, nil), nil).Once()
+	client.EXPECT().Receive().Return(nil, io.EOF).Once()
 	client.EXPECT().Close().Return(nil).Once()
+	for _, lsn := range []int{4, 4, 5} {
+		ack, err := common.Encode(NewAck(lsn))
+		require.NoError(t, err)
+		client.EXPECT().Write(ack).Return(nil).Once()
+	}
+
+	logs.EXPECT().WriteLogs(first).Return(nil).Once()
+	logs.EXPECT().WriteLogs(second).Return(nil).Once()
+	logs.EXPECT().Sync().Return(nil).Twice()
+
+	received := runSlave(t, slave, 1)
+	assert.Equal(t, [][]wal.Command{first, second}, received)
+
+	stats := slave.Stats()
+	assert.Equal(t, RoleSlave, stats.Role)
+	assert.Equal(t, 5, stats.LSN)
+	assert.False(t, stats.LastSync.IsZero())
+	assert.Equal(t, 1, stats.LagRecords, "master committed more than the slave applied")
+	assert.GreaterOrEqual(t, stats.Lag, time.Minute, "lag is the age of the last applied batch")
+}
+
+func TestStart_AckAfterApply(t *testing.T) {
+	t.Parallel()
+
+	logs, client := NewMockLogsWriter(t), NewMockClient(t)
+	slave, err := NewSlave(50*time.Millisecond, dialOnce(client), logs, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	require.NoError(t, err)
 
-	slave, err := NewSlave(50*time.Millisecond, client, disk, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	ack, err := common.Encode(NewAck(3))
 	require.NoError(t, err)
 
-	expectedCommands := []wal.Command{
-		{LSN: 1, CommandType: 1, Args: []string{"name", "Daniil"}},
-		{LSN: 2, CommandType: 0, Args: []string{"name"}},
-		{LSN: 3, CommandType: 2, Args: []string{"name"}},
+	var applied atomic.Bool
+	client.EXPECT().Send(mock.Anything).Return(mustEncode(NewBatchResponse(batch(1, 2), disk.CodecNone, nil)), nil).Once()
+	client.EXPECT().Write(ack).RunAndReturn(func([]byte) error {
+		assert.True(t, applied.Load(), "batch is acknowledged before it's applied")
+		return nil
+	}).Once()
+	client.EXPECT().Receive().Return(nil, io.EOF).Once()
+	client.EXPECT().Close().Return(nil).Once()
+	logs.EXPECT().WriteLogs(mock.Anything).Return(nil).Once()
+	logs.EXPECT().Sync().Return(nil).Once()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	go slave.Start(ctx, 1)
+
+	<-slave.GetReplicationStream()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, slave.Stats().LSN, "lsn isn't moved until the batch is applied")
+	applied.Store(true)
+	slave.Applied()
+
+	assert.Eventually(t, func() bool {
+		return slave.Stats().LSN == 3
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestStart_ResyncRequired(t *testing.T) {
+	t.Parallel()
+
+	logs, client := NewMockLogsWriter(t), NewMockClient(t)
+	var dials atomic.Int32
+	dial := func() (Client, error) {
+		dials.Add(1)
+		return client, nil
 	}
-	encodedExpectedCommands, err := common.Encode(expectedCommands)
+	slave, err := NewSlave(10*time.Millisecond, dial, logs, slog.New(slog.NewJSONHandler(io.Discard, nil)))
 	require.NoError(t, err)
 
-	request, err := common.Encode(NewRequest("segment.log"))
+	client.EXPECT().Send(mock.Anything).Return(mustEncode(NewErrorResponse(disk.ErrSegmentsRemoved), nil), nil).Once()
+	client.EXPECT().Close().Return(nil).Once()
+
+	err = slave.Start(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrResyncRequired)
+	assert.Equal(t, int32(1), dials.Load(), "stream isn't reopened")
+
+	_, ok := <-slave.GetReplicationStream()
+	assert.False(t, ok, "replication stream is closed")
+}
+
+func TestNewSlave_ReplicaIDFile(t *testing.T) {
+	t.Parallel()
+
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "wal", ".replica_id")
+	dial := func() (Client, error) { return nil, errors.New("connection refused") }
+
+	first, err := NewSlave(time.Second, dial, NewMockLogsWriter(t), log, WithReplicaIDFile(path))
 	require.NoError(t, err)
-	response, err := common.Encode(NewSuccessResponse("segment.log", encodedExpectedCommands))
+	require.NotEmpty(t, first.id)
+
+	restarted, err := NewSlave(time.Second, dial, NewMockLogsWriter(t), log, WithReplicaIDFile(path))
 	require.NoError(t, err)
+	assert.Equal(t, first.id, restarted.id, "generated id is kept after restart")
 
-	client.EXPECT().Send(request).Return(response, nil)
-	disk.EXPECT().WriteFile("segment.log", encodedExpectedCommands).Return(nil)
+	configured, err := NewSlave(time.Second, dial, NewMockLogsWriter(t), log, WithReplicaID("replica"), WithReplicaIDFile(path))
+	require.NoError(t, err)
+	assert.Equal(t, "replica", configured.id)
+}
 
-	wg := sync.WaitGroup{}
-	wg.Add(2)
+func TestStart_Errors(t *testing.T) {
+	t.Parallel()
 
-	commandsNumber := 0
+	tests := []struct {
+		name     string
+		response []byte
+	}{
+		{
+			name:     "master error",
+			response: mustEncode(NewErrorResponse(errors.New("failed to read logs")), nil),
+		},
+		{
+			name:     "lsn mismatch",
+			response: mustEncode(NewBatchResponse(batch(3, 4), disk.CodecNone, nil)),
+		},
+		{
+			name:     "invalid response",
+			response: []byte("invalid"),
+		},
+	}
 
-	go func() {
-		defer wg.Done()
-		for commands := range slave.GetReplicationStream() {
-			commandsNumber++
-			assert.Equal(t, expectedCommands, commands)
-		}
-	}()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
-	defer cancel()
+			logs, client := NewMockLogsWriter(t), NewMockClient(t)
+			slave, err := NewSlave(50*time.Millisecond, dialOnce(client), logs, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+			require.NoError(t, err)
 
-	go func() {
-		defer wg.Done()
-		slave.Start(ctx)
-	}()
+			client.EXPECT().Send(mock.Anything).Return(tt.response, nil).Once()
+			client.EXPECT().Close().Return(nil).Once()
 
-	wg.Wait()
-	assert.LessOrEqual(t, commandsNumber, 10)
-	assert.Greater(t, commandsNumber, 0)
+			assert.Empty(t, runSlave(t, slave, 1))
+			assert.Equal(t, 1, slave.Stats().LSN)
+		})
+	}
 }
@@ -20,6 +20,38 @@ func (_m *MockReplication) EXPECT() *MockReplication_Expecter {
 	return &MockReplication_Expecter{mock: &_m.Mock}
 }
 
+// Applied provides a mock function with no fields
+func (_m *MockReplication) Applied() {
+	_m.Called()
+}
+
+// MockReplication_Applied_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Applied'
+type MockReplication_Applied_Call struct {
+	*mock.Call
+}
+
+// Applied is a helper method to define mock.On call
+func (_e *MockReplication_Expecter) Applied() *MockReplication_Applied_Call {
+	return &MockReplication_Applied_Call{Call: _e.mock.On("Applied")}
+}
+
+func (_c *MockReplication_Applied_Call) Run(run func()) *MockReplication_Applied_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockReplication_Applied_Call) Return() *MockReplication_Applied_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockReplication_Applied_Call) RunAndReturn(run func()) *MockReplication_Applied_Call {
+	_c.Run(run)
+	return _c
+}
+
 // GetReplicationStream provides a mock function with no fields
 func (_m *MockReplication) GetReplicationStream() <-chan []wal.Command {
 	ret := _m.Called()
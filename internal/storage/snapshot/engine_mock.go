@@ -0,0 +1,99 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package snapshot
+
+import mock "github.com/stretchr/testify/mock"
+
+// MockEngine is an autogenerated mock type for the Engine type
+type MockEngine struct {
+	mock.Mock
+}
+
+type MockEngine_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockEngine) EXPECT() *MockEngine_Expecter {
+	return &MockEngine_Expecter{mock: &_m.Mock}
+}
+
+// Range provides a mock function with given fields: fn
+func (_m *MockEngine) Range(fn func(string, string)) {
+	_m.Called(fn)
+}
+
+// MockEngine_Range_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Range'
+type MockEngine_Range_Call struct {
+	*mock.Call
+}
+
+// Range is a helper method to define mock.On call
+//   - fn func(string , string)
+func (_e *MockEngine_Expecter) Range(fn interface{}) *MockEngine_Range_Call {
+	return &MockEngine_Range_Call{Call: _e.mock.On("Range", fn)}
+}
+
+func (_c *MockEngine_Range_Call) Run(run func(fn func(string, string))) *MockEngine_Range_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(func(string, string)))
+	})
+	return _c
+}
+
+func (_c *MockEngine_Range_Call) Return() *MockEngine_Range_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockEngine_Range_Call) RunAndReturn(run func(func(string, string))) *MockEngine_Range_Call {
+	_c.Run(run)
+	return _c
+}
+
+// Set provides a mock function with given fields: key, value
+func (_m *MockEngine) Set(key string, value string) {
+	_m.Called(key, value)
+}
+
+// MockEngine_Set_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Set'
+type MockEngine_Set_Call struct {
+	*mock.Call
+}
+
+// Set is a helper method to define mock.On call
+//   - key string
+//   - value string
+func (_e *MockEngine_Expecter) Set(key interface{}, value interface{}) *MockEngine_Set_Call {
+	return &MockEngine_Set_Call{Call: _e.mock.On("Set", key, value)}
+}
+
+func (_c *MockEngine_Set_Call) Run(run func(key string, value string)) *MockEngine_Set_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockEngine_Set_Call) Return() *MockEngine_Set_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockEngine_Set_Call) RunAndReturn(run func(string, string)) *MockEngine_Set_Call {
+	_c.Run(run)
+	return _c
+}
+
+// NewMockEngine creates a new instance of MockEngine. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockEngine(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockEngine {
+	mock := &MockEngine{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
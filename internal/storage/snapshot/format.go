@@ -0,0 +1,198 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+)
+
+// Snapshot is a sequence of WAL records (see disk.EncodeRecord), LSN of every
+// record is the snapshot LSN. First byte of the payload is the record kind:
+//
+//	header  'H' magic[8] "INMEMSNP" | version uint16 | key uint32
+//	entries 'E' count uvarint { key_len uvarint | key | value_len uvarint | value }
+//	trailer 'T' entries uvarint, total amount of entries in the snapshot
+//
+// If key isn't zero, payloads of the records following the header are
+// encrypted with it (see disk.Keyring), index of the record is authenticated.
+// Snapshots of the first version have no key.
+const (
+	formatPlain   uint16 = 1
+	formatVersion uint16 = 2
+
+	headerKind  = 'H'
+	entriesKind = 'E'
+	trailerKind = 'T'
+)
+
+var (
+	ErrCorruptedSnapshot = errors.New("corrupted snapshot")
+
+	snapshotMagic = []byte("INMEMSNP")
+)
+
+// recordEncoder encodes records of the snapshot, encrypting them if the key
+// is set.
+type recordEncoder struct {
+	lsn   uint64
+	keys  *disk.Keyring
+	keyID uint32
+	index uint64
+}
+
+func (e *recordEncoder) encode(payload []byte) ([]byte, error) {
+	e.index++
+	if e.keyID != 0 {
+		var err error
+		if payload, err = e.keys.Seal(e.keyID, e.index, disk.CodecNone, payload); err != nil {
+			return nil, err
+		}
+	}
+
+	return disk.EncodeRecord(e.lsn, payload), nil
+}
+
+func encodeHeader(lsn uint64, keyID uint32) []byte {
+	payload := append([]byte{headerKind}, snapshotMagic...)
+	payload = binary.LittleEndian.AppendUint16(payload, formatVersion)
+	payload = binary.LittleEndian.AppendUint32(payload, keyID)
+	return disk.EncodeRecord(lsn, payload)
+}
+
+func encodeTrailer(entries uint64) []byte {
+	return binary.AppendUvarint([]byte{trailerKind}, entries)
+}
+
+type entriesChunk struct {
+	count   uint64
+	payload []byte
+}
+
+func (c *entriesChunk) add(key, value string) {
+	c.payload = binary.AppendUvarint(c.payload, uint64(len(key)))
+	c.payload = append(c.payload, key...)
+	c.payload = binary.AppendUvarint(c.payload, uint64(len(value)))
+	c.payload = append(c.payload, value...)
+	c.count++
+}
+
+func (c *entriesChunk) encode() []byte {
+	payload := binary.AppendUvarint([]byte{entriesKind}, c.count)
+	return append(payload, c.payload...)
+}
+
+func (c *entriesChunk) reset() {
+	c.count = 0
+	c.payload = c.payload[:0]
+}
+
+// decode calls fn for every entry of the snapshot and returns its LSN, keys
+// may be nil if snapshot isn't encrypted.
+func decode(data []byte, keys *disk.Keyring, fn func(key, value string)) (uint64, error) {
+	records, _, err := disk.DecodeRecords(data)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrCorruptedSnapshot, err)
+	}
+
+	if len(records) < 2 {
+		return 0, fmt.Errorf("%w: missing header", ErrCorruptedSnapshot)
+	}
+	keyID, ok := decodeHeader(records[0].Payload)
+	if !ok {
+		return 0, fmt.Errorf("%w: missing header", ErrCorruptedSnapshot)
+	}
+
+	if keyID != 0 {
+		for i := range records[1:] {
+			payload, err := keys.Open(keyID, uint64(i+1), disk.CodecNone, records[i+1].Payload)
+			if err != nil {
+				return 0, err
+			}
+			records[i+1].Payload = payload
+		}
+	}
+
+	lsn := records[0].LSN
+	var entries uint64
+	for _, record := range records[1 : len(records)-1] {
+		count, err := decodeEntries(record.Payload, fn)
+		if err != nil {
+			return 0, err
+		}
+		entries += count
+	}
+
+	trailer := records[len(records)-1].Payload
+	if len(trailer) == 0 || trailer[0] != trailerKind {
+		return 0, fmt.Errorf("%w: missing trailer", ErrCorruptedSnapshot)
+	}
+	if expected, n := binary.Uvarint(trailer[1:]); n <= 0 || expected != entries {
+		return 0, fmt.Errorf("%w: entries count mismatch", ErrCorruptedSnapshot)
+	}
+
+	return lsn, nil
+}
+
+// decodeHeader returns id of the key the snapshot is encrypted with.
+func decodeHeader(payload []byte) (uint32, bool) {
+	size := 1 + len(snapshotMagic) + 2
+	if len(payload) < size || payload[0] != headerKind || !bytes.Equal(payload[1:1+len(snapshotMagic)], snapshotMagic) {
+		return 0, false
+	}
+
+	switch binary.LittleEndian.Uint16(payload[size-2:]) {
+	case formatPlain:
+		return 0, len(payload) == size
+	case formatVersion:
+		if len(payload) != size+4 {
+			return 0, false
+		}
+		return binary.LittleEndian.Uint32(payload[size:]), true
+	}
+
+	return 0, false
+}
+
+func decodeEntries(payload []byte, fn func(key, value string)) (uint64, error) {
+	if len(payload) == 0 || payload[0] != entriesKind {
+		return 0, fmt.Errorf("%w: unexpected record", ErrCorruptedSnapshot)
+	}
+	payload = payload[1:]
+
+	count, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return 0, fmt.Errorf("%w: malformed entries", ErrCorruptedSnapshot)
+	}
+	payload = payload[n:]
+
+	for range count {
+		var key, value string
+		var ok bool
+		if key, payload, ok = readString(payload); !ok {
+			return 0, fmt.Errorf("%w: malformed entries", ErrCorruptedSnapshot)
+		}
+		if value, payload, ok = readString(payload); !ok {
+			return 0, fmt.Errorf("%w: malformed entries", ErrCorruptedSnapshot)
+		}
+		fn(key, value)
+	}
+
+	if len(payload) > 0 {
+		return 0, fmt.Errorf("%w: malformed entries", ErrCorruptedSnapshot)
+	}
+
+	return count, nil
+}
+
+func readString(data []byte) (string, []byte, bool) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || length > uint64(len(data)-n) {
+		return "", nil, false
+	}
+
+	data = data[n:]
+	return string(data[:length]), data[length:], true
+}
@@ -0,0 +1,90 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package snapshot
+
+import mock "github.com/stretchr/testify/mock"
+
+// MockSegments is an autogenerated mock type for the Segments type
+type MockSegments struct {
+	mock.Mock
+}
+
+type MockSegments_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockSegments) EXPECT() *MockSegments_Expecter {
+	return &MockSegments_Expecter{mock: &_m.Mock}
+}
+
+// RemoveSegmentsBefore provides a mock function with given fields: lsn
+func (_m *MockSegments) RemoveSegmentsBefore(lsn uint64) ([]string, error) {
+	ret := _m.Called(lsn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveSegmentsBefore")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uint64) ([]string, error)); ok {
+		return rf(lsn)
+	}
+	if rf, ok := ret.Get(0).(func(uint64) []string); ok {
+		r0 = rf(lsn)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uint64) error); ok {
+		r1 = rf(lsn)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockSegments_RemoveSegmentsBefore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RemoveSegmentsBefore'
+type MockSegments_RemoveSegmentsBefore_Call struct {
+	*mock.Call
+}
+
+// RemoveSegmentsBefore is a helper method to define mock.On call
+//   - lsn uint64
+func (_e *MockSegments_Expecter) RemoveSegmentsBefore(lsn interface{}) *MockSegments_RemoveSegmentsBefore_Call {
+	return &MockSegments_RemoveSegmentsBefore_Call{Call: _e.mock.On("RemoveSegmentsBefore", lsn)}
+}
+
+func (_c *MockSegments_RemoveSegmentsBefore_Call) Run(run func(lsn uint64)) *MockSegments_RemoveSegmentsBefore_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uint64))
+	})
+	return _c
+}
+
+func (_c *MockSegments_RemoveSegmentsBefore_Call) Return(_a0 []string, _a1 error) *MockSegments_RemoveSegmentsBefore_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockSegments_RemoveSegmentsBefore_Call) RunAndReturn(run func(uint64) ([]string, error)) *MockSegments_RemoveSegmentsBefore_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockSegments creates a new instance of MockSegments. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockSegments(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockSegments {
+	mock := &MockSegments{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,361 @@
+package snapshot
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+)
+
+const (
+	snapshotPrefix = "snapshot_"
+	snapshotSuffix = ".snap"
+	tempSuffix     = ".tmp"
+	chunkSize      = 64 << 10
+)
+
+//go:generate mockery --name=Engine --case=snake --inpackage --inpackage-suffix --with-expecter
+type Engine interface {
+	Range(fn func(key, value string))
+	Set(key, value string)
+}
+
+//go:generate mockery --name=Segments --case=snake --inpackage --inpackage-suffix --with-expecter
+type Segments interface {
+	RemoveSegmentsBefore(lsn uint64) ([]string, error)
+}
+
+// ErrNoSnapshotBefore is returned if the recovery target precedes every
+// snapshot, WAL before the oldest snapshot is removed.
+var ErrNoSnapshotBefore = errors.New("no snapshot precedes the recovery target")
+
+// Snapshotter keeps snapshots taken within the retention window and the last
+// one before it along with WAL following them, so the state can be restored
+// to any point of the window. Only the latest snapshot is kept by default.
+type Snapshotter struct {
+	directory       string
+	engine          Engine
+	segments        Segments
+	keys            *disk.Keyring
+	retentionWindow time.Duration
+	log             *slog.Logger
+}
+
+func NewSnapshotter(directory string, engine Engine, segments Segments, log *slog.Logger, opts ...SnapshotterOption) (*Snapshotter, error) {
+	if engine == nil {
+		return nil, errors.New("engine is nil")
+	}
+	if segments == nil {
+		return nil, errors.New("segments are nil")
+	}
+	if log == nil {
+		return nil, errors.New("logger is nil")
+	}
+
+	if err := os.MkdirAll(directory, 0777); err != nil {
+		return nil, err
+	}
+
+	snapshotter := &Snapshotter{
+		directory: directory,
+		engine:    engine,
+		segments:  segments,
+		log:       log,
+	}
+
+	for _, opt := range opts {
+		opt(snapshotter)
+	}
+
+	return snapshotter, nil
+}
+
+// Create saves the engine as snapshot with the lsn, all commands before the lsn
+// must be applied to the engine. Once snapshot is durable, snapshots out of the
+// retention window and WAL segments covered by the kept ones are removed.
+func (s *Snapshotter) Create(lsn int) error {
+	started := time.Now()
+	filename := fmt.Sprintf("%s%020d%s", snapshotPrefix, lsn, snapshotSuffix)
+
+	entries, size, err := s.write(filename, uint64(lsn), started)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("snapshot created",
+		slog.String("snapshot", filename),
+		slog.Int("lsn", lsn),
+		slog.Uint64("entries", entries),
+		slog.Int64("bytes", size),
+		slog.Duration("elapsed", time.Since(started)),
+	)
+
+	oldest, err := s.removeExpired(filename, started.Add(-s.retentionWindow))
+	if err != nil {
+		s.log.Warn("failed to remove old snapshots", slog.Any("error", err))
+		return nil
+	}
+
+	if _, err := s.segments.RemoveSegmentsBefore(uint64(oldest)); err != nil {
+		s.log.Warn("failed to remove segments covered by snapshot", slog.Any("error", err))
+	}
+
+	return nil
+}
+
+// write saves the snapshot, its modification time is set to the time it's
+// taken at, so commands written before it are in the snapshot.
+func (s *Snapshotter) write(filename string, lsn uint64, takenAt time.Time) (entries uint64, size int64, err error) {
+	path := filepath.Join(s.directory, filename)
+	file, err := os.OpenFile(path+tempSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		if file != nil {
+			_ = file.Close()
+		}
+		if err != nil {
+			_ = os.Remove(path + tempSuffix)
+		}
+	}()
+
+	writer := bufio.NewWriter(file)
+	encoder := &recordEncoder{lsn: lsn, keys: s.keys, keyID: s.keys.CurrentKey()}
+	if _, err := writer.Write(encodeHeader(lsn, encoder.keyID)); err != nil {
+		return 0, 0, err
+	}
+
+	writeRecord := func(payload []byte) error {
+		record, err := encoder.encode(payload)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(record)
+		return err
+	}
+
+	var chunk entriesChunk
+	var writeErr error
+	s.engine.Range(func(key, value string) {
+		if writeErr != nil {
+			return
+		}
+
+		chunk.add(key, value)
+		entries++
+		if len(chunk.payload) >= chunkSize {
+			writeErr = writeRecord(chunk.encode())
+			chunk.reset()
+		}
+	})
+	if writeErr != nil {
+		return 0, 0, writeErr
+	}
+	if chunk.count > 0 {
+		if err := writeRecord(chunk.encode()); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if err := writeRecord(encodeTrailer(entries)); err != nil {
+		return 0, 0, err
+	}
+	if err := writer.Flush(); err != nil {
+		return 0, 0, err
+	}
+	if err := file.Sync(); err != nil {
+		return 0, 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	closeErr := file.Close()
+	file = nil
+	if closeErr != nil {
+		return 0, 0, closeErr
+	}
+
+	if err := os.Chtimes(path+tempSuffix, takenAt, takenAt); err != nil {
+		return 0, 0, err
+	}
+	if err := os.Rename(path+tempSuffix, path); err != nil {
+		return 0, 0, err
+	}
+
+	return entries, info.Size(), syncDirectory(s.directory)
+}
+
+// Restore loads the latest snapshot into the engine and returns its lsn, WAL
+// should be replayed starting from it. Zero is returned if there is no
+// snapshot.
+func (s *Snapshotter) Restore() (int, error) {
+	return s.RestoreBefore(0, time.Time{})
+}
+
+// RestoreBefore loads the latest snapshot with lsn not greater than maxLSN
+// and taken not after the time, zero maxLSN and time are not limits. Zero is
+// returned if there is no snapshot, ErrNoSnapshotBefore if none fits.
+func (s *Snapshotter) RestoreBefore(maxLSN int, at time.Time) (int, error) {
+	snapshots, err := s.snapshots()
+	if err != nil {
+		return 0, err
+	}
+	if len(snapshots) == 0 {
+		return 0, nil
+	}
+
+	filename := ""
+	for _, snapshot := range slices.Backward(snapshots) {
+		if fits, err := s.precedes(snapshot, maxLSN, at); err != nil {
+			return 0, err
+		} else if fits {
+			filename = snapshot
+			break
+		}
+	}
+	if filename == "" {
+		return 0, ErrNoSnapshotBefore
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.directory, filename))
+	if err != nil {
+		return 0, err
+	}
+
+	var entries int
+	lsn, err := decode(data, s.keys, func(key, value string) {
+		s.engine.Set(key, value)
+		entries++
+	})
+	if errors.Is(err, disk.ErrUnknownKey) || errors.Is(err, disk.ErrDecryption) {
+		return 0, fmt.Errorf("snapshot %s: %w", filename, err)
+	}
+	if err != nil {
+		s.log.Error("snapshot is corrupted", slog.String("snapshot", filename), slog.Any("error", err))
+		return 0, fmt.Errorf("snapshot %s: %w", filename, err)
+	}
+
+	s.log.Info("restored snapshot",
+		slog.String("snapshot", filename),
+		slog.Uint64("lsn", lsn),
+		slog.Int("entries", entries),
+	)
+
+	return int(lsn), nil
+}
+
+// snapshots returns snapshots sorted by lsn, unfinished ones are removed.
+func (s *Snapshotter) snapshots() ([]string, error) {
+	entries, err := os.ReadDir(s.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, tempSuffix) {
+			s.log.Warn("removing unfinished snapshot", slog.String("snapshot", name))
+			if err := os.Remove(filepath.Join(s.directory, name)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if _, ok := parseLSN(name); ok {
+			snapshots = append(snapshots, name)
+		}
+	}
+	slices.Sort(snapshots)
+
+	return snapshots, nil
+}
+
+func (s *Snapshotter) precedes(filename string, maxLSN int, at time.Time) (bool, error) {
+	lsn, _ := parseLSN(filename)
+	if maxLSN > 0 && lsn > maxLSN {
+		return false, nil
+	}
+	if at.IsZero() {
+		return true, nil
+	}
+
+	takenAt, err := s.takenAt(filename)
+	if err != nil {
+		return false, err
+	}
+	return !takenAt.After(at), nil
+}
+
+func (s *Snapshotter) takenAt(filename string) (time.Time, error) {
+	info, err := os.Stat(filepath.Join(s.directory, filename))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return info.ModTime(), nil
+}
+
+// removeExpired removes snapshots preceding the latest one taken not after the
+// cutoff, the latest snapshot is always kept. It returns lsn of the oldest
+// kept snapshot.
+func (s *Snapshotter) removeExpired(latest string, cutoff time.Time) (int, error) {
+	snapshots, err := s.snapshots()
+	if err != nil {
+		return 0, err
+	}
+
+	oldest, _ := parseLSN(latest)
+	expired := false
+	for _, snapshot := range slices.Backward(snapshots) {
+		if snapshot > latest {
+			continue
+		}
+		if expired {
+			if err := os.Remove(filepath.Join(s.directory, snapshot)); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		oldest, _ = parseLSN(snapshot)
+		takenAt, err := s.takenAt(snapshot)
+		if err != nil {
+			return 0, err
+		}
+		expired = !takenAt.After(cutoff)
+	}
+
+	return oldest, nil
+}
+
+func parseLSN(filename string) (int, bool) {
+	if !strings.HasPrefix(filename, snapshotPrefix) || !strings.HasSuffix(filename, snapshotSuffix) {
+		return 0, false
+	}
+
+	lsn, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filename, snapshotPrefix), snapshotSuffix))
+	return lsn, err == nil
+}
+
+func syncDirectory(directory string) error {
+	dir, err := os.Open(directory)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dir.Close() }()
+
+	return dir.Sync()
+}
@@ -0,0 +1,25 @@
+package snapshot
+
+import (
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+)
+
+type SnapshotterOption func(*Snapshotter)
+
+// WithKeyring enables encryption of snapshots with the current key of the
+// keyring, the rest of the keys are used to read old snapshots.
+func WithKeyring(keys *disk.Keyring) SnapshotterOption {
+	return func(s *Snapshotter) {
+		s.keys = keys
+	}
+}
+
+// WithRetentionWindow keeps snapshots and WAL needed to restore the state to
+// any point of the window, see Snapshotter.
+func WithRetentionWindow(window time.Duration) SnapshotterOption {
+	return func(s *Snapshotter) {
+		s.retentionWindow = window
+	}
+}
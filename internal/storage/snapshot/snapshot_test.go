@@ -0,0 +1,285 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSnapshotter(t *testing.T, dir string, opts ...SnapshotterOption) (*Snapshotter, *MockEngine, *MockSegments) {
+	engine, segments := NewMockEngine(t), NewMockSegments(t)
+	snapshotter, err := NewSnapshotter(dir, engine, segments, slog.New(slog.NewJSONHandler(io.Discard, nil)), opts...)
+	require.NoError(t, err)
+
+	return snapshotter, engine, segments
+}
+
+func TestCreateRestore(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	data := make(map[string]string)
+	for i := range 10000 {
+		data[fmt.Sprintf("key%d", i)] = fmt.Sprintf("value%d", i)
+	}
+
+	snapshotter, engine, segments := newTestSnapshotter(t, dir)
+	engine.EXPECT().Range(mock.Anything).Run(func(fn func(string, string)) {
+		for key, value := range data {
+			fn(key, value)
+		}
+	}).Twice()
+	segments.EXPECT().RemoveSegmentsBefore(uint64(10)).Return(nil, nil).Once()
+	segments.EXPECT().RemoveSegmentsBefore(uint64(20)).Return(nil, nil).Once()
+
+	require.NoError(t, snapshotter.Create(10))
+	require.NoError(t, snapshotter.Create(20))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	restored := make(map[string]string)
+	snapshotter, engine, _ = newTestSnapshotter(t, dir)
+	engine.EXPECT().Set(mock.Anything, mock.Anything).Run(func(key, value string) {
+		restored[key] = value
+	})
+
+	lsn, err := snapshotter.Restore()
+	require.NoError(t, err)
+	assert.Equal(t, 20, lsn)
+	assert.Equal(t, data, restored)
+}
+
+func TestRestore_NoSnapshot(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "snapshot_1.snap.tmp"), []byte("unfinished"), 0666))
+
+	snapshotter, _, _ := newTestSnapshotter(t, dir)
+	lsn, err := snapshotter.Restore()
+	require.NoError(t, err)
+	assert.Zero(t, lsn)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestRestore_Corrupted(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	snapshotter, engine, segments := newTestSnapshotter(t, dir)
+	engine.EXPECT().Range(mock.Anything).Run(func(fn func(string, string)) {
+		fn("name", "Daniil")
+	}).Once()
+	segments.EXPECT().RemoveSegmentsBefore(uint64(1)).Return(nil, nil).Once()
+	require.NoError(t, snapshotter.Create(1))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	path := filepath.Join(dir, entries[0].Name())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated", data: data[:len(data)-1]},
+		{name: "without trailer", data: data[:len(data)-len(disk.EncodeRecord(1, encodeTrailer(1)))]},
+		{name: "empty", data: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(path, tt.data, 0666))
+			engine.EXPECT().Set(mock.Anything, mock.Anything).Return().Maybe()
+
+			_, err := snapshotter.Restore()
+			assert.ErrorIs(t, err, ErrCorruptedSnapshot)
+		})
+	}
+}
+
+func newTestKeyring(t *testing.T, ids ...uint32) *disk.Keyring {
+	keys := make(map[uint32][]byte)
+	for _, id := range ids {
+		keys[id] = bytes.Repeat([]byte{byte(id)}, 32)
+	}
+
+	keyring, err := disk.NewKeyring(keys, 0)
+	require.NoError(t, err)
+	return keyring
+}
+
+func TestCreateRestore_Encrypted(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	snapshotter, engine, segments := newTestSnapshotter(t, dir, WithKeyring(newTestKeyring(t, 1)))
+	engine.EXPECT().Range(mock.Anything).Run(func(fn func(string, string)) {
+		fn("name", "Daniil")
+	}).Once()
+	segments.EXPECT().RemoveSegmentsBefore(uint64(5)).Return(nil, nil).Once()
+	require.NoError(t, snapshotter.Create(5))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "Daniil")
+
+	// Key is rotated, the old one is kept to read the snapshot.
+	snapshotter, engine, _ = newTestSnapshotter(t, dir, WithKeyring(newTestKeyring(t, 1, 2)))
+	engine.EXPECT().Set("name", "Daniil").Return().Once()
+	lsn, err := snapshotter.Restore()
+	require.NoError(t, err)
+	assert.Equal(t, 5, lsn)
+
+	tests := []struct {
+		name        string
+		opts        []SnapshotterOption
+		expectedErr error
+	}{
+		{name: "wrong key", opts: []SnapshotterOption{WithKeyring(newTestKeyring(t, 2))}, expectedErr: disk.ErrUnknownKey},
+		{name: "no keys", expectedErr: disk.ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshotter, _, _ := newTestSnapshotter(t, dir, tt.opts...)
+			_, err := snapshotter.Restore()
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+
+	t.Run("same id other key", func(t *testing.T) {
+		keys, err := disk.NewKeyring(map[uint32][]byte{1: bytes.Repeat([]byte{7}, 32)}, 0)
+		require.NoError(t, err)
+
+		snapshotter, _, _ := newTestSnapshotter(t, dir, WithKeyring(keys))
+		_, err = snapshotter.Restore()
+		assert.ErrorIs(t, err, disk.ErrDecryption)
+	})
+}
+
+func TestRestore_PlainFormat(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	chunk := entriesChunk{}
+	chunk.add("name", "Daniil")
+
+	header := append([]byte{headerKind}, snapshotMagic...)
+	header = binary.LittleEndian.AppendUint16(header, formatPlain)
+	data := disk.EncodeRecord(3, header)
+	data = append(data, disk.EncodeRecord(3, chunk.encode())...)
+	data = append(data, disk.EncodeRecord(3, encodeTrailer(1))...)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "snapshot_00000000000000000003.snap"), data, 0666))
+
+	snapshotter, engine, _ := newTestSnapshotter(t, dir)
+	engine.EXPECT().Set("name", "Daniil").Return().Once()
+	lsn, err := snapshotter.Restore()
+	require.NoError(t, err)
+	assert.Equal(t, 3, lsn)
+}
+
+func TestRestoreBefore(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	snapshotter, engine, segments := newTestSnapshotter(t, dir, WithRetentionWindow(time.Hour))
+	engine.EXPECT().Range(mock.Anything).Run(func(fn func(string, string)) {
+		fn("name", "first")
+	}).Once()
+	engine.EXPECT().Range(mock.Anything).Run(func(fn func(string, string)) {
+		fn("name", "second")
+	}).Once()
+	segments.EXPECT().RemoveSegmentsBefore(uint64(10)).Return(nil, nil).Twice()
+
+	require.NoError(t, snapshotter.Create(10))
+	time.Sleep(10 * time.Millisecond)
+	between := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, snapshotter.Create(20))
+
+	tests := []struct {
+		name   string
+		maxLSN int
+		at     time.Time
+		lsn    int
+		value  string
+	}{
+		{name: "latest", lsn: 20, value: "second"},
+		{name: "lsn between", maxLSN: 15, lsn: 10, value: "first"},
+		{name: "lsn of snapshot", maxLSN: 20, lsn: 20, value: "second"},
+		{name: "time between", at: between, lsn: 10, value: "first"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var restored string
+			snapshotter, engine, _ := newTestSnapshotter(t, dir)
+			engine.EXPECT().Set("name", mock.Anything).Run(func(_, value string) {
+				restored = value
+			}).Once()
+
+			lsn, err := snapshotter.RestoreBefore(tt.maxLSN, tt.at)
+			require.NoError(t, err)
+			assert.Equal(t, tt.lsn, lsn)
+			assert.Equal(t, tt.value, restored)
+		})
+	}
+
+	t.Run("before snapshots", func(t *testing.T) {
+		snapshotter, _, _ := newTestSnapshotter(t, dir)
+		_, err := snapshotter.RestoreBefore(5, time.Time{})
+		assert.ErrorIs(t, err, ErrNoSnapshotBefore)
+	})
+}
+
+func TestCreate_RetentionWindow(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	snapshotter, engine, segments := newTestSnapshotter(t, dir, WithRetentionWindow(time.Hour))
+	engine.EXPECT().Range(mock.Anything).Return().Times(4)
+	segments.EXPECT().RemoveSegmentsBefore(mock.Anything).Return(nil, nil).Times(4)
+
+	require.NoError(t, snapshotter.Create(10))
+	require.NoError(t, snapshotter.Create(20))
+	require.NoError(t, snapshotter.Create(30))
+
+	// The first snapshots are out of the window, the second one still covers
+	// its start.
+	old := time.Now().Add(-2 * time.Hour)
+	for _, lsn := range []int{10, 20} {
+		path := filepath.Join(dir, fmt.Sprintf("%s%020d%s", snapshotPrefix, lsn, snapshotSuffix))
+		require.NoError(t, os.Chtimes(path, old, old))
+	}
+
+	require.NoError(t, snapshotter.Create(40))
+	segments.AssertCalled(t, "RemoveSegmentsBefore", uint64(20))
+
+	snapshots, err := snapshotter.snapshots()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		fmt.Sprintf("%s%020d%s", snapshotPrefix, 20, snapshotSuffix),
+		fmt.Sprintf("%s%020d%s", snapshotPrefix, 30, snapshotSuffix),
+		fmt.Sprintf("%s%020d%s", snapshotPrefix, 40, snapshotSuffix),
+	}, snapshots)
+}
@@ -0,0 +1,139 @@
+// Code generated by mockery v2.53.3. DO NOT EDIT.
+
+package storage
+
+import (
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockSnapshotter is an autogenerated mock type for the Snapshotter type
+type MockSnapshotter struct {
+	mock.Mock
+}
+
+type MockSnapshotter_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockSnapshotter) EXPECT() *MockSnapshotter_Expecter {
+	return &MockSnapshotter_Expecter{mock: &_m.Mock}
+}
+
+// Create provides a mock function with given fields: lsn
+func (_m *MockSnapshotter) Create(lsn int) error {
+	ret := _m.Called(lsn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(lsn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockSnapshotter_Create_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Create'
+type MockSnapshotter_Create_Call struct {
+	*mock.Call
+}
+
+// Create is a helper method to define mock.On call
+//   - lsn int
+func (_e *MockSnapshotter_Expecter) Create(lsn interface{}) *MockSnapshotter_Create_Call {
+	return &MockSnapshotter_Create_Call{Call: _e.mock.On("Create", lsn)}
+}
+
+func (_c *MockSnapshotter_Create_Call) Run(run func(lsn int)) *MockSnapshotter_Create_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(int))
+	})
+	return _c
+}
+
+func (_c *MockSnapshotter_Create_Call) Return(_a0 error) *MockSnapshotter_Create_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockSnapshotter_Create_Call) RunAndReturn(run func(int) error) *MockSnapshotter_Create_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RestoreBefore provides a mock function with given fields: maxLSN, at
+func (_m *MockSnapshotter) RestoreBefore(maxLSN int, at time.Time) (int, error) {
+	ret := _m.Called(maxLSN, at)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreBefore")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int, time.Time) (int, error)); ok {
+		return rf(maxLSN, at)
+	}
+	if rf, ok := ret.Get(0).(func(int, time.Time) int); ok {
+		r0 = rf(maxLSN, at)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(int, time.Time) error); ok {
+		r1 = rf(maxLSN, at)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockSnapshotter_RestoreBefore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RestoreBefore'
+type MockSnapshotter_RestoreBefore_Call struct {
+	*mock.Call
+}
+
+// RestoreBefore is a helper method to define mock.On call
+//   - maxLSN int
+//   - at time.Time
+func (_e *MockSnapshotter_Expecter) RestoreBefore(maxLSN interface{}, at interface{}) *MockSnapshotter_RestoreBefore_Call {
+	return &MockSnapshotter_RestoreBefore_Call{Call: _e.mock.On("RestoreBefore", maxLSN, at)}
+}
+
+func (_c *MockSnapshotter_RestoreBefore_Call) Run(run func(maxLSN int, at time.Time)) *MockSnapshotter_RestoreBefore_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(int), args[1].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockSnapshotter_RestoreBefore_Call) Return(_a0 int, _a1 error) *MockSnapshotter_RestoreBefore_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockSnapshotter_RestoreBefore_Call) RunAndReturn(run func(int, time.Time) (int, error)) *MockSnapshotter_RestoreBefore_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockSnapshotter creates a new instance of MockSnapshotter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockSnapshotter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockSnapshotter {
+	mock := &MockSnapshotter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
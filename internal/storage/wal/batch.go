@@ -1,49 +1,84 @@
 package wal
 
-import "github.com/DaniilZ77/InMemDB/internal/compute/parser"
+import (
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
+)
 
 type Command struct {
 	LSN         int
 	CommandType int
 	Args        []string
+	// Timestamp is the time the command was written to the log, it's zero
+	// for commands of the old formats.
+	Timestamp time.Time
+}
+
+// flushResult is shared by the copies of the batch, so every command of the
+// batch can wait for it.
+type flushResult struct {
+	done chan struct{}
+	err  error
 }
 
 type Batch struct {
-	lsn         int
-	batchSize   int
-	commands    []Command
-	doneChannel chan bool
+	batchSize int
+	commands  []Command
+	sync      bool
+	// slots is amount of pending writes the batch holds.
+	slots int
+	// started is when the first command was appended to the batch.
+	started time.Time
+	result  *flushResult
 }
 
 func NewBatch(batchSize int) *Batch {
-	return &Batch{batchSize: batchSize, doneChannel: make(chan bool)}
+	return &Batch{batchSize: batchSize, result: &flushResult{done: make(chan struct{})}}
 }
 
 func (b *Batch) AppendCommand(command *parser.Command) {
+	if len(b.commands) == 0 {
+		b.started = time.Now()
+	}
 	b.commands = append(b.commands, Command{
-		LSN:         b.lsn,
 		CommandType: int(command.Type),
 		Args:        command.Args,
 	})
-	b.lsn++
+	b.sync = b.sync || command.Sync
 }
 
 func (b *Batch) ResetBatch() {
 	b.commands = nil
-	b.doneChannel = make(chan bool)
+	b.sync = false
+	b.slots = 0
+	b.result = &flushResult{done: make(chan struct{})}
 }
 
-func (b *Batch) NotifyFlushed(status bool) {
-	defer close(b.doneChannel)
-	for range b.commands {
-		b.doneChannel <- status
-	}
+func (b *Batch) NotifyFlushed(err error) {
+	b.result.err = err
+	close(b.result.done)
 }
 
 func (b *Batch) IsFull() bool {
 	return len(b.commands) >= b.batchSize
 }
 
-func (b *Batch) WaitFlushed() bool {
-	return <-b.doneChannel
+// WaitFlushed waits until the batch is flushed, timeout is ignored if it
+// isn't positive.
+func (b *Batch) WaitFlushed(timeout time.Duration) error {
+	if timeout <= 0 {
+		<-b.result.done
+		return b.result.err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-b.result.done:
+		return b.result.err
+	case <-timer.C:
+		return ErrTimeout
+	}
 }
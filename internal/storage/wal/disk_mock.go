@@ -2,7 +2,10 @@
 
 package wal
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	disk "github.com/DaniilZ77/InMemDB/internal/storage/disk"
+	mock "github.com/stretchr/testify/mock"
+)
 
 // MockDisk is an autogenerated mock type for the Disk type
 type MockDisk struct {
@@ -17,34 +20,67 @@ func (_m *MockDisk) EXPECT() *MockDisk_Expecter {
 	return &MockDisk_Expecter{mock: &_m.Mock}
 }
 
-// ReadSegments provides a mock function with no fields
-func (_m *MockDisk) ReadSegments() ([]byte, error) {
+// Probe provides a mock function with no fields
+func (_m *MockDisk) Probe() error {
 	ret := _m.Called()
 
 	if len(ret) == 0 {
-		panic("no return value specified for ReadSegments")
+		panic("no return value specified for Probe")
 	}
 
-	var r0 []byte
-	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]byte, error)); ok {
-		return rf()
-	}
-	if rf, ok := ret.Get(0).(func() []byte); ok {
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
 		r0 = rf()
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
-		}
+		r0 = ret.Error(0)
 	}
 
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	return r0
+}
+
+// MockDisk_Probe_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Probe'
+type MockDisk_Probe_Call struct {
+	*mock.Call
+}
+
+// Probe is a helper method to define mock.On call
+func (_e *MockDisk_Expecter) Probe() *MockDisk_Probe_Call {
+	return &MockDisk_Probe_Call{Call: _e.mock.On("Probe")}
+}
+
+func (_c *MockDisk_Probe_Call) Run(run func()) *MockDisk_Probe_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockDisk_Probe_Call) Return(_a0 error) *MockDisk_Probe_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockDisk_Probe_Call) RunAndReturn(run func() error) *MockDisk_Probe_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReadSegments provides a mock function with given fields: fn
+func (_m *MockDisk) ReadSegments(fn func(disk.Record) error) error {
+	ret := _m.Called(fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReadSegments")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(func(disk.Record) error) error); ok {
+		r0 = rf(fn)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
 // MockDisk_ReadSegments_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReadSegments'
@@ -53,38 +89,131 @@ type MockDisk_ReadSegments_Call struct {
 }
 
 // ReadSegments is a helper method to define mock.On call
-func (_e *MockDisk_Expecter) ReadSegments() *MockDisk_ReadSegments_Call {
-	return &MockDisk_ReadSegments_Call{Call: _e.mock.On("ReadSegments")}
+//   - fn func(disk.Record) error
+func (_e *MockDisk_Expecter) ReadSegments(fn interface{}) *MockDisk_ReadSegments_Call {
+	return &MockDisk_ReadSegments_Call{Call: _e.mock.On("ReadSegments", fn)}
 }
 
-func (_c *MockDisk_ReadSegments_Call) Run(run func()) *MockDisk_ReadSegments_Call {
+func (_c *MockDisk_ReadSegments_Call) Run(run func(fn func(disk.Record) error)) *MockDisk_ReadSegments_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(func(disk.Record) error))
+	})
+	return _c
+}
+
+func (_c *MockDisk_ReadSegments_Call) Return(_a0 error) *MockDisk_ReadSegments_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockDisk_ReadSegments_Call) RunAndReturn(run func(func(disk.Record) error) error) *MockDisk_ReadSegments_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReadSegmentsFrom provides a mock function with given fields: lsn, fn
+func (_m *MockDisk) ReadSegmentsFrom(lsn uint64, fn func(disk.Record) error) error {
+	ret := _m.Called(lsn, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReadSegmentsFrom")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uint64, func(disk.Record) error) error); ok {
+		r0 = rf(lsn, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockDisk_ReadSegmentsFrom_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReadSegmentsFrom'
+type MockDisk_ReadSegmentsFrom_Call struct {
+	*mock.Call
+}
+
+// ReadSegmentsFrom is a helper method to define mock.On call
+//   - lsn uint64
+//   - fn func(disk.Record) error
+func (_e *MockDisk_Expecter) ReadSegmentsFrom(lsn interface{}, fn interface{}) *MockDisk_ReadSegmentsFrom_Call {
+	return &MockDisk_ReadSegmentsFrom_Call{Call: _e.mock.On("ReadSegmentsFrom", lsn, fn)}
+}
+
+func (_c *MockDisk_ReadSegmentsFrom_Call) Run(run func(lsn uint64, fn func(disk.Record) error)) *MockDisk_ReadSegmentsFrom_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uint64), args[1].(func(disk.Record) error))
+	})
+	return _c
+}
+
+func (_c *MockDisk_ReadSegmentsFrom_Call) Return(_a0 error) *MockDisk_ReadSegmentsFrom_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockDisk_ReadSegmentsFrom_Call) RunAndReturn(run func(uint64, func(disk.Record) error) error) *MockDisk_ReadSegmentsFrom_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Sync provides a mock function with no fields
+func (_m *MockDisk) Sync() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Sync")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockDisk_Sync_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Sync'
+type MockDisk_Sync_Call struct {
+	*mock.Call
+}
+
+// Sync is a helper method to define mock.On call
+func (_e *MockDisk_Expecter) Sync() *MockDisk_Sync_Call {
+	return &MockDisk_Sync_Call{Call: _e.mock.On("Sync")}
+}
+
+func (_c *MockDisk_Sync_Call) Run(run func()) *MockDisk_Sync_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		run()
 	})
 	return _c
 }
 
-func (_c *MockDisk_ReadSegments_Call) Return(_a0 []byte, _a1 error) *MockDisk_ReadSegments_Call {
-	_c.Call.Return(_a0, _a1)
+func (_c *MockDisk_Sync_Call) Return(_a0 error) *MockDisk_Sync_Call {
+	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *MockDisk_ReadSegments_Call) RunAndReturn(run func() ([]byte, error)) *MockDisk_ReadSegments_Call {
+func (_c *MockDisk_Sync_Call) RunAndReturn(run func() error) *MockDisk_Sync_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// WriteSegment provides a mock function with given fields: _a0
-func (_m *MockDisk) WriteSegment(_a0 []byte) error {
-	ret := _m.Called(_a0)
+// WriteSegment provides a mock function with given fields: lsn, data
+func (_m *MockDisk) WriteSegment(lsn uint64, data []byte) error {
+	ret := _m.Called(lsn, data)
 
 	if len(ret) == 0 {
 		panic("no return value specified for WriteSegment")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func([]byte) error); ok {
-		r0 = rf(_a0)
+	if rf, ok := ret.Get(0).(func(uint64, []byte) error); ok {
+		r0 = rf(lsn, data)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -98,14 +227,15 @@ type MockDisk_WriteSegment_Call struct {
 }
 
 // WriteSegment is a helper method to define mock.On call
-//   - _a0 []byte
-func (_e *MockDisk_Expecter) WriteSegment(_a0 interface{}) *MockDisk_WriteSegment_Call {
-	return &MockDisk_WriteSegment_Call{Call: _e.mock.On("WriteSegment", _a0)}
+//   - lsn uint64
+//   - data []byte
+func (_e *MockDisk_Expecter) WriteSegment(lsn interface{}, data interface{}) *MockDisk_WriteSegment_Call {
+	return &MockDisk_WriteSegment_Call{Call: _e.mock.On("WriteSegment", lsn, data)}
 }
 
-func (_c *MockDisk_WriteSegment_Call) Run(run func(_a0 []byte)) *MockDisk_WriteSegment_Call {
+func (_c *MockDisk_WriteSegment_Call) Run(run func(lsn uint64, data []byte)) *MockDisk_WriteSegment_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].([]byte))
+		run(args[0].(uint64), args[1].([]byte))
 	})
 	return _c
 }
@@ -115,7 +245,7 @@ func (_c *MockDisk_WriteSegment_Call) Return(_a0 error) *MockDisk_WriteSegment_C
 	return _c
 }
 
-func (_c *MockDisk_WriteSegment_Call) RunAndReturn(run func([]byte) error) *MockDisk_WriteSegment_Call {
+func (_c *MockDisk_WriteSegment_Call) RunAndReturn(run func(uint64, []byte) error) *MockDisk_WriteSegment_Call {
 	_c.Call.Return(run)
 	return _c
 }
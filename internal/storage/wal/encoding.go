@@ -0,0 +1,181 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/common"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+)
+
+// Payload of the record is a batch of commands:
+//
+//	time     varint   unix time in nanoseconds the batch was written at,
+//	                  absent in disk.FormatBinary records
+//	count    uvarint  amount of commands
+//	commands:
+//	  type   uvarint
+//	  args   uvarint  amount of args
+//	  arg    uvarint length followed by the bytes of the arg
+//
+// Commands of the batch have sequential LSNs starting from the LSN of the
+// record, so they aren't stored.
+
+var errMalformedPayload = errors.New("malformed payload")
+
+// EncodePayload encodes commands as payload of the record, the batch is
+// stamped with the time of the first command if it's set, e.g. for commands
+// received from master, and with the current time otherwise.
+func EncodePayload(commands []Command) ([]byte, error) {
+	timestamp := time.Now()
+	if len(commands) > 0 && !commands[0].Timestamp.IsZero() {
+		timestamp = commands[0].Timestamp
+	}
+
+	return encodeBatch(commands, timestamp)
+}
+
+func encodeBatch(commands []Command, timestamp time.Time) ([]byte, error) {
+	size := 2 * binary.MaxVarintLen64
+	for _, command := range commands {
+		size += 2 * binary.MaxVarintLen64
+		for _, arg := range command.Args {
+			size += binary.MaxVarintLen64 + len(arg)
+		}
+	}
+
+	payload := make([]byte, 0, size)
+	payload = binary.AppendVarint(payload, timestamp.UnixNano())
+	return encodeCommands(payload, commands)
+}
+
+func encodeCommands(payload []byte, commands []Command) ([]byte, error) {
+	payload = binary.AppendUvarint(payload, uint64(len(commands)))
+	for i, command := range commands {
+		if command.LSN != commands[0].LSN+i {
+			return nil, fmt.Errorf("batch lsn %d is not sequential", command.LSN)
+		}
+
+		payload = binary.AppendUvarint(payload, uint64(command.CommandType))
+		payload = binary.AppendUvarint(payload, uint64(len(command.Args)))
+		for _, arg := range command.Args {
+			payload = binary.AppendUvarint(payload, uint64(len(arg)))
+			payload = append(payload, arg...)
+		}
+	}
+
+	return payload, nil
+}
+
+func decodeCommands(lsn uint64, payload []byte) ([]Command, error) {
+	reader := payloadReader{data: payload}
+
+	count := reader.uvarint()
+	if reader.err != nil || count > uint64(len(payload)) {
+		return nil, errMalformedPayload
+	}
+
+	commands := make([]Command, 0, count)
+	for i := range count {
+		command := Command{
+			LSN:         int(lsn + i),
+			CommandType: int(reader.uvarint()),
+		}
+
+		argsCount := reader.uvarint()
+		if reader.err != nil || argsCount > uint64(len(payload)) {
+			return nil, errMalformedPayload
+		}
+		command.Args = make([]string, 0, argsCount)
+		for range argsCount {
+			command.Args = append(command.Args, reader.string())
+		}
+
+		if reader.err != nil {
+			return nil, reader.err
+		}
+		commands = append(commands, command)
+	}
+
+	if len(reader.data) > 0 {
+		return nil, errMalformedPayload
+	}
+
+	return commands, nil
+}
+
+func decodeBatch(lsn uint64, payload []byte) ([]Command, error) {
+	timestamp, n := binary.Varint(payload)
+	if n <= 0 {
+		return nil, errMalformedPayload
+	}
+
+	commands, err := decodeCommands(lsn, payload[n:])
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range commands {
+		commands[i].Timestamp = time.Unix(0, timestamp)
+	}
+
+	return commands, nil
+}
+
+type payloadReader struct {
+	data []byte
+	err  error
+}
+
+func (r *payloadReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	value, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errMalformedPayload
+		return 0
+	}
+
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *payloadReader) string() string {
+	length := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+	if length > uint64(len(r.data)) {
+		r.err = errMalformedPayload
+		return ""
+	}
+
+	value := string(r.data[:length])
+	r.data = r.data[length:]
+	return value
+}
+
+// DecodeRecord decodes commands of the record written by any format version.
+func DecodeRecord(record disk.Record) ([]Command, error) {
+	var commands []Command
+	var err error
+	switch record.Version {
+	case disk.FormatLegacy:
+		commands, err = common.DecodeMany[[]Command](record.Payload)
+	case disk.FormatBinary:
+		commands, err = decodeCommands(record.LSN, record.Payload)
+	case disk.FormatTimestamps, disk.FormatCompression:
+		commands, err = decodeBatch(record.LSN, record.Payload)
+	default:
+		err = fmt.Errorf("%w %d", disk.ErrUnsupportedVersion, record.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("record with lsn %d: %w", record.LSN, err)
+	}
+
+	return commands, nil
+}
@@ -0,0 +1,70 @@
+package wal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeCommands(t *testing.T) {
+	t.Parallel()
+
+	commands := []Command{
+		{LSN: 10, CommandType: 1, Args: []string{"name", "Daniil"}},
+		{LSN: 11, CommandType: 1, Args: []string{"empty", ""}},
+		{LSN: 12, CommandType: 2, Args: []string{"name"}},
+	}
+
+	payload, err := encodeCommands(nil, commands)
+	require.NoError(t, err)
+
+	decoded, err := decodeCommands(10, payload)
+	require.NoError(t, err)
+	assert.Equal(t, commands, decoded)
+
+	for size := range len(payload) {
+		_, err := decodeCommands(10, payload[:size])
+		assert.ErrorIs(t, err, errMalformedPayload)
+	}
+}
+
+func TestEncodeBatch(t *testing.T) {
+	t.Parallel()
+
+	timestamp := time.Date(2024, 5, 1, 10, 42, 0, 0, time.UTC)
+	commands := []Command{
+		{LSN: 3, CommandType: 1, Args: []string{"name", "Daniil"}},
+		{LSN: 4, CommandType: 2, Args: []string{"name"}},
+	}
+
+	payload, err := encodeBatch(commands, timestamp)
+	require.NoError(t, err)
+
+	decoded, err := DecodeRecord(disk.Record{LSN: 3, Payload: payload, Version: disk.FormatTimestamps})
+	require.NoError(t, err)
+	require.Len(t, decoded, len(commands))
+	for i := range decoded {
+		assert.True(t, timestamp.Equal(decoded[i].Timestamp))
+		assert.Equal(t, commands[i].Args, decoded[i].Args)
+	}
+
+	binaryPayload, err := encodeCommands(nil, commands)
+	require.NoError(t, err)
+	decoded, err = DecodeRecord(disk.Record{LSN: 3, Payload: binaryPayload, Version: disk.FormatBinary})
+	require.NoError(t, err)
+	assert.Equal(t, commands, decoded)
+}
+
+func TestEncodeCommands_NotSequential(t *testing.T) {
+	t.Parallel()
+
+	_, err := encodeCommands(nil, []Command{
+		{LSN: 1, CommandType: 2, Args: []string{"name"}},
+		{LSN: 3, CommandType: 2, Args: []string{"name"}},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,39 @@
+package wal
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+var (
+	// ErrBusy is returned if pending writes queue is full, the command isn't
+	// logged, so it can be retried.
+	ErrBusy = errors.New("server busy")
+	// ErrTimeout is returned if the command isn't written in time, it may
+	// still be logged later.
+	ErrTimeout = errors.New("wal write timeout")
+	ErrClosed  = errors.New("wal is closed")
+	// ErrReadOnly is returned if WAL was switched to read-only mode after
+	// persistent disk failures, see Wal.SetReadWrite.
+	ErrReadOnly    = errors.New("read-only mode")
+	ErrDiskFull    = errors.New("disk is full")
+	ErrWriteFailed = errors.New("wal write failed")
+	ErrFsyncFailed = errors.New("wal fsync failed")
+)
+
+func writeError(err error) error {
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) {
+		return fmt.Errorf("%w: %w", ErrDiskFull, err)
+	}
+
+	return fmt.Errorf("%w: %w", ErrWriteFailed, err)
+}
+
+func fsyncError(err error) error {
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) {
+		return fmt.Errorf("%w: %w", ErrDiskFull, err)
+	}
+
+	return fmt.Errorf("%w: %w", ErrFsyncFailed, err)
+}
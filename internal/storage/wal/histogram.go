@@ -0,0 +1,55 @@
+package wal
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// HistogramBucket counts observations greater than the bound of the previous
+// bucket and not greater than UpperBound, the last bucket is unbounded.
+type HistogramBucket struct {
+	UpperBound int64
+	Count      int64
+}
+
+// Histogram counts observations in buckets with the fixed bounds, it's safe
+// for concurrent use.
+type Histogram struct {
+	bounds []int64
+	counts []atomic.Int64
+}
+
+func NewHistogram(bounds ...int64) *Histogram {
+	return &Histogram{
+		bounds: append(bounds, math.MaxInt64),
+		counts: make([]atomic.Int64, len(bounds)+1),
+	}
+}
+
+func (h *Histogram) Observe(value int64) {
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i].Add(1)
+			return
+		}
+	}
+}
+
+// Buckets returns counts of the buckets in order of their bounds.
+func (h *Histogram) Buckets() []HistogramBucket {
+	buckets := make([]HistogramBucket, len(h.bounds))
+	for i, bound := range h.bounds {
+		buckets[i] = HistogramBucket{UpperBound: bound, Count: h.counts[i].Load()}
+	}
+
+	return buckets
+}
+
+// BatchStats describes flushed batches: their sizes, how long their first
+// command waited for the flush in microseconds, and smoothed latency of
+// fsync.
+type BatchStats struct {
+	Sizes        []HistogramBucket
+	Waits        []HistogramBucket
+	FsyncLatency int64
+}
@@ -1,17 +1,18 @@
 package wal
 
 import (
-	"bytes"
-	"encoding/gob"
 	"log/slog"
 
-	"github.com/DaniilZ77/InMemDB/internal/common"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
 )
 
 //go:generate mockery --name=Disk --case=snake --inpackage --inpackage-suffix --with-expecter
 type Disk interface {
-	WriteSegment([]byte) error
-	ReadSegments() ([]byte, error)
+	WriteSegment(lsn uint64, data []byte) error
+	ReadSegments(fn func(disk.Record) error) error
+	ReadSegmentsFrom(lsn uint64, fn func(disk.Record) error) error
+	Sync() error
+	Probe() error
 }
 
 type LogsManager struct {
@@ -26,14 +27,19 @@ func NewLogsManager(disk Disk, log *slog.Logger) *LogsManager {
 	}
 }
 
+// WriteLogs writes commands as a batch, see EncodePayload.
 func (w *LogsManager) WriteLogs(commands []Command) error {
-	buffer := new(bytes.Buffer)
-	encoder := gob.NewEncoder(buffer)
-	if err := encoder.Encode(commands); err != nil {
+	payload, err := EncodePayload(commands)
+	if err != nil {
 		return err
 	}
 
-	if err := w.disk.WriteSegment(buffer.Bytes()); err != nil {
+	var lsn uint64
+	if len(commands) > 0 {
+		lsn = uint64(commands[0].LSN)
+	}
+
+	if err := w.disk.WriteSegment(lsn, payload); err != nil {
 		w.log.Error("failed to write data on disk", slog.Any("error", err))
 		return err
 	}
@@ -41,11 +47,63 @@ func (w *LogsManager) WriteLogs(commands []Command) error {
 	return nil
 }
 
-func (w *LogsManager) ReadLogs() ([]Command, error) {
-	segments, err := w.disk.ReadSegments()
+func (w *LogsManager) Sync() error {
+	if err := w.disk.Sync(); err != nil {
+		w.log.Error("failed to sync data on disk", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// Probe checks that logs can be written.
+func (w *LogsManager) Probe() error {
+	return w.disk.Probe()
+}
+
+// ReadLogs calls fn for commands of every logged batch in order.
+func (w *LogsManager) ReadLogs(fn func([]Command) error) error {
+	return w.disk.ReadSegments(func(record disk.Record) error {
+		commands, err := DecodeRecord(record)
+		if err != nil {
+			return err
+		}
+
+		return fn(commands)
+	})
+}
+
+// ReadLogsFrom calls fn for commands of every logged batch starting from the
+// one holding the lsn, it may be called while logs are written. Commands of
+// the first batches may precede the lsn.
+func (w *LogsManager) ReadLogsFrom(lsn int, fn func([]Command) error) error {
+	return w.disk.ReadSegmentsFrom(uint64(lsn), func(record disk.Record) error {
+		commands, err := DecodeRecord(record)
+		if err != nil {
+			return err
+		}
+
+		return fn(commands)
+	})
+}
+
+// DecodeSegment decodes commands of the segment as it's stored on disk, keys
+// may be nil if the segment isn't encrypted.
+func DecodeSegment(data []byte, keys *disk.Keyring) ([]Command, error) {
+	records, _, err := disk.DecodeSegment(data, keys)
 	if err != nil {
 		return nil, err
 	}
 
-	return common.DecodeMany[[]Command](segments)
+	var commands []Command
+	for _, record := range records {
+		decoded, err := DecodeRecord(record)
+		if err != nil {
+			return nil, err
+		}
+
+		commands = append(commands, decoded...)
+	}
+
+	return commands, nil
 }
@@ -4,8 +4,12 @@ import (
 	"errors"
 	"io"
 	"log/slog"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/DaniilZ77/InMemDB/internal/common"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -23,7 +27,8 @@ func TestLogsManager_Success(t *testing.T) {
 	}
 
 	var encodedCommands []byte
-	mockDisk.EXPECT().WriteSegment(mock.MatchedBy(func(data []byte) bool {
+	written := time.Now()
+	mockDisk.EXPECT().WriteSegment(uint64(1), mock.MatchedBy(func(data []byte) bool {
 		encodedCommands = data
 		return true
 	})).Return(nil).Once()
@@ -31,13 +36,54 @@ func TestLogsManager_Success(t *testing.T) {
 	err := logsManager.WriteLogs(commands)
 	require.NoError(t, err)
 
-	mockDisk.EXPECT().ReadSegments().Return(encodedCommands, nil).Once()
+	mockDisk.EXPECT().ReadSegments(mock.Anything).RunAndReturn(func(fn func(disk.Record) error) error {
+		return fn(disk.Record{LSN: 1, Payload: encodedCommands, Version: disk.FormatVersion})
+	}).Once()
 
-	decodedCommands, err := logsManager.ReadLogs()
+	var decodedCommands []Command
+	err = logsManager.ReadLogs(func(commands []Command) error {
+		decodedCommands = append(decodedCommands, commands...)
+		return nil
+	})
 	require.NoError(t, err)
+	for i := range decodedCommands {
+		assert.False(t, decodedCommands[i].Timestamp.Before(written))
+		decodedCommands[i].Timestamp = time.Time{}
+	}
 	assert.Equal(t, commands, decodedCommands)
 }
 
+func TestLogsManager_ReadLogsFrom(t *testing.T) {
+	t.Parallel()
+
+	mockDisk := NewMockDisk(t)
+	logsManager := NewLogsManager(mockDisk, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	timestamp := time.Unix(1735787045, 0)
+	commands := []Command{
+		{LSN: 5, CommandType: 1, Args: []string{"name", "Daniil"}, Timestamp: timestamp},
+		{LSN: 6, CommandType: 2, Args: []string{"name"}, Timestamp: timestamp},
+	}
+
+	var encodedCommands []byte
+	mockDisk.EXPECT().WriteSegment(uint64(5), mock.MatchedBy(func(data []byte) bool {
+		encodedCommands = data
+		return true
+	})).Return(nil).Once()
+	require.NoError(t, logsManager.WriteLogs(commands))
+
+	mockDisk.EXPECT().ReadSegmentsFrom(uint64(6), mock.Anything).RunAndReturn(func(_ uint64, fn func(disk.Record) error) error {
+		return fn(disk.Record{LSN: 5, Payload: encodedCommands, Version: disk.FormatVersion})
+	}).Once()
+
+	var decodedCommands []Command
+	err := logsManager.ReadLogsFrom(6, func(commands []Command) error {
+		decodedCommands = append(decodedCommands, commands...)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, commands, decodedCommands, "batch keeps timestamp of the commands")
+}
+
 func TestLogsManager_Error(t *testing.T) {
 	mockDisk := NewMockDisk(t)
 	logsManager := NewLogsManager(mockDisk, slog.New(slog.NewJSONHandler(io.Discard, nil)))
@@ -51,11 +97,10 @@ func TestLogsManager_Error(t *testing.T) {
 		{
 			name: "read error",
 			call: func() error {
-				_, err := logsManager.ReadLogs()
-				return err
+				return logsManager.ReadLogs(func([]Command) error { return nil })
 			},
 			mock: func() {
-				mockDisk.EXPECT().ReadSegments().Return(nil, expectedErr).Once()
+				mockDisk.EXPECT().ReadSegments(mock.Anything).Return(expectedErr).Once()
 			},
 		},
 		{
@@ -64,7 +109,7 @@ func TestLogsManager_Error(t *testing.T) {
 				return logsManager.WriteLogs([]Command{})
 			},
 			mock: func() {
-				mockDisk.EXPECT().WriteSegment(mock.Anything).Return(expectedErr).Once()
+				mockDisk.EXPECT().WriteSegment(mock.Anything, mock.Anything).Return(expectedErr).Once()
 			},
 		},
 	}
@@ -79,3 +124,48 @@ func TestLogsManager_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeSegment(t *testing.T) {
+	t.Parallel()
+
+	batch1 := []Command{{LSN: 1, CommandType: 1, Args: []string{"name", "Daniil"}}}
+	batch2 := []Command{{LSN: 2, CommandType: 2, Args: []string{"name"}}}
+	expected := append(batch1, batch2...)
+
+	gob1, err := common.Encode(batch1)
+	require.NoError(t, err)
+	gob2, err := common.Encode(batch2)
+	require.NoError(t, err)
+	payload1, err := encodeCommands(nil, batch1)
+	require.NoError(t, err)
+	payload2, err := encodeCommands(nil, batch2)
+	require.NoError(t, err)
+
+	segment := disk.EncodeSegmentHeader(disk.SegmentHeader{Version: disk.FormatBinary, FirstLSN: 1})
+	segment = append(segment, disk.EncodeRecord(1, payload1)...)
+	segment = append(segment, disk.EncodeRecord(2, payload2)...)
+
+	tests := []struct {
+		name    string
+		segment []byte
+	}{
+		{name: "segment", segment: segment},
+		{name: "legacy framed segment", segment: append(disk.EncodeRecord(1, gob1), disk.EncodeRecord(2, gob2)...)},
+		{name: "legacy segment", segment: append(gob1, gob2...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, err := DecodeSegment(tt.segment, nil)
+			require.NoError(t, err)
+			assert.Equal(t, expected, commands)
+		})
+	}
+
+	t.Run("corrupted segment", func(t *testing.T) {
+		corrupted := slices.Clone(segment)
+		corrupted[len(corrupted)-1] ^= 0xff
+		_, err := DecodeSegment(corrupted, nil)
+		assert.ErrorIs(t, err, disk.ErrCorruptedRecord)
+	})
+}
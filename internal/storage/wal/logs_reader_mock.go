@@ -17,34 +17,22 @@ func (_m *MockLogsReader) EXPECT() *MockLogsReader_Expecter {
 	return &MockLogsReader_Expecter{mock: &_m.Mock}
 }
 
-// ReadLogs provides a mock function with no fields
-func (_m *MockLogsReader) ReadLogs() ([]Command, error) {
-	ret := _m.Called()
+// ReadLogs provides a mock function with given fields: fn
+func (_m *MockLogsReader) ReadLogs(fn func([]Command) error) error {
+	ret := _m.Called(fn)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ReadLogs")
 	}
 
-	var r0 []Command
-	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]Command, error)); ok {
-		return rf()
-	}
-	if rf, ok := ret.Get(0).(func() []Command); ok {
-		r0 = rf()
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]Command)
-		}
-	}
-
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	var r0 error
+	if rf, ok := ret.Get(0).(func(func([]Command) error) error); ok {
+		r0 = rf(fn)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
 // MockLogsReader_ReadLogs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReadLogs'
@@ -53,23 +41,24 @@ type MockLogsReader_ReadLogs_Call struct {
 }
 
 // ReadLogs is a helper method to define mock.On call
-func (_e *MockLogsReader_Expecter) ReadLogs() *MockLogsReader_ReadLogs_Call {
-	return &MockLogsReader_ReadLogs_Call{Call: _e.mock.On("ReadLogs")}
+//   - fn func([]Command) error
+func (_e *MockLogsReader_Expecter) ReadLogs(fn interface{}) *MockLogsReader_ReadLogs_Call {
+	return &MockLogsReader_ReadLogs_Call{Call: _e.mock.On("ReadLogs", fn)}
 }
 
-func (_c *MockLogsReader_ReadLogs_Call) Run(run func()) *MockLogsReader_ReadLogs_Call {
+func (_c *MockLogsReader_ReadLogs_Call) Run(run func(fn func([]Command) error)) *MockLogsReader_ReadLogs_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run()
+		run(args[0].(func([]Command) error))
 	})
 	return _c
 }
 
-func (_c *MockLogsReader_ReadLogs_Call) Return(_a0 []Command, _a1 error) *MockLogsReader_ReadLogs_Call {
-	_c.Call.Return(_a0, _a1)
+func (_c *MockLogsReader_ReadLogs_Call) Return(_a0 error) *MockLogsReader_ReadLogs_Call {
+	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *MockLogsReader_ReadLogs_Call) RunAndReturn(run func() ([]Command, error)) *MockLogsReader_ReadLogs_Call {
+func (_c *MockLogsReader_ReadLogs_Call) RunAndReturn(run func(func([]Command) error) error) *MockLogsReader_ReadLogs_Call {
 	_c.Call.Return(run)
 	return _c
 }
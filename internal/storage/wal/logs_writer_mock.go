@@ -17,6 +17,96 @@ func (_m *MockLogsWriter) EXPECT() *MockLogsWriter_Expecter {
 	return &MockLogsWriter_Expecter{mock: &_m.Mock}
 }
 
+// Probe provides a mock function with no fields
+func (_m *MockLogsWriter) Probe() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Probe")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockLogsWriter_Probe_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Probe'
+type MockLogsWriter_Probe_Call struct {
+	*mock.Call
+}
+
+// Probe is a helper method to define mock.On call
+func (_e *MockLogsWriter_Expecter) Probe() *MockLogsWriter_Probe_Call {
+	return &MockLogsWriter_Probe_Call{Call: _e.mock.On("Probe")}
+}
+
+func (_c *MockLogsWriter_Probe_Call) Run(run func()) *MockLogsWriter_Probe_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockLogsWriter_Probe_Call) Return(_a0 error) *MockLogsWriter_Probe_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockLogsWriter_Probe_Call) RunAndReturn(run func() error) *MockLogsWriter_Probe_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Sync provides a mock function with no fields
+func (_m *MockLogsWriter) Sync() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Sync")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockLogsWriter_Sync_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Sync'
+type MockLogsWriter_Sync_Call struct {
+	*mock.Call
+}
+
+// Sync is a helper method to define mock.On call
+func (_e *MockLogsWriter_Expecter) Sync() *MockLogsWriter_Sync_Call {
+	return &MockLogsWriter_Sync_Call{Call: _e.mock.On("Sync")}
+}
+
+func (_c *MockLogsWriter_Sync_Call) Run(run func()) *MockLogsWriter_Sync_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockLogsWriter_Sync_Call) Return(_a0 error) *MockLogsWriter_Sync_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockLogsWriter_Sync_Call) RunAndReturn(run func() error) *MockLogsWriter_Sync_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // WriteLogs provides a mock function with given fields: _a0
 func (_m *MockLogsWriter) WriteLogs(_a0 []Command) error {
 	ret := _m.Called(_a0)
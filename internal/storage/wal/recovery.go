@@ -0,0 +1,96 @@
+package wal
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var ErrTargetBeforeSnapshot = errors.New("recovery target precedes the snapshot")
+
+var errTargetReached = errors.New("recovery target reached")
+
+// RecoveryTarget limits recovery to a point in the past, zero value recovers
+// the whole log. Recovery stops at the first command with lsn StopLSN or
+// written after StopTime.
+type RecoveryTarget struct {
+	StopLSN  int
+	StopTime time.Time
+}
+
+func (t RecoveryTarget) IsZero() bool {
+	return t.StopLSN == 0 && t.StopTime.IsZero()
+}
+
+// reached reports whether the command is past the target. Commands of the old
+// formats have no timestamp, they are recovered.
+func (t RecoveryTarget) reached(command Command) bool {
+	if t.StopLSN > 0 && command.LSN >= t.StopLSN {
+		return true
+	}
+
+	return !t.StopTime.IsZero() && command.Timestamp.After(t.StopTime)
+}
+
+// RecoverTo calls apply for logged commands starting from the lsn till the
+// target, commands before the lsn are already restored from the snapshot.
+func (w *Wal) RecoverTo(lsn int, target RecoveryTarget, apply func([]Command)) error {
+	if target.StopLSN > 0 && target.StopLSN < lsn {
+		return fmt.Errorf("%w: snapshot lsn %d", ErrTargetBeforeSnapshot, lsn)
+	}
+
+	started := time.Now()
+	next := lsn
+	recovered, skipped := 0, 0
+
+	err := w.logsReader.ReadLogs(func(commands []Command) error {
+		if len(commands) == 0 {
+			return nil
+		}
+
+		first := 0
+		for first < len(commands) && commands[first].LSN < lsn {
+			if !target.StopTime.IsZero() && commands[first].Timestamp.After(target.StopTime) {
+				return fmt.Errorf("%w: lsn %d is written after %s",
+					ErrTargetBeforeSnapshot, commands[first].LSN, target.StopTime.Format(time.RFC3339))
+			}
+			first++
+		}
+		skipped += first
+
+		last := first
+		for last < len(commands) && !target.reached(commands[last]) {
+			last++
+		}
+
+		if first < last {
+			apply(commands[first:last])
+			recovered += last - first
+		}
+		if last < len(commands) {
+			next = max(next, commands[last].LSN)
+			return errTargetReached
+		}
+
+		next = max(next, commands[len(commands)-1].LSN+1)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errTargetReached) {
+		return err
+	}
+	if err == nil && !target.IsZero() {
+		w.log.Warn("recovery target is not reached, the whole log is recovered", slog.Int("lsn", next))
+	}
+
+	w.log.Info("recovered database",
+		slog.Int("commands", recovered),
+		slog.Int("skipped", skipped),
+		slog.Int("lsn", next),
+		slog.Duration("elapsed", time.Since(started)),
+	)
+
+	w.lsn.Store(int64(next))
+
+	return nil
+}
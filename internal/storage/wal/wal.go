@@ -3,39 +3,116 @@ package wal
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
 )
 
 const (
-	statusSuccess = true
-	statusError   = false
+	defaultFsyncInterval    = time.Second
+	defaultMaxPendingWrites = 10000
+	defaultBusyTimeout      = 100 * time.Millisecond
+	defaultFailureThreshold = 3
 )
 
+// FsyncPolicy defines when written batches are synced to disk and when Save
+// acknowledges the command:
+//   - always: every command is written and synced on its own before ack;
+//   - group: commands are grouped into batches, a batch is synced once before
+//     ack of its commands;
+//   - interval: commands are acked before they are written, files are synced
+//     every fsync interval;
+//   - none: commands are acked before they are written, syncing is left to OS.
+//
+// Command with Sync set waits until it is synced whatever the policy is.
+type FsyncPolicy string
+
+const (
+	FsyncAlways   FsyncPolicy = "always"
+	FsyncGroup    FsyncPolicy = "group"
+	FsyncInterval FsyncPolicy = "interval"
+	FsyncNone     FsyncPolicy = "none"
+)
+
+func (p FsyncPolicy) IsValid() bool {
+	switch p {
+	case FsyncAlways, FsyncGroup, FsyncInterval, FsyncNone:
+		return true
+	}
+	return false
+}
+
+// waits reports whether commands are acked only after they are synced.
+func (p FsyncPolicy) waits() bool {
+	return p == FsyncAlways || p == FsyncGroup
+}
+
 //go:generate mockery --name=LogsReader --case=snake --inpackage --inpackage-suffix --with-expecter
 type LogsReader interface {
-	ReadLogs() ([]Command, error)
+	ReadLogs(fn func([]Command) error) error
 }
 
 //go:generate mockery --name=LogsWriter --case=snake --inpackage --inpackage-suffix --with-expecter
 type LogsWriter interface {
 	WriteLogs([]Command) error
+	Sync() error
+	Probe() error
 }
 
 type Wal struct {
 	logsReader LogsReader
 	logsWriter LogsWriter
 
-	batchChannel chan Batch
+	// flushes signals the flusher that full batches are queued.
+	flushes      chan struct{}
 	batchTimeout time.Duration
 
+	// adaptive batching flushes commands as soon as WAL is idle, under
+	// contention batch is given time to grow depending on fsync latency.
+	adaptive      bool
+	notify        chan struct{}
+	lastBatchSize int
+	fsyncLatency  atomic.Int64
+	batchSizes    *Histogram
+	batchWaits    *Histogram
+
+	fsyncPolicy   FsyncPolicy
+	fsyncInterval time.Duration
+	dirty         bool
+
+	commitHook func([]Command)
+
+	// pending holds a slot for every saved command till its batch is
+	// flushed, it's nil if amount of pending writes isn't limited.
+	pending          chan struct{}
+	maxPendingWrites int
+	busyTimeout      time.Duration
+	writeTimeout     time.Duration
+	done             chan struct{}
+
+	// WAL is switched to read-only mode after failureThreshold batches in a
+	// row fail to be written, readOnly is the last failure then.
+	failureThreshold int
+	failures         atomic.Int32
+	modeMu           sync.Mutex
+	readOnly         error
+
 	log *slog.Logger
 
-	mu    sync.Mutex
-	batch *Batch
+	// lsn is the LSN the next written command gets, LSNs are assigned when
+	// a batch is written, so a failed write doesn't leave a gap.
+	lsn atomic.Int64
+
+	// mu guards the batch being filled and the full batches queued for the
+	// flusher in order they were filled.
+	mu     sync.Mutex
+	batch  *Batch
+	full   []Batch
+	closed bool
 }
 
 func NewWal(
@@ -43,7 +120,8 @@ func NewWal(
 	batchTimeout time.Duration,
 	logsReader LogsReader,
 	logsWriter LogsWriter,
-	log *slog.Logger) (*Wal, error) {
+	log *slog.Logger,
+	opts ...WalOption) (*Wal, error) {
 	if logsReader == nil {
 		return nil, errors.New("logs reader is nil")
 	}
@@ -54,34 +132,177 @@ func NewWal(
 		return nil, errors.New("logger is nil")
 	}
 
-	return &Wal{
-		logsReader:   logsReader,
-		logsWriter:   logsWriter,
-		batchChannel: make(chan Batch),
-		batchTimeout: batchTimeout,
-		log:          log,
-		batch:        NewBatch(batchSize),
-	}, nil
+	wal := &Wal{
+		logsReader:       logsReader,
+		logsWriter:       logsWriter,
+		flushes:          make(chan struct{}, 1),
+		batchTimeout:     batchTimeout,
+		fsyncPolicy:      FsyncGroup,
+		fsyncInterval:    defaultFsyncInterval,
+		maxPendingWrites: defaultMaxPendingWrites,
+		busyTimeout:      defaultBusyTimeout,
+		failureThreshold: defaultFailureThreshold,
+		done:             make(chan struct{}),
+		log:              log,
+		batch:            NewBatch(batchSize),
+		batchSizes:       NewHistogram(1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024),
+		batchWaits:       NewHistogram(10, 50, 100, 500, 1000, 5000, 10000, 50000, 100000),
+	}
+
+	for _, opt := range opts {
+		opt(wal)
+	}
+
+	if !wal.fsyncPolicy.IsValid() {
+		return nil, fmt.Errorf("invalid fsync policy %q", wal.fsyncPolicy)
+	}
+	if wal.maxPendingWrites > 0 {
+		wal.pending = make(chan struct{}, wal.maxPendingWrites)
+	}
+	if wal.adaptive {
+		wal.notify = make(chan struct{}, 1)
+	}
+
+	return wal, nil
+}
+
+// Save logs the command. If there are too many pending writes it waits for
+// the busy timeout and returns ErrBusy, errors of writing to disk are
+// returned as ErrDiskFull, ErrWriteFailed or ErrFsyncFailed.
+func (w *Wal) Save(command *parser.Command) error {
+	return w.SaveBatch([]*parser.Command{command})
 }
 
-func (w *Wal) Save(command *parser.Command) bool {
+// SaveBatch logs the commands in order as a part of the same batch, so they
+// are written by a single append and either all of them are logged or none.
+// Errors are the same as of Save.
+func (w *Wal) SaveBatch(commands []*parser.Command) error {
+	if len(commands) == 0 {
+		return nil
+	}
+	if err := w.ReadOnly(); err != nil {
+		return err
+	}
+	slots, err := w.acquire(len(commands))
+	if err != nil {
+		return err
+	}
+
+	wait := w.fsyncPolicy.waits()
+	for _, command := range commands {
+		wait = wait || command.Sync
+	}
+
 	w.mu.Lock()
-	w.batch.AppendCommand(command)
+	if w.closed {
+		w.mu.Unlock()
+		w.release(slots)
+		return ErrClosed
+	}
+
+	for _, command := range commands {
+		w.batch.AppendCommand(command)
+	}
+	w.batch.slots += slots
 	batch := *w.batch
-	if w.batch.IsFull() {
+	full := w.batch.IsFull() || w.fsyncPolicy == FsyncAlways
+	if full {
+		w.full = append(w.full, batch)
 		w.batch.ResetBatch()
-		w.batchChannel <- batch
 	}
 	w.mu.Unlock()
 
-	return batch.WaitFlushed()
+	if full {
+		signal(w.flushes)
+	} else if w.adaptive {
+		signal(w.notify)
+	}
+
+	if !wait {
+		return nil
+	}
+
+	return batch.WaitFlushed(w.writeTimeout)
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// acquire takes a slot of pending writes for every command and returns how
+// many slots are taken, a batch larger than the limit takes all of them.
+func (w *Wal) acquire(commands int) (int, error) {
+	if w.pending == nil {
+		return 0, nil
+	}
+
+	slots := min(commands, w.maxPendingWrites)
+	var timeout <-chan time.Time
+	for i := range slots {
+		select {
+		case w.pending <- struct{}{}:
+			continue
+		default:
+		}
+
+		if w.busyTimeout <= 0 {
+			w.release(i)
+			return 0, ErrBusy
+		}
+		if timeout == nil {
+			timeout = time.After(w.busyTimeout)
+		}
+
+		select {
+		case w.pending <- struct{}{}:
+		case <-timeout:
+			w.release(i)
+			return 0, ErrBusy
+		case <-w.done:
+			w.release(i)
+			return 0, ErrClosed
+		}
+	}
+
+	return slots, nil
+}
+
+func (w *Wal) release(n int) {
+	if w.pending == nil {
+		return
+	}
+
+	for range n {
+		<-w.pending
+	}
+}
+
+// PendingWrites returns amount of saved commands which aren't flushed yet.
+func (w *Wal) PendingWrites() int {
+	return len(w.pending)
 }
 
 func (w *Wal) Start(ctx context.Context) {
 	ticker := time.NewTicker(w.batchTimeout)
 
+	var syncTicks <-chan time.Time
+	if w.fsyncPolicy == FsyncInterval {
+		syncTicker := time.NewTicker(w.fsyncInterval)
+		defer syncTicker.Stop()
+		syncTicks = syncTicker.C
+	}
+
+	// Flush delayed by adaptive batching, see groupCommitDelay.
+	delayTimer := time.NewTimer(w.batchTimeout)
+	delayTimer.Stop()
+	var delayed bool
+
 	defer func() {
 		ticker.Stop()
+		delayTimer.Stop()
 		if v := recover(); v != nil {
 			w.log.Error("panic recovered", slog.Any("error", v))
 			go w.Start(ctx)
@@ -102,29 +323,92 @@ func (w *Wal) Start(ctx context.Context) {
 			w.flushAll()
 			return
 		case <-ticker.C:
-			w.mu.Lock()
-			batch := *w.batch
-			w.batch.ResetBatch()
-			w.mu.Unlock()
-			w.flushBatch(batch)
-		case batch := <-w.batchChannel:
+			w.flushCurrent()
+		case <-w.notify:
+			if delayed {
+				continue
+			}
+			if delay := w.groupCommitDelay(); delay > 0 {
+				delayTimer.Reset(delay)
+				delayed = true
+				continue
+			}
+			w.flushCurrent()
+		case <-delayTimer.C:
+			delayed = false
+			w.flushCurrent()
+		case <-w.flushes:
 			ticker.Reset(w.batchTimeout)
-			w.flushBatch(batch)
+			w.flushBatches(w.takeBatches(false))
+		case <-syncTicks:
+			if err := w.sync(); err != nil {
+				w.log.Error("failed to sync wal", slog.Any("error", err))
+			}
 		}
 	}
 }
 
+// flushCurrent flushes queued batches and the batch being filled.
+func (w *Wal) flushCurrent() {
+	w.flushBatches(w.takeBatches(true))
+}
+
+// takeBatches takes queued full batches, followed by the batch being filled
+// if current is set.
+func (w *Wal) takeBatches(current bool) []Batch {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	batches := w.full
+	w.full = nil
+	if current && len(w.batch.commands) > 0 {
+		batches = append(batches, *w.batch)
+		w.batch.ResetBatch()
+	}
+
+	return batches
+}
+
+func (w *Wal) flushBatches(batches []Batch) {
+	for _, batch := range batches {
+		w.flushBatch(batch)
+	}
+}
+
+// groupCommitDelay returns how long the batch may wait for more commands.
+// Idle WAL flushes a command at once, if the previous batch had more than one
+// command, commands arrive faster than they are flushed, so the batch is given
+// up to a half of fsync latency to grow.
+func (w *Wal) groupCommitDelay() time.Duration {
+	if w.lastBatchSize <= 1 {
+		return 0
+	}
+
+	return min(time.Duration(w.fsyncLatency.Load())/2, w.batchTimeout)
+}
+
+// BatchStats returns histograms of flushed batches.
+func (w *Wal) BatchStats() BatchStats {
+	return BatchStats{
+		Sizes:        w.batchSizes.Buckets(),
+		Waits:        w.batchWaits.Buckets(),
+		FsyncLatency: time.Duration(w.fsyncLatency.Load()).Microseconds(),
+	}
+}
+
+// flushAll flushes pending batches and closes WAL, commands saved after it
+// get ErrClosed.
 func (w *Wal) flushAll() {
-	for {
-		select {
-		case batch := <-w.batchChannel:
-			w.flushBatch(batch)
-		default:
-			w.mu.Lock()
-			batch := *w.batch
-			w.mu.Unlock()
-			w.flushBatch(batch)
-			return
+	close(w.done)
+
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+
+	w.flushCurrent()
+	if w.fsyncPolicy != FsyncNone {
+		if err := w.sync(); err != nil {
+			w.log.Error("failed to sync wal", slog.Any("error", err))
 		}
 	}
 }
@@ -134,26 +418,124 @@ func (w *Wal) flushBatch(batch Batch) {
 		return
 	}
 
+	defer w.release(batch.slots)
+
+	w.lastBatchSize = len(batch.commands)
+	w.batchSizes.Observe(int64(len(batch.commands)))
+	w.batchWaits.Observe(time.Since(batch.started).Microseconds())
+
+	// Commands carry commit time, so replicas log them with the same time
+	// and measure their lag by it.
+	committed := time.Now()
+	lsn := int(w.lsn.Load())
+	for i := range batch.commands {
+		batch.commands[i].LSN = lsn + i
+		batch.commands[i].Timestamp = committed
+	}
+
 	err := w.logsWriter.WriteLogs(batch.commands)
 	if err != nil {
 		w.log.Error("failed to flush batch", slog.Any("error", err))
-		batch.NotifyFlushed(statusError)
+		w.fail(writeError(err))
+		batch.NotifyFlushed(writeError(err))
 		return
 	}
 
-	batch.NotifyFlushed(statusSuccess)
+	w.lsn.Add(int64(len(batch.commands)))
+	w.dirty = true
+	if batch.sync || w.fsyncPolicy.waits() {
+		if err := w.sync(); err != nil {
+			w.log.Error("failed to sync batch", slog.Any("error", err))
+			w.fail(fsyncError(err))
+			batch.NotifyFlushed(fsyncError(err))
+			return
+		}
+	}
+
+	w.failures.Store(0)
+	if w.commitHook != nil {
+		w.commitHook(batch.commands)
+	}
+	batch.NotifyFlushed(nil)
 }
 
-func (w *Wal) Recover() ([]Command, error) {
-	commands, err := w.logsReader.ReadLogs()
-	if err != nil {
-		return nil, err
+func (w *Wal) sync() error {
+	if !w.dirty {
+		return nil
+	}
+
+	started := time.Now()
+	if err := w.logsWriter.Sync(); err != nil {
+		return err
+	}
+	w.observeFsync(time.Since(started))
+
+	w.dirty = false
+	return nil
+}
+
+// observeFsync updates moving average of fsync latency.
+func (w *Wal) observeFsync(latency time.Duration) {
+	average := w.fsyncLatency.Load()
+	if average == 0 {
+		w.fsyncLatency.Store(int64(latency))
+		return
+	}
+	w.fsyncLatency.Store((7*average + int64(latency)) / 8)
+}
+
+// fail switches WAL to read-only mode if the failure is persistent.
+func (w *Wal) fail(err error) {
+	if w.failureThreshold <= 0 || int(w.failures.Add(1)) < w.failureThreshold {
+		return
+	}
+
+	w.modeMu.Lock()
+	defer w.modeMu.Unlock()
+	if w.readOnly == nil {
+		w.log.Error("switched to read-only mode", slog.Int("failures", w.failureThreshold), slog.Any("error", err))
+	}
+	w.readOnly = err
+}
+
+// ReadOnly returns ErrReadOnly with the failure WAL was switched to read-only
+// mode by, it's nil in read-write mode.
+func (w *Wal) ReadOnly() error {
+	w.modeMu.Lock()
+	defer w.modeMu.Unlock()
+
+	if w.readOnly == nil {
+		return nil
 	}
+	return fmt.Errorf("%w: %w", ErrReadOnly, w.readOnly)
+}
 
-	w.log.Info("recovered database", slog.Any("commands", len(commands)))
-	if len(commands) > 0 {
-		w.batch.lsn = commands[len(commands)-1].LSN + 1
+// SetReadWrite switches WAL back to read-write mode if the disk is writable.
+func (w *Wal) SetReadWrite() error {
+	w.modeMu.Lock()
+	defer w.modeMu.Unlock()
+
+	if w.readOnly == nil {
+		return nil
+	}
+	if err := w.logsWriter.Probe(); err != nil {
+		return writeError(err)
 	}
 
-	return commands, nil
+	w.failures.Store(0)
+	w.readOnly = nil
+	w.log.Info("switched to read-write mode")
+	return nil
+}
+
+// LSN returns the LSN the next written command gets, commands saved but not
+// written yet get it or the following ones.
+func (w *Wal) LSN() int {
+	return int(w.lsn.Load())
+}
+
+// Recover passes logged commands starting from the lsn to apply batch by
+// batch, commands before the lsn are restored from snapshot.
+func (w *Wal) Recover(lsn int, apply func([]Command)) error {
+	return w.RecoverTo(lsn, RecoveryTarget{}, apply)
 }
@@ -0,0 +1,66 @@
+package wal
+
+import "time"
+
+type WalOption func(*Wal)
+
+func WithFsyncPolicy(policy FsyncPolicy) WalOption {
+	return func(w *Wal) {
+		w.fsyncPolicy = policy
+	}
+}
+
+func WithFsyncInterval(interval time.Duration) WalOption {
+	return func(w *Wal) {
+		w.fsyncInterval = interval
+	}
+}
+
+// WithMaxPendingWrites limits amount of saved commands waiting to be flushed,
+// zero or negative disables the limit.
+func WithMaxPendingWrites(maxPendingWrites int) WalOption {
+	return func(w *Wal) {
+		w.maxPendingWrites = maxPendingWrites
+	}
+}
+
+// WithBusyTimeout sets how long Save waits for a pending writes slot before
+// returning ErrBusy.
+func WithBusyTimeout(timeout time.Duration) WalOption {
+	return func(w *Wal) {
+		w.busyTimeout = timeout
+	}
+}
+
+// WithWriteTimeout sets how long Save waits for the command to be flushed
+// before returning ErrTimeout, zero means no limit.
+func WithWriteTimeout(timeout time.Duration) WalOption {
+	return func(w *Wal) {
+		w.writeTimeout = timeout
+	}
+}
+
+// WithFailureThreshold sets amount of failed writes in a row WAL is switched
+// to read-only mode after, zero or negative disables read-only mode.
+func WithFailureThreshold(threshold int) WalOption {
+	return func(w *Wal) {
+		w.failureThreshold = threshold
+	}
+}
+
+// WithAdaptiveBatching makes WAL flush commands as soon as it's idle instead
+// of waiting for the batch timeout, batches grow under contention.
+func WithAdaptiveBatching(enabled bool) WalOption {
+	return func(w *Wal) {
+		w.adaptive = enabled
+	}
+}
+
+// WithCommitHook sets function called with commands of every batch once it's
+// written and synced if commands wait for sync. It's called by the flushing
+// goroutine, so it must not block.
+func WithCommitHook(hook func([]Command)) WalOption {
+	return func(w *Wal) {
+		w.commitHook = hook
+	}
+}
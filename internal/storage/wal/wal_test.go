@@ -4,24 +4,32 @@ import (
 	"context"
 	"errors"
 	"io"
+	"io/fs"
 	"log/slog"
-	"slices"
+	"math"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/DaniilZ77/InMemDB/internal/compute/parser"
+	"github.com/DaniilZ77/InMemDB/internal/storage/disk"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
-func newTestWal(t *testing.T, ctx context.Context, batchSize int, batchTimeout time.Duration) (*Wal, *MockLogsReader, *MockLogsWriter) {
+func newTestWal(
+	t *testing.T,
+	ctx context.Context,
+	batchSize int,
+	batchTimeout time.Duration,
+	opts ...WalOption) (*Wal, *MockLogsReader, *MockLogsWriter) {
 	logsReader := NewMockLogsReader(t)
 	logsWriter := NewMockLogsWriter(t)
 
-	wal, err := NewWal(batchSize, batchTimeout, logsReader, logsWriter, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	wal, err := NewWal(batchSize, batchTimeout, logsReader, logsWriter, slog.New(slog.NewJSONHandler(io.Discard, nil)), opts...)
 	require.NoError(t, err)
 
 	go wal.Start(ctx)
@@ -48,6 +56,7 @@ func TestSave_Timeout(t *testing.T) {
 		commandsCount.Add(int32(len(commands)))
 		return true
 	})).Return(nil)
+	logsWriter.EXPECT().Sync().Return(nil)
 
 	wg := sync.WaitGroup{}
 	wg.Add(len(parserCommands))
@@ -56,7 +65,7 @@ func TestSave_Timeout(t *testing.T) {
 		go func() {
 			defer wg.Done()
 			res := wal.Save(&parserCommands[i])
-			assert.True(t, res)
+			assert.NoError(t, res)
 		}()
 	}
 
@@ -81,6 +90,7 @@ func TestSave_BatchOverflow(t *testing.T) {
 	logsWriter.EXPECT().WriteLogs(mock.MatchedBy(func(commands []Command) bool {
 		return len(commands) == batchSize
 	})).Return(nil).Once()
+	logsWriter.EXPECT().Sync().Return(nil).Once()
 
 	wg := sync.WaitGroup{}
 	wg.Add(batchSize)
@@ -88,7 +98,7 @@ func TestSave_BatchOverflow(t *testing.T) {
 		go func() {
 			defer wg.Done()
 			res := wal.Save(&parserCommands[i])
-			assert.True(t, res)
+			assert.NoError(t, res)
 		}()
 	}
 
@@ -108,7 +118,7 @@ func TestSave_Error(t *testing.T) {
 		Type: parser.SET,
 		Args: []string{"name", "Daniil"},
 	})
-	assert.False(t, res)
+	assert.ErrorIs(t, res, ErrWriteFailed)
 }
 
 func TestSave_ContextCancel(t *testing.T) {
@@ -123,6 +133,7 @@ func TestSave_ContextCancel(t *testing.T) {
 	logsWriter.EXPECT().WriteLogs(mock.MatchedBy(func(commands []Command) bool {
 		return len(commands) == 1
 	})).Return(nil).Once()
+	logsWriter.EXPECT().Sync().Return(nil).Once()
 
 	wg := sync.WaitGroup{}
 	wg.Add(1)
@@ -130,7 +141,7 @@ func TestSave_ContextCancel(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		res := wal.Save(&parserCommands[0])
-		assert.True(t, res)
+		assert.NoError(t, res)
 	}()
 	time.Sleep(100 * time.Millisecond)
 
@@ -138,6 +149,360 @@ func TestSave_ContextCancel(t *testing.T) {
 	wg.Wait()
 }
 
+func TestSave_SyncError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wal, _, logsWriter := newTestWal(t, ctx, 1, time.Hour)
+	logsWriter.EXPECT().WriteLogs(mock.Anything).Return(nil).Once()
+	logsWriter.EXPECT().Sync().Return(errors.New("sync error")).Once()
+	logsWriter.EXPECT().Sync().Return(nil).Maybe()
+
+	res := wal.Save(&parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}})
+	assert.ErrorIs(t, res, ErrFsyncFailed)
+}
+
+func TestSave_FsyncAlways(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wal, _, logsWriter := newTestWal(t, ctx, 10, time.Hour, WithFsyncPolicy(FsyncAlways))
+	logsWriter.EXPECT().WriteLogs(mock.MatchedBy(func(commands []Command) bool {
+		return len(commands) == 1
+	})).Return(nil).Twice()
+	logsWriter.EXPECT().Sync().Return(nil).Twice()
+
+	assert.NoError(t, wal.Save(&parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}}))
+	assert.NoError(t, wal.Save(&parser.Command{Type: parser.DEL, Args: []string{"name"}}))
+}
+
+func TestSave_CommitHook(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var committed [][]Command
+	hook := func(commands []Command) {
+		committed = append(committed, commands)
+	}
+	wal, _, logsWriter := newTestWal(t, ctx, 10, time.Hour, WithFsyncPolicy(FsyncAlways), WithCommitHook(hook))
+	logsWriter.EXPECT().WriteLogs(mock.Anything).Return(nil).Once()
+	logsWriter.EXPECT().WriteLogs(mock.Anything).Return(errors.New("disk failure")).Once()
+	logsWriter.EXPECT().Sync().Return(nil).Once()
+
+	require.NoError(t, wal.Save(&parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}}))
+	require.Error(t, wal.Save(&parser.Command{Type: parser.DEL, Args: []string{"name"}}))
+
+	require.Len(t, committed, 1, "failed batch isn't passed to hook")
+	require.Len(t, committed[0], 1)
+	assert.Equal(t, []string{"name", "Daniil"}, committed[0][0].Args)
+	assert.False(t, committed[0][0].Timestamp.IsZero(), "commands carry commit time")
+}
+
+func TestSave_FsyncNone(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wal, _, logsWriter := newTestWal(t, ctx, 10, 100*time.Millisecond, WithFsyncPolicy(FsyncNone))
+
+	written := make(chan struct{})
+	logsWriter.EXPECT().WriteLogs(mock.Anything).RunAndReturn(func([]Command) error {
+		close(written)
+		return nil
+	}).Once()
+
+	assert.NoError(t, wal.Save(&parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}}))
+	select {
+	case <-written:
+		t.Fatal("command is acknowledged after it is written")
+	default:
+	}
+
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("command is not written")
+	}
+}
+
+func TestSave_FsyncInterval(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wal, _, logsWriter := newTestWal(t, ctx, 1, time.Hour,
+		WithFsyncPolicy(FsyncInterval),
+		WithFsyncInterval(50*time.Millisecond),
+	)
+
+	synced := make(chan struct{})
+	logsWriter.EXPECT().WriteLogs(mock.Anything).Return(nil).Once()
+	logsWriter.EXPECT().Sync().RunAndReturn(func() error {
+		close(synced)
+		return nil
+	}).Once()
+
+	assert.NoError(t, wal.Save(&parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}}))
+
+	select {
+	case <-synced:
+	case <-time.After(time.Second):
+		t.Fatal("wal is not synced")
+	}
+}
+
+func TestSave_SyncCommand(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wal, _, logsWriter := newTestWal(t, ctx, 10, 100*time.Millisecond, WithFsyncPolicy(FsyncNone))
+
+	var synced atomic.Bool
+	logsWriter.EXPECT().WriteLogs(mock.MatchedBy(func(commands []Command) bool {
+		return len(commands) == 2
+	})).Return(nil).Once()
+	logsWriter.EXPECT().Sync().RunAndReturn(func() error {
+		synced.Store(true)
+		return nil
+	}).Once()
+
+	assert.NoError(t, wal.Save(&parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}}))
+	assert.NoError(t, wal.Save(&parser.Command{Type: parser.DEL, Args: []string{"name"}, Sync: true}))
+	assert.True(t, synced.Load())
+}
+
+func TestSave_DiskFull(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wal, _, logsWriter := newTestWal(t, ctx, 1, time.Hour)
+	logsWriter.EXPECT().WriteLogs(mock.Anything).Return(&fs.PathError{Op: "write", Path: "wal.log", Err: syscall.ENOSPC}).Once()
+
+	err := wal.Save(&parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}})
+	assert.ErrorIs(t, err, ErrDiskFull)
+	assert.ErrorIs(t, err, syscall.ENOSPC)
+}
+
+func TestSave_Busy(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wal, _, logsWriter := newTestWal(t, ctx, 1, time.Hour,
+		WithMaxPendingWrites(1),
+		WithBusyTimeout(50*time.Millisecond),
+	)
+
+	// Disk stalls on the first write.
+	stalled, resume := make(chan struct{}), make(chan struct{})
+	logsWriter.EXPECT().WriteLogs(mock.Anything).RunAndReturn(func([]Command) error {
+		close(stalled)
+		<-resume
+		return nil
+	}).Once()
+	logsWriter.EXPECT().WriteLogs(mock.Anything).Return(nil).Once()
+	logsWriter.EXPECT().Sync().Return(nil)
+
+	saved := make(chan error)
+	go func() {
+		saved <- wal.Save(&parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}})
+	}()
+	<-stalled
+
+	assert.Equal(t, 1, wal.PendingWrites())
+	assert.ErrorIs(t, wal.Save(&parser.Command{Type: parser.DEL, Args: []string{"name"}}), ErrBusy)
+
+	close(resume)
+	assert.NoError(t, <-saved)
+	assert.Zero(t, wal.PendingWrites())
+	assert.NoError(t, wal.Save(&parser.Command{Type: parser.DEL, Args: []string{"name"}}))
+}
+
+func TestSave_WriteTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wal, _, logsWriter := newTestWal(t, ctx, 1, time.Hour, WithWriteTimeout(50*time.Millisecond))
+
+	resume := make(chan struct{})
+	logsWriter.EXPECT().WriteLogs(mock.Anything).RunAndReturn(func([]Command) error {
+		<-resume
+		return nil
+	}).Once()
+	logsWriter.EXPECT().Sync().Return(nil).Maybe()
+	t.Cleanup(func() { close(resume) })
+
+	err := wal.Save(&parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}})
+	assert.ErrorIs(t, err, ErrTimeout)
+}
+
+func TestSave_Closed(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wal, _, _ := newTestWal(t, ctx, 10, time.Hour)
+
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	err := wal.Save(&parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}})
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestSave_ReadOnly(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wal, _, logsWriter := newTestWal(t, ctx, 1, time.Hour, WithFailureThreshold(2))
+	logsWriter.EXPECT().WriteLogs(mock.Anything).Return(syscall.ENOSPC).Twice()
+
+	command := &parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}}
+	assert.ErrorIs(t, wal.Save(command), ErrDiskFull)
+	assert.NoError(t, wal.ReadOnly())
+	assert.ErrorIs(t, wal.Save(command), ErrDiskFull)
+
+	// Disk isn't touched in read-only mode.
+	err := wal.Save(command)
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.ErrorIs(t, err, ErrDiskFull)
+	assert.ErrorIs(t, wal.ReadOnly(), ErrReadOnly)
+
+	logsWriter.EXPECT().Probe().Return(syscall.ENOSPC).Once()
+	assert.ErrorIs(t, wal.SetReadWrite(), ErrDiskFull)
+	assert.ErrorIs(t, wal.Save(command), ErrReadOnly)
+
+	logsWriter.EXPECT().Probe().Return(nil).Once()
+	logsWriter.EXPECT().WriteLogs(mock.Anything).Return(nil).Once()
+	logsWriter.EXPECT().Sync().Return(nil).Once()
+	require.NoError(t, wal.SetReadWrite())
+	assert.NoError(t, wal.ReadOnly())
+	assert.NoError(t, wal.Save(command))
+}
+
+func TestSave_FailuresReset(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wal, _, logsWriter := newTestWal(t, ctx, 1, time.Hour, WithFailureThreshold(2))
+	logsWriter.EXPECT().WriteLogs(mock.Anything).Return(errors.New("write error")).Once()
+	logsWriter.EXPECT().WriteLogs(mock.Anything).Return(nil).Once()
+	logsWriter.EXPECT().WriteLogs(mock.Anything).Return(errors.New("write error")).Once()
+	logsWriter.EXPECT().Sync().Return(nil).Once()
+
+	command := &parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}}
+	assert.ErrorIs(t, wal.Save(command), ErrWriteFailed)
+	assert.NoError(t, wal.Save(command))
+	assert.ErrorIs(t, wal.Save(command), ErrWriteFailed)
+	assert.NoError(t, wal.ReadOnly())
+}
+
+func TestNewWal_InvalidFsyncPolicy(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewWal(10, time.Second, NewMockLogsReader(t), NewMockLogsWriter(t),
+		slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithFsyncPolicy("sometimes"),
+	)
+	assert.Error(t, err)
+}
+
+func TestSave_AdaptiveBatching(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wal, _, logsWriter := newTestWal(t, ctx, 10, time.Hour, WithAdaptiveBatching(true))
+	logsWriter.EXPECT().WriteLogs(mock.MatchedBy(func(commands []Command) bool {
+		return len(commands) == 1
+	})).Return(nil).Twice()
+	logsWriter.EXPECT().Sync().Return(nil).Twice()
+
+	// Idle WAL doesn't wait for the batch timeout.
+	assert.NoError(t, wal.Save(&parser.Command{Type: parser.SET, Args: []string{"name", "Daniil"}}))
+	assert.NoError(t, wal.Save(&parser.Command{Type: parser.DEL, Args: []string{"name"}}))
+
+	stats := wal.BatchStats()
+	assert.Equal(t, HistogramBucket{UpperBound: 1, Count: 2}, stats.Sizes[0])
+	var waits int64
+	for _, bucket := range stats.Waits {
+		waits += bucket.Count
+	}
+	assert.Equal(t, int64(2), waits)
+}
+
+func TestGroupCommitDelay(t *testing.T) {
+	t.Parallel()
+
+	wal, err := NewWal(10, 10*time.Millisecond, NewMockLogsReader(t), NewMockLogsWriter(t),
+		slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithAdaptiveBatching(true),
+	)
+	require.NoError(t, err)
+
+	wal.observeFsync(4 * time.Millisecond)
+	wal.lastBatchSize = 1
+	assert.Zero(t, wal.groupCommitDelay(), "idle")
+
+	wal.lastBatchSize = 5
+	assert.Equal(t, 2*time.Millisecond, wal.groupCommitDelay())
+
+	wal.observeFsync(164 * time.Millisecond)
+	assert.Equal(t, 24*time.Millisecond, time.Duration(wal.fsyncLatency.Load()))
+	assert.Equal(t, 10*time.Millisecond, wal.groupCommitDelay(), "limited by batch timeout")
+}
+
+func TestHistogram(t *testing.T) {
+	t.Parallel()
+
+	histogram := NewHistogram(1, 10)
+	for _, value := range []int64{0, 1, 5, 10, 11, 1000} {
+		histogram.Observe(value)
+	}
+
+	assert.Equal(t, []HistogramBucket{
+		{UpperBound: 1, Count: 2},
+		{UpperBound: 10, Count: 2},
+		{UpperBound: math.MaxInt64, Count: 2},
+	}, histogram.Buckets())
+}
+
+func expectReadLogs(logsReader *MockLogsReader, batches ...[]Command) {
+	logsReader.EXPECT().ReadLogs(mock.Anything).RunAndReturn(func(fn func([]Command) error) error {
+		for _, batch := range batches {
+			if err := fn(batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Once()
+}
+
+func collectCommands(res *[]Command) func([]Command) {
+	return func(commands []Command) {
+		*res = append(*res, commands...)
+	}
+}
+
 func TestRecover_Success(t *testing.T) {
 	t.Parallel()
 
@@ -145,24 +510,18 @@ func TestRecover_Success(t *testing.T) {
 	t.Cleanup(cancel)
 	wal, logsReader, _ := newTestWal(t, ctx, 10, 500*time.Millisecond)
 	commands := []Command{
-		{LSN: 3, CommandType: 0, Args: []string{"name"}},
-		{LSN: 2, CommandType: 1, Args: []string{"name", "Daniil"}},
 		{LSN: 1, CommandType: 2, Args: []string{"name"}},
+		{LSN: 2, CommandType: 1, Args: []string{"name", "Daniil"}},
+		{LSN: 3, CommandType: 0, Args: []string{"name"}},
 	}
-	logsReader.EXPECT().ReadLogs().Return(commands, nil).Once()
-	slices.SortFunc(commands, func(command1 Command, command2 Command) int {
-		return command1.LSN - command2.LSN
-	})
+	expectReadLogs(logsReader, commands[:2], commands[2:])
 
-	res, err := wal.Recover()
+	var res []Command
+	err := wal.Recover(0, collectCommands(&res))
 	require.NoError(t, err)
 
-	assert.Len(t, res, len(commands))
-	for i := range res {
-		assert.Equal(t, commands[i].Args, res[i].Args)
-		assert.Equal(t, commands[i].CommandType, res[i].CommandType)
-	}
-	assert.Equal(t, wal.batch.lsn, commands[len(commands)-1].LSN+1)
+	assert.Equal(t, commands, res)
+	assert.Equal(t, commands[len(commands)-1].LSN+1, wal.LSN())
 }
 
 func TestRecover_Error(t *testing.T) {
@@ -171,8 +530,168 @@ func TestRecover_Error(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	t.Cleanup(cancel)
 	wal, logsReader, _ := newTestWal(t, ctx, 10, 500*time.Millisecond)
-	logsReader.EXPECT().ReadLogs().Return(nil, errors.New("recover error")).Once()
+	logsReader.EXPECT().ReadLogs(mock.Anything).Return(errors.New("recover error")).Once()
 
-	_, err := wal.Recover()
+	err := wal.Recover(0, func([]Command) {})
 	assert.Error(t, err)
 }
+
+func TestRecover_FromSnapshot(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	wal, logsReader, _ := newTestWal(t, ctx, 10, 500*time.Millisecond)
+	commands := []Command{
+		{LSN: 1, CommandType: 1, Args: []string{"name", "Daniil"}},
+		{LSN: 2, CommandType: 2, Args: []string{"name"}},
+		{LSN: 3, CommandType: 1, Args: []string{"name", "Ivan"}},
+	}
+
+	expectReadLogs(logsReader, commands)
+	var res []Command
+	err := wal.Recover(2, collectCommands(&res))
+	require.NoError(t, err)
+	assert.Equal(t, commands[1:], res)
+	assert.Equal(t, 4, wal.LSN())
+
+	expectReadLogs(logsReader)
+	res = nil
+	err = wal.Recover(10, collectCommands(&res))
+	require.NoError(t, err)
+	assert.Empty(t, res)
+	assert.Equal(t, 10, wal.LSN())
+}
+
+func TestRecoverTo(t *testing.T) {
+	t.Parallel()
+
+	at := func(minute int) time.Time {
+		return time.Date(2024, 5, 1, 10, minute, 0, 0, time.UTC)
+	}
+	batches := [][]Command{
+		{
+			{LSN: 1, CommandType: 1, Args: []string{"name", "Daniil"}, Timestamp: at(40)},
+			{LSN: 2, CommandType: 1, Args: []string{"age", "21"}, Timestamp: at(40)},
+		},
+		{
+			{LSN: 3, CommandType: 1, Args: []string{"name", "garbage"}, Timestamp: at(43)},
+			{LSN: 4, CommandType: 2, Args: []string{"age"}, Timestamp: at(43)},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		lsn      int
+		target   RecoveryTarget
+		expected int
+		next     int
+		err      error
+	}{
+		{name: "stop lsn", target: RecoveryTarget{StopLSN: 2}, expected: 1, next: 2},
+		{name: "stop lsn inside batch", target: RecoveryTarget{StopLSN: 4}, expected: 3, next: 4},
+		{name: "stop time", target: RecoveryTarget{StopTime: at(42)}, expected: 2, next: 3},
+		{name: "not reached", target: RecoveryTarget{StopTime: at(50)}, expected: 4, next: 5},
+		{name: "after snapshot", lsn: 2, target: RecoveryTarget{StopTime: at(42)}, expected: 1, next: 3},
+		{name: "lsn before snapshot", lsn: 3, target: RecoveryTarget{StopLSN: 2}, err: ErrTargetBeforeSnapshot},
+		{name: "time before snapshot", lsn: 4, target: RecoveryTarget{StopTime: at(41)}, err: ErrTargetBeforeSnapshot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			wal, logsReader, _ := newTestWal(t, ctx, 10, 500*time.Millisecond)
+			if tt.target.StopLSN == 0 || tt.target.StopLSN >= tt.lsn {
+				expectReadLogs(logsReader, batches...)
+			}
+
+			var res []Command
+			err := wal.RecoverTo(tt.lsn, tt.target, collectCommands(&res))
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, res, tt.expected)
+			assert.Equal(t, tt.next, wal.LSN())
+		})
+	}
+}
+
+// failingLogsWriter fails the writes while fail is set.
+type failingLogsWriter struct {
+	*LogsManager
+	fail atomic.Bool
+}
+
+func (w *failingLogsWriter) WriteLogs(commands []Command) error {
+	if w.fail.Load() {
+		return errors.New("disk failure")
+	}
+	return w.LogsManager.WriteLogs(commands)
+}
+
+func TestSave_FailedWriteRecovery(t *testing.T) {
+	t.Parallel()
+
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	logsManager := NewLogsManager(disk.NewDisk(t.TempDir(), 1<<20, log), log)
+	logsWriter := &failingLogsWriter{LogsManager: logsManager}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	wal, err := NewWal(10, time.Hour, logsManager, logsWriter, log, WithFsyncPolicy(FsyncAlways))
+	require.NoError(t, err)
+	go wal.Start(ctx)
+
+	require.NoError(t, wal.Save(&parser.Command{Type: parser.SET, Args: []string{"a", "1"}}))
+	logsWriter.fail.Store(true)
+	require.ErrorIs(t, wal.Save(&parser.Command{Type: parser.SET, Args: []string{"b", "2"}}), ErrWriteFailed)
+	logsWriter.fail.Store(false)
+	require.NoError(t, wal.Save(&parser.Command{Type: parser.SET, Args: []string{"c", "3"}}))
+	assert.Equal(t, 2, wal.LSN(), "failed write doesn't take LSNs")
+
+	recovered, err := NewWal(10, time.Hour, logsManager, logsManager, log)
+	require.NoError(t, err)
+
+	var res []Command
+	require.NoError(t, recovered.Recover(0, collectCommands(&res)))
+	require.Len(t, res, 2)
+	assert.Equal(t, 0, res[0].LSN)
+	assert.Equal(t, []string{"a", "1"}, res[0].Args)
+	assert.Equal(t, 1, res[1].LSN)
+	assert.Equal(t, []string{"c", "3"}, res[1].Args)
+	assert.Equal(t, 2, recovered.LSN())
+}
+
+func TestSaveBatch(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	wal, _, logsWriter := newTestWal(t, ctx, 2, time.Hour, WithMaxPendingWrites(2))
+	var written []Command
+	logsWriter.EXPECT().WriteLogs(mock.Anything).RunAndReturn(func(commands []Command) error {
+		written = commands
+		return nil
+	}).Once()
+	logsWriter.EXPECT().Sync().Return(nil).Once()
+
+	err := wal.SaveBatch([]*parser.Command{
+		{Type: parser.SET, Args: []string{"a", "1"}},
+		{Type: parser.SET, Args: []string{"b", "2"}},
+		{Type: parser.DEL, Args: []string{"a"}},
+	})
+	require.NoError(t, err)
+	assert.Zero(t, wal.PendingWrites(), "batch larger than the limit of pending writes releases them")
+
+	require.Len(t, written, 3, "commands are written by a single append")
+	for i, args := range [][]string{{"a", "1"}, {"b", "2"}, {"a"}} {
+		assert.Equal(t, i, written[i].LSN)
+		assert.Equal(t, args, written[i].Args)
+	}
+}
@@ -21,76 +21,157 @@ func (_m *MockWal) EXPECT() *MockWal_Expecter {
 	return &MockWal_Expecter{mock: &_m.Mock}
 }
 
-// Recover provides a mock function with no fields
-func (_m *MockWal) Recover() ([]wal.Command, error) {
+// LSN provides a mock function with no fields
+func (_m *MockWal) LSN() int {
 	ret := _m.Called()
 
 	if len(ret) == 0 {
-		panic("no return value specified for Recover")
+		panic("no return value specified for LSN")
 	}
 
-	var r0 []wal.Command
-	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]wal.Command, error)); ok {
-		return rf()
-	}
-	if rf, ok := ret.Get(0).(func() []wal.Command); ok {
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
 		r0 = rf()
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]wal.Command)
-		}
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
+// MockWal_LSN_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'LSN'
+type MockWal_LSN_Call struct {
+	*mock.Call
+}
+
+// LSN is a helper method to define mock.On call
+func (_e *MockWal_Expecter) LSN() *MockWal_LSN_Call {
+	return &MockWal_LSN_Call{Call: _e.mock.On("LSN")}
+}
+
+func (_c *MockWal_LSN_Call) Run(run func()) *MockWal_LSN_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockWal_LSN_Call) Return(_a0 int) *MockWal_LSN_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockWal_LSN_Call) RunAndReturn(run func() int) *MockWal_LSN_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReadOnly provides a mock function with no fields
+func (_m *MockWal) ReadOnly() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReadOnly")
 	}
 
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
-// MockWal_Recover_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Recover'
-type MockWal_Recover_Call struct {
+// MockWal_ReadOnly_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReadOnly'
+type MockWal_ReadOnly_Call struct {
 	*mock.Call
 }
 
-// Recover is a helper method to define mock.On call
-func (_e *MockWal_Expecter) Recover() *MockWal_Recover_Call {
-	return &MockWal_Recover_Call{Call: _e.mock.On("Recover")}
+// ReadOnly is a helper method to define mock.On call
+func (_e *MockWal_Expecter) ReadOnly() *MockWal_ReadOnly_Call {
+	return &MockWal_ReadOnly_Call{Call: _e.mock.On("ReadOnly")}
 }
 
-func (_c *MockWal_Recover_Call) Run(run func()) *MockWal_Recover_Call {
+func (_c *MockWal_ReadOnly_Call) Run(run func()) *MockWal_ReadOnly_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		run()
 	})
 	return _c
 }
 
-func (_c *MockWal_Recover_Call) Return(_a0 []wal.Command, _a1 error) *MockWal_Recover_Call {
-	_c.Call.Return(_a0, _a1)
+func (_c *MockWal_ReadOnly_Call) Return(_a0 error) *MockWal_ReadOnly_Call {
+	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *MockWal_Recover_Call) RunAndReturn(run func() ([]wal.Command, error)) *MockWal_Recover_Call {
+func (_c *MockWal_ReadOnly_Call) RunAndReturn(run func() error) *MockWal_ReadOnly_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RecoverTo provides a mock function with given fields: lsn, target, apply
+func (_m *MockWal) RecoverTo(lsn int, target wal.RecoveryTarget, apply func([]wal.Command)) error {
+	ret := _m.Called(lsn, target, apply)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecoverTo")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, wal.RecoveryTarget, func([]wal.Command)) error); ok {
+		r0 = rf(lsn, target, apply)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockWal_RecoverTo_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RecoverTo'
+type MockWal_RecoverTo_Call struct {
+	*mock.Call
+}
+
+// RecoverTo is a helper method to define mock.On call
+//   - lsn int
+//   - target wal.RecoveryTarget
+//   - apply func([]wal.Command)
+func (_e *MockWal_Expecter) RecoverTo(lsn interface{}, target interface{}, apply interface{}) *MockWal_RecoverTo_Call {
+	return &MockWal_RecoverTo_Call{Call: _e.mock.On("RecoverTo", lsn, target, apply)}
+}
+
+func (_c *MockWal_RecoverTo_Call) Run(run func(lsn int, target wal.RecoveryTarget, apply func([]wal.Command))) *MockWal_RecoverTo_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(int), args[1].(wal.RecoveryTarget), args[2].(func([]wal.Command)))
+	})
+	return _c
+}
+
+func (_c *MockWal_RecoverTo_Call) Return(_a0 error) *MockWal_RecoverTo_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockWal_RecoverTo_Call) RunAndReturn(run func(int, wal.RecoveryTarget, func([]wal.Command)) error) *MockWal_RecoverTo_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
 // Save provides a mock function with given fields: command
-func (_m *MockWal) Save(command *parser.Command) bool {
+func (_m *MockWal) Save(command *parser.Command) error {
 	ret := _m.Called(command)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Save")
 	}
 
-	var r0 bool
-	if rf, ok := ret.Get(0).(func(*parser.Command) bool); ok {
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*parser.Command) error); ok {
 		r0 = rf(command)
 	} else {
-		r0 = ret.Get(0).(bool)
+		r0 = ret.Error(0)
 	}
 
 	return r0
@@ -114,12 +195,58 @@ func (_c *MockWal_Save_Call) Run(run func(command *parser.Command)) *MockWal_Sav
 	return _c
 }
 
-func (_c *MockWal_Save_Call) Return(_a0 bool) *MockWal_Save_Call {
+func (_c *MockWal_Save_Call) Return(_a0 error) *MockWal_Save_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockWal_Save_Call) RunAndReturn(run func(*parser.Command) error) *MockWal_Save_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SaveBatch provides a mock function with given fields: commands
+func (_m *MockWal) SaveBatch(commands []*parser.Command) error {
+	ret := _m.Called(commands)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveBatch")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]*parser.Command) error); ok {
+		r0 = rf(commands)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockWal_SaveBatch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SaveBatch'
+type MockWal_SaveBatch_Call struct {
+	*mock.Call
+}
+
+// SaveBatch is a helper method to define mock.On call
+//   - commands []*parser.Command
+func (_e *MockWal_Expecter) SaveBatch(commands interface{}) *MockWal_SaveBatch_Call {
+	return &MockWal_SaveBatch_Call{Call: _e.mock.On("SaveBatch", commands)}
+}
+
+func (_c *MockWal_SaveBatch_Call) Run(run func(commands []*parser.Command)) *MockWal_SaveBatch_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].([]*parser.Command))
+	})
+	return _c
+}
+
+func (_c *MockWal_SaveBatch_Call) Return(_a0 error) *MockWal_SaveBatch_Call {
 	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *MockWal_Save_Call) RunAndReturn(run func(*parser.Command) bool) *MockWal_Save_Call {
+func (_c *MockWal_SaveBatch_Call) RunAndReturn(run func([]*parser.Command) error) *MockWal_SaveBatch_Call {
 	_c.Call.Return(run)
 	return _c
 }
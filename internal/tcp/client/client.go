@@ -2,6 +2,7 @@ package client
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -11,21 +12,38 @@ import (
 	"github.com/DaniilZ77/InMemDB/internal/common"
 )
 
-const defaultBufferSize = 1024
+const (
+	defaultBufferSize      = 4 << 10
+	defaultMaxMessageSize  = 512 << 20
+	defaultThrottleRetries = 3
+	defaultThrottleBackoff = 50 * time.Millisecond
+)
+
+var (
+	ErrThrottled = errors.New("request throttled by server")
+	ErrBusy      = errors.New("server busy")
+)
 
 type Client struct {
-	connection  net.Conn
-	idleTimeout time.Duration
-	bufferSize  int
+	connection      net.Conn
+	idleTimeout     time.Duration
+	bufferSize      int
+	maxMessageSize  int
+	throttleRetries int
+	throttleBackoff time.Duration
 }
 
 func NewClient(address string, opts ...ClientOption) (*Client, error) {
-	connection, err := net.Dial("tcp", address)
+	connection, err := net.Dial(common.ParseAddress(address))
 	if err != nil {
 		return nil, err
 	}
 
-	client := &Client{connection: connection}
+	client := &Client{
+		connection:      connection,
+		throttleRetries: defaultThrottleRetries,
+		throttleBackoff: defaultThrottleBackoff,
+	}
 	for _, opt := range opts {
 		opt(client)
 	}
@@ -33,33 +51,130 @@ func NewClient(address string, opts ...ClientOption) (*Client, error) {
 	if client.bufferSize == 0 {
 		client.bufferSize = defaultBufferSize
 	}
+	if client.maxMessageSize == 0 {
+		client.maxMessageSize = defaultMaxMessageSize
+	}
 
 	return client, nil
 }
 
+// Send sends request and returns response, throttled requests and writes
+// rejected by busy server are retried with exponential backoff, ErrThrottled
+// or ErrBusy is returned once retries run out.
 func (c *Client) Send(request []byte) ([]byte, error) {
-	if c.idleTimeout != 0 {
-		if err := c.connection.SetWriteDeadline(time.Now().Add(c.idleTimeout)); err != nil {
+	backoff := c.throttleBackoff
+	for retry := 0; ; retry++ {
+		response, err := c.send(request)
+		if err != nil {
 			return nil, err
 		}
+
+		retryErr := rejection(response)
+		if retryErr == nil {
+			return response, nil
+		}
+		if retry >= c.throttleRetries {
+			return nil, retryErr
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
 	}
-	_, err := common.Write(c.connection, request)
-	if err != nil {
+}
+
+// Stream sends request and returns reader of the response, it's read as it
+// arrives, so a large response isn't held in memory. It must be read till
+// io.EOF before the next request. Rejected requests are retried as by Send.
+func (c *Client) Stream(request []byte) (io.Reader, error) {
+	backoff := c.throttleBackoff
+	for retry := 0; ; retry++ {
+		if err := c.Write(request); err != nil {
+			return nil, err
+		}
+
+		response := bufio.NewReader(common.NewMessageReader(deadlineReader{client: c}, c.maxMessageSize))
+		// rejections are short, so the response is rejected only if it's
+		// read whole by peek
+		head, err := response.Peek(len(common.ThrottledResponse) + 1)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		retryErr := rejection(head)
+		if retryErr == nil {
+			return response, nil
+		}
+		if retry >= c.throttleRetries {
+			return nil, retryErr
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func rejection(response []byte) error {
+	switch string(response) {
+	case common.ThrottledResponse:
+		return ErrThrottled
+	case common.BusyResponse:
+		return ErrBusy
+	}
+
+	return nil
+}
+
+// deadlineReader extends read deadline of the connection before every read,
+// so reading a long stream isn't cut by the idle timeout.
+type deadlineReader struct {
+	client *Client
+}
+
+func (r deadlineReader) Read(data []byte) (int, error) {
+	if r.client.idleTimeout != 0 {
+		if err := r.client.connection.SetReadDeadline(time.Now().Add(r.client.idleTimeout)); err != nil {
+			return 0, err
+		}
+	}
+
+	return r.client.connection.Read(data)
+}
+
+func (c *Client) send(request []byte) ([]byte, error) {
+	if err := c.Write(request); err != nil {
+		return nil, err
+	}
+
+	response, err := c.Receive()
+	if err != nil && err != io.EOF {
 		return nil, err
 	}
 
-	response := make([]byte, c.bufferSize)
+	return response, nil
+}
+
+// Write sends message without waiting for response, e.g. to acknowledge
+// messages of a stream.
+func (c *Client) Write(message []byte) error {
+	if c.idleTimeout != 0 {
+		if err := c.connection.SetWriteDeadline(time.Now().Add(c.idleTimeout)); err != nil {
+			return err
+		}
+	}
+
+	return common.WriteMessage(c.connection, message, c.bufferSize)
+}
+
+// Receive reads the next message sent by server, it's used for streams
+// server keeps sending messages to after the request.
+func (c *Client) Receive() ([]byte, error) {
 	if c.idleTimeout != 0 {
 		if err := c.connection.SetReadDeadline(time.Now().Add(c.idleTimeout)); err != nil {
 			return nil, err
 		}
 	}
-	n, err := common.Read(c.connection, response)
-	if err != nil && err != io.EOF {
-		return nil, err
-	}
 
-	return response[:n], nil
+	return common.ReadMessage(c.connection, nil, c.maxMessageSize, c.maxMessageSize)
 }
 
 func (c *Client) Close() error {
@@ -79,6 +194,10 @@ func (c *Client) Run() error {
 		}
 
 		response, err := c.Send(request)
+		if errors.Is(err, ErrThrottled) || errors.Is(err, ErrBusy) {
+			fmt.Println(err.Error())
+			continue
+		}
 		if err != nil {
 			return err
 		}
@@ -15,3 +15,16 @@ func WithBufferSize(bufferSize int) ClientOption {
 		c.bufferSize = bufferSize
 	}
 }
+
+func WithMaxMessageSize(maxMessageSize int) ClientOption {
+	return func(c *Client) {
+		c.maxMessageSize = maxMessageSize
+	}
+}
+
+func WithThrottleBackoff(retries int, backoff time.Duration) ClientOption {
+	return func(c *Client) {
+		c.throttleRetries = retries
+		c.throttleBackoff = backoff
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type ClientInfo struct {
+	ID          uint64
+	RemoteAddr  string
+	LocalAddr   string
+	ConnectedAt time.Time
+	LastCommand time.Time
+	Commands    uint64
+}
+
+type ConnectionStats struct {
+	Connected int
+	Accepted  uint64
+	Commands  uint64
+	Throttled uint64
+}
+
+type client struct {
+	id          uint64
+	conn        net.Conn
+	identity    string
+	connectedAt time.Time
+	lastCommand atomic.Int64
+	commands    atomic.Uint64
+}
+
+func (c *client) touch() {
+	c.lastCommand.Store(time.Now().UnixNano())
+	c.commands.Add(1)
+}
+
+type connections struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	conns   map[net.Conn]*client
+	closing bool
+
+	lastID    uint64
+	accepted  atomic.Uint64
+	commands  atomic.Uint64
+	throttled atomic.Uint64
+}
+
+func newConnections() *connections {
+	return &connections{conns: make(map[net.Conn]*client)}
+}
+
+func (c *connections) add(conn net.Conn) *client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closing {
+		return nil
+	}
+
+	c.lastID++
+	client := &client{
+		id:          c.lastID,
+		conn:        conn,
+		identity:    connectionIdentity(conn),
+		connectedAt: time.Now(),
+	}
+	c.conns[conn] = client
+	c.accepted.Add(1)
+	c.wg.Add(1)
+	return client
+}
+
+func (c *connections) remove(conn net.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.conns[conn]; ok {
+		delete(c.conns, conn)
+		c.wg.Done()
+	}
+}
+
+// prepareRead sets read deadline unless server is closing. Both happen under
+// the same lock as in interrupt, so a connection can't miss the shutdown.
+func (c *connections) prepareRead(conn net.Conn, deadline time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closing {
+		return false, nil
+	}
+
+	return true, conn.SetReadDeadline(deadline)
+}
+
+func (c *connections) isClosing() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closing
+}
+
+// interrupt marks connections as closing and wakes up the ones blocked on read,
+// the ones executing a command finish it first.
+func (c *connections) interrupt() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closing = true
+	for conn := range c.conns {
+		_ = conn.SetReadDeadline(time.Now())
+	}
+
+	return len(c.conns)
+}
+
+func (c *connections) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.wg.Wait()
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (c *connections) closeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for conn := range c.conns {
+		_ = conn.Close()
+	}
+}
+
+func (c *connections) list() []ClientInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clients := make([]ClientInfo, 0, len(c.conns))
+	for _, client := range c.conns {
+		info := ClientInfo{
+			ID:          client.id,
+			RemoteAddr:  client.conn.RemoteAddr().String(),
+			LocalAddr:   client.conn.LocalAddr().String(),
+			ConnectedAt: client.connectedAt,
+			Commands:    client.commands.Load(),
+		}
+		if lastCommand := client.lastCommand.Load(); lastCommand != 0 {
+			info.LastCommand = time.Unix(0, lastCommand)
+		}
+		clients = append(clients, info)
+	}
+
+	return clients
+}
+
+// kill closes connection of the client, its handler stops on the next read or
+// write.
+func (c *connections) kill(id uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for conn, client := range c.conns {
+		if client.id == id {
+			_ = conn.Close()
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *connections) stats() ConnectionStats {
+	c.mu.Lock()
+	connected := len(c.conns)
+	c.mu.Unlock()
+
+	return ConnectionStats{
+		Connected: connected,
+		Accepted:  c.accepted.Load(),
+		Commands:  c.commands.Load(),
+		Throttled: c.throttled.Load(),
+	}
+}
+
+func connectionIdentity(conn net.Conn) string {
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP.String()
+	}
+
+	return localIdentity
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+
+	"github.com/DaniilZ77/InMemDB/internal/common"
+)
+
+const defaultSocketPermissions fs.FileMode = 0660
+
+func listen(address string, socketPermissions fs.FileMode) (net.Listener, error) {
+	network, addr := common.ParseAddress(address)
+	if network != "unix" {
+		return net.Listen(network, addr)
+	}
+
+	if err := removeStaleSocket(addr); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(addr, socketPermissions); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// removeStaleSocket removes socket file left after unclean shutdown, refusing
+// to touch the path if another server still accepts connections on it.
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&fs.ModeSocket == 0 {
+		return errors.New("address is not a socket: " + path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return errors.New("socket is already in use: " + path)
+	}
+
+	return os.Remove(path)
+}
@@ -4,18 +4,45 @@ import (
 	"context"
 	"log/slog"
 	"net"
+
+	"github.com/DaniilZ77/InMemDB/internal/common"
 )
 
 type handler func(context.Context, net.Conn)
 
-func (s *Server) clientsLimiter(next handler) handler {
-	return func(ctx context.Context, conn net.Conn) {
-		if s.semaphore != nil {
-			s.semaphore.Acquire()
-			defer s.semaphore.Release()
+type requestHandler func(context.Context, []byte) (response, error)
+
+// response is either the message or the stream writing it.
+type response struct {
+	message []byte
+	stream  ResponseStream
+}
+
+type clientKey struct{}
+
+const localIdentity = "local"
+
+func withClient(ctx context.Context, client *client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+func identity(ctx context.Context) string {
+	if client, ok := ctx.Value(clientKey{}).(*client); ok {
+		return client.identity
+	}
+
+	return localIdentity
+}
+
+func (s *Server) rateLimiter(next requestHandler) requestHandler {
+	return func(ctx context.Context, request []byte) (response, error) {
+		if s.limiter != nil && !s.limiter.Allow(identity(ctx), request) {
+			s.connections.throttled.Add(1)
+			s.log.Debug("request throttled", slog.String("client", identity(ctx)))
+			return response{message: []byte(common.ThrottledResponse)}, nil
 		}
 
-		next(ctx, conn)
+		return next(ctx, request)
 	}
 }
 
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/DaniilZ77/InMemDB/internal/concurrency"
+)
+
+// maxBuckets caps the number of buckets, the least recently used one is
+// dropped to make room, so its client starts over with a full bucket.
+const maxBuckets = 1 << 10
+
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimits maps request class to its limit, classes without a limit are
+// not throttled.
+type RateLimits map[string]Limit
+
+type bucketKey struct {
+	identity string
+	class    string
+}
+
+type bucketEntry struct {
+	key    bucketKey
+	bucket *concurrency.TokenBucket
+}
+
+type RateLimiter struct {
+	classify func(request []byte) string
+	defaults RateLimits
+	clients  map[string]RateLimits
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*list.Element
+	recent  *list.List
+}
+
+// NewRateLimiter creates token bucket rate limiter keyed by client identity
+// and request class, clients limits override defaults class by class.
+func NewRateLimiter(classify func(request []byte) string, defaults RateLimits, clients map[string]RateLimits) *RateLimiter {
+	return &RateLimiter{
+		classify: classify,
+		defaults: defaults,
+		clients:  clients,
+		buckets:  make(map[bucketKey]*list.Element),
+		recent:   list.New(),
+	}
+}
+
+func (r *RateLimiter) Allow(identity string, request []byte) bool {
+	class := r.classify(request)
+
+	limit, ok := r.clients[identity][class]
+	if !ok {
+		limit, ok = r.defaults[class]
+	}
+	if !ok {
+		return true
+	}
+
+	return r.bucket(bucketKey{identity: identity, class: class}, limit).Allow()
+}
+
+func (r *RateLimiter) bucket(key bucketKey, limit Limit) *concurrency.TokenBucket {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if element, ok := r.buckets[key]; ok {
+		r.recent.MoveToFront(element)
+		return element.Value.(*bucketEntry).bucket
+	}
+
+	if r.recent.Len() >= maxBuckets {
+		oldest := r.recent.Back()
+		r.recent.Remove(oldest)
+		delete(r.buckets, oldest.Value.(*bucketEntry).key)
+	}
+
+	bucket := concurrency.NewTokenBucket(limit.Rate, limit.Burst)
+	r.buckets[key] = r.recent.PushFront(&bucketEntry{key: key, bucket: bucket})
+	return bucket
+}
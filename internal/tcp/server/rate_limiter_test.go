@@ -0,0 +1,59 @@
+package server
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	classify := func(request []byte) string {
+		return string(request)
+	}
+	limiter := NewRateLimiter(classify,
+		RateLimits{"write": {Rate: 0, Burst: 1}},
+		map[string]RateLimits{"10.0.0.1": {"write": {Rate: 0, Burst: 2}, "read": {Rate: 0, Burst: 1}}},
+	)
+
+	t.Run("default limit", func(t *testing.T) {
+		assert.True(t, limiter.Allow("10.0.0.2", []byte("write")))
+		assert.False(t, limiter.Allow("10.0.0.2", []byte("write")))
+		assert.True(t, limiter.Allow("10.0.0.3", []byte("write")))
+	})
+
+	t.Run("unlimited class", func(t *testing.T) {
+		for range 10 {
+			assert.True(t, limiter.Allow("10.0.0.2", []byte("read")))
+		}
+	})
+
+	t.Run("client limit", func(t *testing.T) {
+		assert.True(t, limiter.Allow("10.0.0.1", []byte("write")))
+		assert.True(t, limiter.Allow("10.0.0.1", []byte("write")))
+		assert.False(t, limiter.Allow("10.0.0.1", []byte("write")))
+		assert.True(t, limiter.Allow("10.0.0.1", []byte("read")))
+		assert.False(t, limiter.Allow("10.0.0.1", []byte("read")))
+	})
+}
+
+func TestRateLimiter_Eviction(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(func([]byte) string { return "write" }, RateLimits{"write": {Rate: 0, Burst: 1}}, nil)
+
+	assert.True(t, limiter.Allow("oldest", nil))
+	assert.True(t, limiter.Allow("recent", nil))
+	for i := range maxBuckets - 2 {
+		assert.True(t, limiter.Allow(strconv.Itoa(i), nil))
+	}
+	assert.False(t, limiter.Allow("oldest", nil))
+
+	assert.True(t, limiter.Allow("new", nil))
+	assert.Len(t, limiter.buckets, maxBuckets)
+	assert.False(t, limiter.Allow("oldest", nil), "recently used bucket is kept")
+	assert.True(t, limiter.Allow("recent", nil), "least recently used bucket is evicted")
+	assert.Len(t, limiter.buckets, maxBuckets)
+}
@@ -3,6 +3,8 @@ package server
 import (
 	"context"
 	"errors"
+	"io"
+	"io/fs"
 	"log/slog"
 	"net"
 	"time"
@@ -12,18 +14,48 @@ import (
 )
 
 type Server struct {
-	listener    net.Listener
-	bufferSize  int
-	idleTimeout time.Duration
-	logic       func([]byte) ([]byte, error)
-	semaphore   *concurrency.Semaphore
-	log         *slog.Logger
+	listeners         []net.Listener
+	addresses         []string
+	socketPermissions fs.FileMode
+	bufferSize        int
+	maxRequestSize    int
+	maxRequestMemory  int
+	budget            *concurrency.Budget
+	idleTimeout       time.Duration
+	drainTimeout      time.Duration
+	logic             func([]byte) ([]byte, error)
+	streamer          Streamer
+	stream            StreamHandler
+	semaphore         *concurrency.Semaphore
+	limiter           *RateLimiter
+	connections       *connections
+	log               *slog.Logger
 }
 
 const (
-	defaultBufferSize = 4 << 10
+	defaultBufferSize       = 4 << 10
+	defaultMaxRequestSize   = 8 << 20
+	defaultMaxRequestMemory = 64 << 20
+	defaultDrainTimeout     = 5 * time.Second
+
+	errMessageTooLarge = "ERROR(message too large)"
 )
 
+// StreamHandler serves a stream connection: it gets the first request of the
+// connection and sends messages until ctx is done or sending fails, receive
+// reads messages the client sends meanwhile.
+type StreamHandler func(ctx context.Context, request []byte, send func([]byte) error, receive func() ([]byte, error)) error
+
+// ResponseStream writes the response, it's sent in chunks as it's written,
+// so its size isn't limited by memory.
+type ResponseStream func(writer io.Writer) error
+
+// Streamer returns the stream of the response if the request is answered by
+// a stream instead of logic.
+type Streamer func(request []byte) (ResponseStream, bool)
+
+var errStreamClosed = errors.New("stream is closed")
+
 //go:generate mockery --name=Database --case=snake --inpackage --inpackage-suffix --with-expecter
 type Database interface {
 	Execute(source string) string
@@ -37,90 +69,221 @@ func NewServer(
 		return nil, errors.New("logger is nil")
 	}
 
-	listener, err := net.Listen("tcp", address)
-	if err != nil {
-		return nil, err
-	}
-
-	log.Info("started listening", slog.String("address", address))
-
 	server := &Server{
-		listener:   listener,
-		bufferSize: maxMessageSize,
-		log:        log,
+		addresses:         []string{address},
+		socketPermissions: defaultSocketPermissions,
+		bufferSize:        maxMessageSize,
+		maxRequestSize:    defaultMaxRequestSize,
+		maxRequestMemory:  defaultMaxRequestMemory,
+		drainTimeout:      defaultDrainTimeout,
+		connections:       newConnections(),
+		log:               log,
 	}
 
 	for _, opt := range opts {
 		opt(server)
 	}
 
+	for _, address := range server.addresses {
+		listener, err := listen(address, server.socketPermissions)
+		if err != nil {
+			server.closeListeners()
+			return nil, err
+		}
+		server.listeners = append(server.listeners, listener)
+
+		log.Info("started listening", slog.String("address", address))
+	}
+
 	if server.bufferSize == 0 {
 		server.bufferSize = defaultBufferSize
 	}
+	if server.maxRequestSize < server.bufferSize {
+		server.maxRequestSize = server.bufferSize
+	}
+	// Requests up to bufferSize are read into the connection buffer, budget
+	// limits memory of larger ones across all connections.
+	server.budget = concurrency.NewBudget(max(server.maxRequestMemory, server.maxRequestSize))
 
 	return server, nil
 }
 
 func (s *Server) Run(ctx context.Context, logic func([]byte) ([]byte, error)) error {
-	done := make(chan struct{})
+	done := make(chan struct{}, len(s.listeners))
 	s.logic = logic
 
-	defer func() {
-		if err := s.listener.Close(); err != nil {
-			s.log.Error("failed to close listener", slog.Any("error", err))
-		}
-	}()
+	// Accept loops waiting for a client slot are stopped by acceptCtx, so
+	// they are waited for on shutdown however many clients are connected.
+	acceptCtx, stop := context.WithCancel(ctx)
+	defer stop()
 
-	go func() {
-		defer close(done)
-		for {
-			connection, err := s.listener.Accept()
-			if err != nil {
-				if !errors.Is(err, net.ErrClosed) {
-					s.log.Error("failed to accept connection", slog.Any("error", err))
-				}
-				return
-			}
-
-			go s.recoverer(s.clientsLimiter(s.handler))(ctx, connection)
-		}
-	}()
+	for _, listener := range s.listeners {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			s.accept(acceptCtx, listener)
+		}()
+	}
 
+	var err error
+	stopped := 0
 	select {
 	case <-ctx.Done():
-		s.log.Info("stopping server")
-		return nil
 	case <-done:
+		stopped++
 		s.log.Error("unexpected server error")
-		return errors.New("server stopped accepting connections")
+		err = errors.New("server stopped accepting connections")
 	}
+
+	stop()
+	s.shutdown()
+	for ; stopped < len(s.listeners); stopped++ {
+		<-done
+	}
+
+	return err
+}
+
+// RunStreaming runs the server as Run does, but requests the streamer returns
+// a stream for are answered by the stream.
+func (s *Server) RunStreaming(ctx context.Context, logic func([]byte) ([]byte, error), streamer Streamer) error {
+	s.streamer = streamer
+	return s.Run(ctx, logic)
+}
+
+// RunStream runs the server as Run does, but every connection is a stream
+// served by the handler, it's closed once the handler returns.
+func (s *Server) RunStream(ctx context.Context, handler StreamHandler) error {
+	s.stream = handler
+	return s.Run(ctx, nil)
+}
+
+// accept takes a client slot before accepting a connection, so clients over
+// the limit wait in the listener backlog in the order they connected. It
+// returns once ctx is done or the listener is closed.
+func (s *Server) accept(ctx context.Context, listener net.Listener) {
+	for {
+		if !s.acquireClient(ctx) {
+			return
+		}
+
+		connection, err := listener.Accept()
+		if err != nil {
+			s.releaseClient()
+			if !errors.Is(err, net.ErrClosed) {
+				s.log.Error("failed to accept connection", slog.Any("error", err))
+			}
+			return
+		}
+
+		client := s.connections.add(connection)
+		if client == nil {
+			s.releaseClient()
+			if err := connection.Close(); err != nil {
+				s.log.Error("failed to close connection", slog.Any("error", err))
+			}
+			continue
+		}
+
+		go func() {
+			defer s.releaseClient()
+			defer s.connections.remove(connection)
+			s.recoverer(s.handler)(withClient(ctx, client), connection)
+		}()
+	}
+}
+
+func (s *Server) acquireClient(ctx context.Context) bool {
+	if s.semaphore == nil {
+		return true
+	}
+
+	return s.semaphore.Acquire(ctx)
+}
+
+func (s *Server) releaseClient() {
+	if s.semaphore != nil {
+		s.semaphore.Release()
+	}
+}
+
+func (s *Server) closeListeners() {
+	for _, listener := range s.listeners {
+		if err := listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			s.log.Error("failed to close listener", slog.Any("error", err))
+		}
+	}
+}
+
+func (s *Server) shutdown() {
+	s.closeListeners()
+	s.log.Info("stopped accepting connections", slog.Any("addresses", s.addresses))
+
+	active := s.connections.interrupt()
+	s.log.Info("draining connections", slog.Int("active", active), slog.Duration("timeout", s.drainTimeout))
+
+	if s.connections.wait(s.drainTimeout) {
+		s.log.Info("connections drained")
+		return
+	}
+
+	s.log.Warn("drain timeout exceeded, closing connections")
+	s.connections.closeAll()
 }
 
 func (s *Server) handler(ctx context.Context, connection net.Conn) {
 	s.log.Debug("new connection", slog.String("remote", connection.RemoteAddr().String()))
 
+	handle := s.rateLimiter(s.execute)
+
 	defer func() {
 		if err := connection.Close(); err != nil {
 			s.log.Error("failed to close connection", slog.Any("error", err))
 		}
 	}()
 
-	var err error
-	var n int
-	buffer := make([]byte, s.bufferSize)
+	buffer := make([]byte, 0, s.bufferSize)
 	for {
 		if ctx.Err() != nil {
 			return
 		}
 
+		var deadline time.Time
 		if s.idleTimeout != 0 {
-			if err = connection.SetReadDeadline(time.Now().Add(s.idleTimeout)); err != nil {
-				s.log.Error("set read deadline failure", slog.Any("error", err))
+			deadline = time.Now().Add(s.idleTimeout)
+		}
+		ok, err := s.connections.prepareRead(connection, deadline)
+		if err != nil {
+			s.log.Error("set read deadline failure", slog.Any("error", err))
+			return
+		}
+		if !ok {
+			return
+		}
+
+		request, reserved, err := common.ReadMessageBudget(connection, buffer, s.bufferSize, s.maxRequestSize, s.budget)
+		if errors.Is(err, common.ErrMessageTooLarge) {
+			s.log.Warn("message too large", slog.Int("max_request_size", s.maxRequestSize))
+			if !s.write(connection, []byte(errMessageTooLarge)) {
+				return
+			}
+			continue
+		}
+		if errors.Is(err, common.ErrBudgetExceeded) {
+			s.log.Warn("request memory exhausted", slog.Int("max_request_memory", s.maxRequestMemory))
+			if !s.write(connection, []byte(common.BusyResponse)) {
 				return
 			}
+			continue
 		}
-		n, err = common.Read(connection, buffer)
 		if err != nil {
+			if s.connections.isClosing() {
+				s.log.Debug("connection interrupted by shutdown", slog.Any("error", err))
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				s.log.Info("connection killed", slog.String("remote", connection.RemoteAddr().String()))
+				return
+			}
 			var ne net.Error
 			if errors.As(err, &ne) && ne.Timeout() {
 				s.log.Warn("idle connection", slog.Any("error", err))
@@ -130,20 +293,151 @@ func (s *Server) handler(ctx context.Context, connection net.Conn) {
 			return
 		}
 
-		response, err := s.logic(buffer[:n])
+		// Large requests don't keep their buffer, so idle connections hold
+		// at most bufferSize bytes.
+		if cap(request) <= s.bufferSize {
+			buffer = request[:0]
+		} else {
+			buffer = make([]byte, 0, s.bufferSize)
+		}
+
+		if s.stream != nil {
+			s.budget.Release(reserved)
+			s.serveStream(ctx, connection, request)
+			return
+		}
+
+		response, err := handle(ctx, request)
+		s.budget.Release(reserved)
 		if err != nil {
 			s.log.Error("failed to execute logic", slog.Any("error", err))
 			return
 		}
-		if s.idleTimeout != 0 {
-			if err = connection.SetWriteDeadline(time.Now().Add(s.idleTimeout)); err != nil {
-				s.log.Error("set write deadline failure", slog.Any("error", err))
+		if response.stream != nil {
+			if !s.writeStream(connection, response.stream) {
 				return
 			}
+			continue
 		}
-		if _, err = common.Write(connection, response); err != nil {
-			s.log.Error("write failure", slog.Any("error", err))
+		if !s.write(connection, response.message) {
 			return
 		}
 	}
 }
+
+func (s *Server) execute(ctx context.Context, request []byte) (response, error) {
+	if client, ok := ctx.Value(clientKey{}).(*client); ok {
+		client.touch()
+	}
+	s.connections.commands.Add(1)
+
+	if s.streamer != nil {
+		if stream, ok := s.streamer(request); ok {
+			return response{stream: stream}, nil
+		}
+	}
+
+	message, err := s.logic(request)
+	return response{message: message}, err
+}
+
+func (s *Server) serveStream(ctx context.Context, connection net.Conn, request []byte) {
+	if client, ok := ctx.Value(clientKey{}).(*client); ok {
+		client.touch()
+	}
+	s.connections.commands.Add(1)
+
+	send := func(message []byte) error {
+		if !s.write(connection, message) {
+			return errStreamClosed
+		}
+		return nil
+	}
+	receive := func() ([]byte, error) {
+		var deadline time.Time
+		if s.idleTimeout != 0 {
+			deadline = time.Now().Add(s.idleTimeout)
+		}
+		ok, err := s.connections.prepareRead(connection, deadline)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errStreamClosed
+		}
+
+		message, err := common.ReadMessage(connection, nil, s.bufferSize, s.maxRequestSize)
+		if err != nil {
+			return nil, err
+		}
+		if client, ok := ctx.Value(clientKey{}).(*client); ok {
+			client.touch()
+		}
+		return message, nil
+	}
+
+	err := s.stream(ctx, request, send, receive)
+	if err != nil && !errors.Is(err, errStreamClosed) {
+		s.log.Error("failed to serve stream", slog.Any("error", err))
+	}
+}
+
+func (s *Server) Clients() []ClientInfo {
+	return s.connections.list()
+}
+
+func (s *Server) KillClient(id uint64) bool {
+	return s.connections.kill(id)
+}
+
+func (s *Server) Stats() ConnectionStats {
+	return s.connections.stats()
+}
+
+func (s *Server) write(connection net.Conn, response []byte) bool {
+	if s.idleTimeout != 0 {
+		if err := connection.SetWriteDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+			s.log.Error("set write deadline failure", slog.Any("error", err))
+			return false
+		}
+	}
+	if err := common.WriteMessage(connection, response, s.bufferSize); err != nil {
+		s.log.Error("write failure", slog.Any("error", err))
+		return false
+	}
+
+	return true
+}
+
+// writeStream sends the stream as a single message, the connection is closed
+// if the stream fails, as the part of the message is already sent.
+func (s *Server) writeStream(connection net.Conn, stream ResponseStream) bool {
+	writer := common.NewMessageWriter(deadlineWriter{connection: connection, timeout: s.idleTimeout}, s.bufferSize)
+	if err := stream(writer); err != nil {
+		s.log.Error("failed to stream response", slog.Any("error", err))
+		return false
+	}
+	if err := writer.Close(); err != nil {
+		s.log.Error("write failure", slog.Any("error", err))
+		return false
+	}
+
+	return true
+}
+
+// deadlineWriter extends write deadline of the connection before every write,
+// so a slow stream isn't cut by the idle timeout.
+type deadlineWriter struct {
+	connection net.Conn
+	timeout    time.Duration
+}
+
+func (w deadlineWriter) Write(data []byte) (int, error) {
+	if w.timeout != 0 {
+		if err := w.connection.SetWriteDeadline(time.Now().Add(w.timeout)); err != nil {
+			return 0, err
+		}
+	}
+
+	return w.connection.Write(data)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"io/fs"
 	"time"
 
 	"github.com/DaniilZ77/InMemDB/internal/concurrency"
@@ -25,3 +26,41 @@ func WithMaxMessageSize(maxMessageSize int) ServerOption {
 		s.bufferSize = maxMessageSize
 	}
 }
+
+func WithDrainTimeout(drainTimeout time.Duration) ServerOption {
+	return func(s *Server) {
+		s.drainTimeout = drainTimeout
+	}
+}
+
+func WithListeners(addresses ...string) ServerOption {
+	return func(s *Server) {
+		s.addresses = append(s.addresses, addresses...)
+	}
+}
+
+func WithSocketPermissions(permissions fs.FileMode) ServerOption {
+	return func(s *Server) {
+		s.socketPermissions = permissions
+	}
+}
+
+func WithMaxRequestSize(maxRequestSize int) ServerOption {
+	return func(s *Server) {
+		s.maxRequestSize = maxRequestSize
+	}
+}
+
+// WithMaxRequestMemory limits total size of requests larger than the message
+// buffer being read or executed at once, requests over it get busy response.
+func WithMaxRequestMemory(maxRequestMemory int) ServerOption {
+	return func(s *Server) {
+		s.maxRequestMemory = maxRequestMemory
+	}
+}
+
+func WithRateLimiter(limiter *RateLimiter) ServerOption {
+	return func(s *Server) {
+		s.limiter = limiter
+	}
+}
@@ -1,11 +1,15 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"io"
 	"log/slog"
 	"net"
 	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
@@ -33,7 +37,7 @@ func TestServer(t *testing.T) {
 	})
 	time.Sleep(100 * time.Millisecond)
 
-	address := server.listener.Addr().String()
+	address := server.listeners[0].Addr().String()
 	command := []byte("set name Daniil")
 
 	t.Run("success", func(t *testing.T) {
@@ -83,3 +87,476 @@ func TestServer(t *testing.T) {
 		assert.ErrorIs(t, err, io.EOF)
 	})
 }
+
+func TestServer_GracefulShutdown(t *testing.T) {
+	server, err := NewServer(
+		"127.0.0.1:0",
+		100,
+		slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithIdleTimeout(time.Minute),
+		WithDrainTimeout(time.Second))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	started := make(chan struct{})
+	stopped := make(chan error)
+	go func() {
+		stopped <- server.Run(ctx, func(b []byte) ([]byte, error) {
+			close(started)
+			time.Sleep(300 * time.Millisecond)
+			return []byte("OK"), nil
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	address := server.listeners[0].Addr().String()
+
+	idleConn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	t.Cleanup(func() { idleConn.Close() }) // nolint
+
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) // nolint
+
+	_, err = common.Write(conn, []byte("set name Daniil"))
+	require.NoError(t, err)
+
+	<-started
+	cancel()
+
+	buffer := make([]byte, 1024)
+	n, err := common.Read(conn, buffer)
+	require.NoError(t, err)
+	assert.Equal(t, "OK", string(buffer[:n]))
+
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("server did not stop after drain")
+	}
+
+	_, err = idleConn.Read(make([]byte, 10))
+	assert.ErrorIs(t, err, io.EOF)
+
+	_, err = net.Dial("tcp", address)
+	assert.Error(t, err)
+}
+
+func TestServer_DrainTimeout(t *testing.T) {
+	server, err := NewServer(
+		"127.0.0.1:0",
+		100,
+		slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithDrainTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	started := make(chan struct{})
+	stopped := make(chan error)
+	go func() {
+		stopped <- server.Run(ctx, func(b []byte) ([]byte, error) {
+			close(started)
+			<-release
+			return []byte("OK"), nil
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", server.listeners[0].Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) // nolint
+
+	_, err = common.Write(conn, []byte("get name"))
+	require.NoError(t, err)
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("server did not stop after drain timeout")
+	}
+
+	_, err = conn.Read(make([]byte, 10))
+	assert.Error(t, err)
+}
+
+func TestServer_ShutdownAtConnectionLimit(t *testing.T) {
+	server, err := NewServer(
+		"127.0.0.1:0",
+		100,
+		slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithMaxConnections(1),
+		WithDrainTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	started := make(chan struct{})
+	stopped := make(chan error)
+	go func() {
+		stopped <- server.Run(ctx, func(b []byte) ([]byte, error) {
+			close(started)
+			<-release
+			return []byte("OK"), nil
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", server.listeners[0].Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) // nolint
+
+	_, err = common.Write(conn, []byte("get name"))
+	require.NoError(t, err)
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err, "accept loop waiting for the slot is stopped")
+	case <-time.After(time.Second):
+		t.Fatal("server did not stop with every slot taken")
+	}
+}
+
+func TestServer_UnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "inmemdb.sock")
+	server, err := NewServer(
+		"127.0.0.1:0",
+		100,
+		slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithListeners("unix://"+socketPath),
+		WithSocketPermissions(0600))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go server.Run(ctx, func(b []byte) ([]byte, error) { // nolint
+		return b, nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	for _, address := range []string{server.listeners[0].Addr().String(), "unix://" + socketPath} {
+		conn, err := net.Dial(common.ParseAddress(address))
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() }) // nolint
+
+		_, err = common.Write(conn, []byte("get name"))
+		require.NoError(t, err)
+
+		buffer := make([]byte, 1024)
+		n, err := common.Read(conn, buffer)
+		require.NoError(t, err)
+		assert.Equal(t, "get name", string(buffer[:n]))
+	}
+}
+
+func TestServer_StaleUnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "inmemdb.sock")
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	_, err = NewServer("unix://"+socketPath, 100, log)
+	assert.Error(t, err)
+
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, listener.Close())
+
+	server, err := NewServer("unix://"+socketPath, 100, log)
+	require.NoError(t, err)
+	server.closeListeners()
+}
+
+func TestServer_ChunkedMessages(t *testing.T) {
+	const maxMessageSize = 100
+	const maxRequestSize = 1 << 20
+	server, err := NewServer(
+		"127.0.0.1:0",
+		maxMessageSize,
+		slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithMaxRequestSize(maxRequestSize))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go server.Run(ctx, func(b []byte) ([]byte, error) { // nolint
+		return b, nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", server.listeners[0].Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) // nolint
+
+	t.Run("large value", func(t *testing.T) {
+		request := append([]byte("set name "), bytes.Repeat([]byte("a"), maxRequestSize/2)...)
+		require.NoError(t, common.WriteMessage(conn, request, maxMessageSize))
+
+		response, err := common.ReadMessage(conn, nil, maxMessageSize, maxRequestSize)
+		require.NoError(t, err)
+		assert.Equal(t, request, response)
+	})
+
+	t.Run("exceed request size", func(t *testing.T) {
+		request := bytes.Repeat([]byte("a"), maxRequestSize+1)
+		require.NoError(t, common.WriteMessage(conn, request, maxMessageSize))
+
+		response, err := common.ReadMessage(conn, nil, maxMessageSize, maxRequestSize)
+		require.NoError(t, err)
+		assert.Equal(t, errMessageTooLarge, string(response))
+
+		require.NoError(t, common.WriteMessage(conn, []byte("get name"), maxMessageSize))
+		response, err = common.ReadMessage(conn, nil, maxMessageSize, maxRequestSize)
+		require.NoError(t, err)
+		assert.Equal(t, "get name", string(response))
+	})
+}
+
+func TestServer_RequestMemory(t *testing.T) {
+	const maxMessageSize = 100
+	const maxRequestSize = 1000
+	server, err := NewServer(
+		"127.0.0.1:0",
+		maxMessageSize,
+		slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithMaxRequestSize(maxRequestSize),
+		WithMaxRequestMemory(maxRequestSize))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go server.Run(ctx, func(b []byte) ([]byte, error) { // nolint
+		return b, nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	address := server.listeners[0].Addr().String()
+	pending, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	t.Cleanup(func() { pending.Close() }) // nolint
+
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) // nolint
+
+	// The first 900 bytes of the pending request hold most of the budget until
+	// its last chunk arrives.
+	chunk := bytes.Repeat([]byte("a"), maxMessageSize)
+	for range 9 {
+		require.NoError(t, binary.Write(pending, binary.LittleEndian, uint32(len(chunk))|1<<31))
+		_, err = pending.Write(chunk)
+		require.NoError(t, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	request := bytes.Repeat([]byte("b"), 3*maxMessageSize)
+	require.NoError(t, common.WriteMessage(conn, request, maxMessageSize))
+	response, err := common.ReadMessage(conn, nil, maxMessageSize, maxRequestSize)
+	require.NoError(t, err)
+	assert.Equal(t, common.BusyResponse, string(response))
+
+	_, err = common.Write(pending, chunk[:50])
+	require.NoError(t, err)
+	response, err = common.ReadMessage(pending, nil, maxMessageSize, maxRequestSize)
+	require.NoError(t, err)
+	assert.Len(t, response, 950)
+
+	require.NoError(t, common.WriteMessage(conn, request, maxMessageSize))
+	response, err = common.ReadMessage(conn, nil, maxMessageSize, maxRequestSize)
+	require.NoError(t, err)
+	assert.Equal(t, request, response, "budget is released once the request is handled")
+}
+
+func TestServer_Stream(t *testing.T) {
+	const maxMessageSize = 100
+	server, err := NewServer("127.0.0.1:0", maxMessageSize, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go server.RunStream(ctx, func(_ context.Context, request []byte, send func([]byte) error, receive func() ([]byte, error)) error { // nolint
+		for i := range 3 {
+			if err := send([]byte(string(request) + " " + strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		ack, err := receive()
+		if err != nil {
+			return err
+		}
+		return send(ack)
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", server.listeners[0].Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) // nolint
+
+	require.NoError(t, common.WriteMessage(conn, []byte("stream"), maxMessageSize))
+	for i := range 3 {
+		message, err := common.ReadMessage(conn, nil, maxMessageSize, maxMessageSize)
+		require.NoError(t, err)
+		assert.Equal(t, "stream "+strconv.Itoa(i), string(message))
+	}
+
+	require.NoError(t, common.WriteMessage(conn, []byte("ack"), maxMessageSize))
+	message, err := common.ReadMessage(conn, nil, maxMessageSize, maxMessageSize)
+	require.NoError(t, err)
+	assert.Equal(t, "ack", string(message), "client messages are received by handler")
+
+	_, err = common.ReadMessage(conn, nil, maxMessageSize, maxMessageSize)
+	assert.ErrorIs(t, err, io.EOF, "connection is closed once the stream is served")
+}
+
+func TestServer_StreamedResponse(t *testing.T) {
+	const maxMessageSize = 100
+	server, err := NewServer("127.0.0.1:0", maxMessageSize, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	line := bytes.Repeat([]byte("a"), 30)
+	go server.RunStreaming(ctx, func(b []byte) ([]byte, error) { // nolint
+		return b, nil
+	}, func(request []byte) (ResponseStream, bool) {
+		switch string(request) {
+		case "dump":
+			return func(writer io.Writer) error {
+				for range 10 {
+					if _, err := writer.Write(line); err != nil {
+						return err
+					}
+				}
+				return nil
+			}, true
+		case "fail":
+			return func(writer io.Writer) error {
+				_, _ = writer.Write(bytes.Repeat(line, 4))
+				return io.ErrUnexpectedEOF
+			}, true
+		}
+		return nil, false
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", server.listeners[0].Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) // nolint
+
+	require.NoError(t, common.WriteMessage(conn, []byte("dump"), maxMessageSize))
+	response, err := common.ReadMessage(conn, nil, maxMessageSize, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat(line, 10), response)
+
+	require.NoError(t, common.WriteMessage(conn, []byte("get"), maxMessageSize))
+	response, err = common.ReadMessage(conn, nil, maxMessageSize, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, "get", string(response), "other requests are executed by logic")
+
+	require.NoError(t, common.WriteMessage(conn, []byte("fail"), maxMessageSize))
+	_, err = common.ReadMessage(conn, nil, maxMessageSize, 1000)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "connection is closed if the stream fails")
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	limiter := NewRateLimiter(func([]byte) string { return "write" }, RateLimits{"write": {Rate: 0, Burst: 1}}, nil)
+	server, err := NewServer(
+		"127.0.0.1:0",
+		100,
+		slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		WithRateLimiter(limiter))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go server.Run(ctx, func(b []byte) ([]byte, error) { // nolint
+		return []byte("OK"), nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", server.listeners[0].Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) // nolint
+
+	buffer := make([]byte, 1024)
+	for _, expected := range []string{"OK", common.ThrottledResponse} {
+		_, err = common.Write(conn, []byte("set name Daniil"))
+		require.NoError(t, err)
+
+		n, err := common.Read(conn, buffer)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(buffer[:n]))
+	}
+}
+
+func TestServer_Clients(t *testing.T) {
+	server, err := NewServer(
+		"127.0.0.1:0",
+		100,
+		slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go server.Run(ctx, func(b []byte) ([]byte, error) { // nolint
+		return []byte("OK"), nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", server.listeners[0].Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) // nolint
+
+	_, err = common.Write(conn, []byte("get name"))
+	require.NoError(t, err)
+	_, err = common.Read(conn, make([]byte, 100))
+	require.NoError(t, err)
+
+	clients := server.Clients()
+	require.Len(t, clients, 1)
+	assert.Equal(t, conn.LocalAddr().String(), clients[0].RemoteAddr)
+	assert.Equal(t, uint64(1), clients[0].Commands)
+	assert.False(t, clients[0].LastCommand.IsZero())
+
+	stats := server.Stats()
+	assert.Equal(t, ConnectionStats{Connected: 1, Accepted: 1, Commands: 1}, stats)
+
+	assert.False(t, server.KillClient(clients[0].ID+1))
+	assert.True(t, server.KillClient(clients[0].ID))
+
+	_, err = conn.Read(make([]byte, 10))
+	assert.ErrorIs(t, err, io.EOF)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, server.Clients())
+}